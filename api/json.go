@@ -0,0 +1,25 @@
+package api;
+
+import (
+  "fmt"
+  "github.com/wenkesj/rphash/parse"
+);
+
+// Convert centroids back into the schema the parser read the data from.
+// Simple and Stream report centroids in the parser's normalized feature space,
+// so each one is denormalized field by field and labeled with its schema key,
+// in the same order the centroids were given.
+// Centroids of any other length, such as ones left in a projected space,
+// cannot be matched to the schema and are rejected.
+func CentroidsToJSON(parser *parse.Parser, centroids [][]float64) (map[string]interface{}, error) {
+  keys := parser.GetSchemaKeys();
+  if len(keys) == 0 {
+    return nil, fmt.Errorf("api: parser has no schema, parse the data set before converting centroids");
+  }
+  for i, centroid := range centroids {
+    if len(centroid) != len(keys) {
+      return nil, fmt.Errorf("api: centroid %d has %d dimensions but the schema has %d fields", i, len(centroid), len(keys));
+    }
+  }
+  return parser.Float64MatrixToJSON(parser.GetLabel(), centroids), nil;
+};
@@ -0,0 +1,101 @@
+package api;
+
+import (
+  "encoding/json"
+  "fmt"
+  "io"
+  "github.com/wenkesj/rphash/classifier"
+  "github.com/wenkesj/rphash/parse"
+  "github.com/wenkesj/rphash/projector"
+);
+
+// The decoder settings a model was trained with.
+type DecoderConfig struct {
+  Dimension int `json:"dimension"`;
+  Rotations int `json:"rotations"`;
+  Searches int `json:"searches"`;
+};
+
+// Model bundles everything needed to serve a clustering: the parser schema
+// the data was read with, the projection and decoder used to hash it, and
+// the centroids. SaveModel writes one and LoadModel reads it back.
+type Model struct {
+  Label string `json:"label"`;
+  Fields []parse.FieldStats `json:"fields"`;
+  Projection *projector.DBFriendly `json:"projection,omitempty"`;
+  Decoder DecoderConfig `json:"decoder"`;
+  Centroids [][]float64 `json:"centroids"`;
+  parser *parse.Parser;
+  classifier *classifier.Classifier;
+};
+
+// Write a model of a finished clustering to w as JSON. The centroids must be
+// in the parser's feature space, as Simple and Stream report them.
+// The projection may be nil if it is not needed for serving.
+func SaveModel(w io.Writer, parser *parse.Parser, projection *projector.DBFriendly, decoder DecoderConfig, centroids [][]float64) error {
+  fields, err := parser.FieldStatistics();
+  if err != nil {
+    return err;
+  }
+  for i, centroid := range centroids {
+    if len(centroid) != len(fields) {
+      return fmt.Errorf("api: centroid %d has %d dimensions but the schema has %d fields", i, len(centroid), len(fields));
+    }
+  }
+  bytes, err := json.MarshalIndent(&Model{
+    Label: parser.GetLabel(),
+    Fields: fields,
+    Projection: projection,
+    Decoder: decoder,
+    Centroids: centroids,
+  }, "", "  ");
+  if err != nil {
+    return err;
+  }
+  _, err = w.Write(bytes);
+  return err;
+};
+
+// Read a model written by SaveModel, ready to Predict.
+func LoadModel(r io.Reader) (*Model, error) {
+  model := new(Model);
+  if err := json.NewDecoder(r).Decode(model); err != nil {
+    return nil, err;
+  }
+  if len(model.Centroids) == 0 {
+    return nil, fmt.Errorf("api: the model has no centroids");
+  }
+  parser := parse.NewParser();
+  if err := parser.ImportStats(model.Label, model.Fields); err != nil {
+    return nil, err;
+  }
+  for i, centroid := range model.Centroids {
+    if len(centroid) != len(model.Fields) {
+      return nil, fmt.Errorf("api: centroid %d has %d dimensions but the schema has %d fields", i, len(centroid), len(model.Fields));
+    }
+  }
+  model.parser = parser;
+  model.classifier = classifier.NewClassifier(model.Centroids);
+  return model, nil;
+};
+
+// The parser restored from the model, for converting data or centroids.
+func (this *Model) GetParser() *parse.Parser {
+  return this.parser;
+};
+
+// The cluster of one JSON object with every field of the model's schema.
+func (this *Model) Predict(rawJSON []byte) (int, error) {
+  if this.parser == nil {
+    return -1, fmt.Errorf("api: the model was not loaded with LoadModel");
+  }
+  var object map[string]interface{};
+  if err := json.Unmarshal(rawJSON, &object); err != nil {
+    return -1, err;
+  }
+  vec, err := this.parser.Transform(object);
+  if err != nil {
+    return -1, err;
+  }
+  return this.classifier.Predict(vec), nil;
+};
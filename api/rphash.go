@@ -0,0 +1,121 @@
+package api;
+
+import (
+  "math"
+  "github.com/wenkesj/rphash/defaults"
+  "github.com/wenkesj/rphash/reader"
+  "github.com/wenkesj/rphash/simple"
+  "github.com/wenkesj/rphash/types"
+);
+
+// RPHash clusters a data set with the whole pipeline wired up: the RPHash
+// object, its decoder, and the Simple clusterer that maps, reduces and refines.
+// Build one with New for data in memory or NewStream for data read from an
+// iterator, and read the result with Centroids.
+type RPHash struct {
+  object types.RPHashObject;
+  clusterer *simple.Simple;
+};
+
+// An Option configures the RPHash object before the clustering is built.
+type Option func(object types.RPHashObject);
+
+// Fix the random seed so repeated runs give the same centroids.
+// Without it every RPHash picks its own seed.
+func WithSeed(seed int64) Option {
+  return func(object types.RPHashObject) {
+    object.SetRandomSeed(seed);
+  };
+};
+
+// Decode projected vectors with d. Its dimensionality must not exceed the
+// dimensions of the data, as the vectors are projected down to it.
+func WithDecoder(d types.Decoder) Option {
+  return func(object types.RPHashObject) {
+    object.SetDecoderType(d);
+  };
+};
+
+// Set the modulus hashes are reduced by.
+func WithHashModulus(m int64) Option {
+  return func(object types.RPHashObject) {
+    object.SetHashModulus(m);
+  };
+};
+
+// Hash every vector with n random projections. The object keeps the
+// setting for a stream.Stream built over GetRPHash(); Simple, which Centroids
+// runs, hashes each vector once and does not read it.
+func WithProjections(n int) Option {
+  return func(object types.RPHashObject) {
+    object.SetNumberOfProjections(n);
+  };
+};
+
+// Probe n blurred copies of every projected vector. Like WithProjections it
+// is kept for a stream.Stream and not read by Simple.
+func WithBlurs(n int) Option {
+  return func(object types.RPHashObject) {
+    if blurred, ok := object.(interface{ SetNumberOfBlurs(n int) }); ok {
+      blurred.SetNumberOfBlurs(n);
+    }
+  };
+};
+
+// Cluster data into k clusters. Options are applied in order, and the
+// variance is then set from the data. Nothing runs until Centroids is called.
+func New(data [][]float64, k int, opts ...Option) *RPHash {
+  object := reader.NewSimpleArray(data, k);
+  for _, opt := range opts {
+    opt(object);
+  }
+  object.SetVariance(data);
+  return &RPHash{
+    object: object,
+    clusterer: simple.NewSimple(object),
+  };
+};
+
+// Cluster the vectors of the given dimension read from vecs into k clusters,
+// without holding them in memory. The decoder and hash modulus default to the
+// ones New uses, so both give the same centroids for the same data and seed.
+// Each run reads vecs from the start again; the caller closes it.
+func NewStream(vecs types.Iterator, dimension, k int, opts ...Option) *RPHash {
+  object := reader.NewStreamObject(dimension, k);
+  object.SetVectorIterator(vecs);
+  object.SetDecoderType(defaults.NewDecoder(dimension / 2, 6, 1));
+  object.SetHashModulus(math.MaxInt64);
+  for _, opt := range opts {
+    opt(object);
+  }
+  return &RPHash{
+    object: object,
+    clusterer: simple.NewSimple(object),
+  };
+};
+
+// Run the map and reduce phases, returning any error they report.
+func (this *RPHash) Run() error {
+  return this.clusterer.Run();
+};
+
+// The k centroids, running the pipeline first if it has not run yet.
+// Nil if the run fails.
+func (this *RPHash) Centroids() [][]float64 {
+  return this.clusterer.GetCentroids();
+};
+
+// Centroids, so an RPHash is a types.Clusterer.
+func (this *RPHash) GetCentroids() [][]float64 {
+  return this.Centroids();
+};
+
+// The RPHash object the options were applied to.
+func (this *RPHash) GetRPHash() types.RPHashObject {
+  return this.object;
+};
+
+// The Simple clusterer underneath, for assignment and tuning.
+func (this *RPHash) GetSimple() *simple.Simple {
+  return this.clusterer;
+};
@@ -0,0 +1,87 @@
+package api;
+
+import (
+  "fmt"
+  "math"
+  "sync"
+  "github.com/wenkesj/rphash/metrics"
+  "github.com/wenkesj/rphash/types"
+  "github.com/wenkesj/rphash/utils"
+);
+
+// The most vectors SelectK scores each candidate on. Silhouette is quadratic
+// in the number of vectors, so larger data sets are scored on a sample.
+const selectKSampleSize = 1000;
+
+// Run the clustering once per candidate K, in parallel, and score each result
+// by its mean silhouette on the data. Higher is better, and the best scoring K
+// is returned with every score. Candidates that cannot be scored, below 2 or
+// not smaller than the number of vectors, are left out of the scores, and an
+// error is returned if none can be.
+// Inertia, metrics.WCSS, is not offered as a score: it only falls as K grows,
+// so the best inertia is always the largest candidate, and reading the elbow
+// of its curve is a judgement left to the caller.
+func SelectK(data [][]float64, candidates []int) (bestK int, scores map[int]float64, err error) {
+  return SelectKWith(data, candidates, func(k int) types.Clusterer {
+    return NewRPHash(data, k);
+  });
+};
+
+// SelectK with any clustering algorithm. newClusterer is called once per
+// candidate, concurrently, and must return a clusterer over data for that K.
+// Candidates whose run fails are left out of the scores. If no candidate is
+// scored the error of a failed run, or else one saying none could be, is
+// returned.
+func SelectKWith(data [][]float64, candidates []int, newClusterer func(k int) types.Clusterer) (bestK int, scores map[int]float64, err error) {
+  sample := data;
+  if len(data) > selectKSampleSize {
+    reservoir := utils.NewReservoirWithSeed(selectKSampleSize, 0);
+    for _, vec := range data {
+      reservoir.Push(vec);
+    }
+    sample = reservoir.Sample();
+  }
+
+  scores = make(map[int]float64);
+  var runErr error;
+  var lock sync.Mutex;
+  var runs sync.WaitGroup;
+  for _, k := range candidates {
+    if k < 2 || k >= len(data) {
+      continue;
+    }
+    runs.Add(1);
+    go func(k int) {
+      defer runs.Done();
+      clusterer := newClusterer(k);
+      if err := clusterer.Run(); err != nil {
+        lock.Lock();
+        if runErr == nil {
+          runErr = err;
+        }
+        lock.Unlock();
+        return;
+      }
+      centroids := clusterer.GetCentroids();
+      score := metrics.Silhouette(sample, centroids);
+      lock.Lock();
+      scores[k] = score;
+      lock.Unlock();
+    }(k);
+  }
+  runs.Wait();
+
+  if len(scores) == 0 {
+    if runErr != nil {
+      return 0, scores, runErr;
+    }
+    return 0, scores, fmt.Errorf("api: no candidate K between 2 and %d to score", len(data) - 1);
+  }
+  best := math.Inf(-1);
+  for k, score := range scores {
+    if score > best || (score == best && k < bestK) {
+      best, bestK = score, k;
+    }
+  }
+  return bestK, scores, nil;
+};
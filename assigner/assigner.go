@@ -0,0 +1,59 @@
+package assigner;
+
+import (
+    "github.com/wenkesj/rphash/types"
+    "github.com/wenkesj/rphash/utils"
+);
+
+// Bucket assigns a vector to the first centroid whose LSH ids contain the
+// vector's hash. This is the exact bucket match Simple has always used.
+type Bucket struct {};
+
+func NewBucket() *Bucket {
+    return &Bucket{};
+};
+
+func (this *Bucket) Assign(vec []float64, hash int64, centroids []types.Centroid) int {
+    for i, cent := range centroids {
+        if cent.GetIDs().Contains(hash) {
+            return i;
+        }
+    }
+    return -1;
+};
+
+// Nearest assigns a vector to the centroid whose reference vector is closest,
+// ignoring the hash. The references are fixed at construction, typically the
+// centroids of an earlier run, so assignment does not depend on the partial
+// means being accumulated.
+type Nearest struct {
+    references [][]float64;
+    distance types.Distance;
+};
+
+func NewNearest(references [][]float64) *Nearest {
+    return &Nearest{
+        references: references,
+    };
+};
+
+// Pick the closest reference by distance rather than by Euclidean distance,
+// which nil restores.
+func (this *Nearest) SetDistance(distance types.Distance) {
+    this.distance = distance;
+};
+
+func (this *Nearest) Assign(vec []float64, hash int64, centroids []types.Centroid) int {
+    if len(this.references) == 0 {
+        return -1;
+    }
+    distance := this.distance;
+    if distance == nil {
+        distance = utils.Distance;
+    }
+    nearest := utils.FindNearest(vec, this.references, distance);
+    if nearest >= len(centroids) {
+        return -1;
+    }
+    return nearest;
+};
@@ -0,0 +1,122 @@
+package classifier;
+
+import (
+    "math"
+    "github.com/wenkesj/rphash/types"
+    "github.com/wenkesj/rphash/utils"
+);
+
+// Classifier labels vectors with the index of their nearest centroid,
+// typically the centroids of a finished Simple or Stream run.
+type Classifier struct {
+    centroids [][]float64;
+    index *kdTree;
+    stats []DistanceStats;
+    distance types.Distance;
+};
+
+func NewClassifier(centroids [][]float64) *Classifier {
+    return &Classifier{
+        centroids: centroids,
+        index: nil,
+        stats: make([]DistanceStats, len(centroids)),
+    };
+};
+
+func (this *Classifier) GetCentroids() [][]float64 {
+    return this.centroids;
+};
+
+// Label vectors by distance rather than by Euclidean distance, which nil
+// restores. Set it before observing any vectors, as the cluster statistics
+// hold distances in whatever metric was in use.
+func (this *Classifier) SetDistance(distance types.Distance) {
+    this.distance = distance;
+};
+
+func (this *Classifier) GetDistance() types.Distance {
+    if this.distance == nil {
+        return utils.Distance;
+    }
+    return this.distance;
+};
+
+// Build a k-d tree over the centroids so Predict avoids scanning every
+// centroid, which pays off once there are thousands of them.
+// Predict gives the same answers with or without the index. The tree only
+// prunes correctly for Euclidean distance, so with another distance set
+// Predict scans every centroid whether or not it is built.
+func (this *Classifier) BuildIndex() {
+    this.index = newKDTree(this.centroids);
+};
+
+func (this *Classifier) HasIndex() bool {
+    return this.index != nil;
+};
+
+// The index of the centroid nearest to vec, or -1 if there are no centroids.
+// Ties go to the lowest index, as in utils.FindNearest.
+func (this *Classifier) Predict(vec []float64) int {
+    label, _ := this.PredictWithDistance(vec);
+    return label;
+};
+
+// Predict along with the distance from vec to the chosen centroid, so callers
+// can reject assignments that are too far away. The distance is +Inf when
+// there are no centroids.
+func (this *Classifier) PredictWithDistance(vec []float64) (int, float64) {
+    if len(this.centroids) == 0 {
+        return -1, math.Inf(1);
+    }
+    if this.index != nil && this.distance == nil {
+        return this.index.nearest(vec);
+    }
+    distance := this.GetDistance();
+    label := utils.FindNearest(vec, this.centroids, distance);
+    return label, distance(vec, this.centroids[label]);
+};
+
+// PredictWithDistance for every row of data, in order.
+func (this *Classifier) PredictAllWithDistance(data [][]float64) ([]int, []float64) {
+    labels := make([]int, len(data));
+    distances := make([]float64, len(data));
+    for i, vec := range data {
+        labels[i], distances[i] = this.PredictWithDistance(vec);
+    }
+    return labels, distances;
+};
+
+// Assign vec to its nearest centroid and add its distance to that cluster's
+// statistics. Returns the cluster, or -1 if there are no centroids.
+func (this *Classifier) Observe(vec []float64) int {
+    label, distance := this.PredictWithDistance(vec);
+    if label >= 0 {
+        this.stats[label].Add(distance);
+    }
+    return label;
+};
+
+// Observe every vector of vecs, resetting the iterator before and after.
+func (this *Classifier) ObserveIterator(vecs types.Iterator) {
+    vecs.Reset();
+    for vecs.HasNext() {
+        this.Observe(vecs.Next());
+    }
+    vecs.Reset();
+};
+
+// The distance statistics of each cluster, in centroid order.
+func (this *Classifier) GetClusterStats() []DistanceStats {
+    return this.stats;
+};
+
+// How anomalous vec is for its nearest cluster: the z-score of its distance
+// to the centroid against the distances observed for that cluster.
+// NaN if there are no centroids or nothing was observed for the cluster.
+func (this *Classifier) AnomalyScore(vec []float64) float64 {
+    label, distance := this.PredictWithDistance(vec);
+    if label < 0 || this.stats[label].Count == 0 {
+        return math.NaN();
+    }
+    return this.stats[label].ZScore(distance);
+};
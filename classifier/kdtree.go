@@ -0,0 +1,110 @@
+package classifier;
+
+import (
+    "math"
+    "sort"
+    "github.com/wenkesj/rphash/utils"
+);
+
+// A node splits its points on one axis at the point it holds.
+type kdNode struct {
+    point int;
+    axis int;
+    left *kdNode;
+    right *kdNode;
+};
+
+type kdTree struct {
+    points [][]float64;
+    root *kdNode;
+};
+
+// Sorts point indices by one coordinate.
+type byAxis struct {
+    indices []int;
+    points [][]float64;
+    axis int;
+};
+
+func (this byAxis) Len() int {
+    return len(this.indices);
+};
+
+func (this byAxis) Less(i, j int) bool {
+    return this.points[this.indices[i]][this.axis] < this.points[this.indices[j]][this.axis];
+};
+
+func (this byAxis) Swap(i, j int) {
+    this.indices[i], this.indices[j] = this.indices[j], this.indices[i];
+};
+
+func newKDTree(points [][]float64) *kdTree {
+    indices := make([]int, len(points));
+    for i := range indices {
+        indices[i] = i;
+    }
+    tree := &kdTree{
+        points: points,
+    };
+    tree.root = tree.build(indices);
+    return tree;
+};
+
+// Split on the axis with the widest spread, at the median point.
+func (this *kdTree) build(indices []int) *kdNode {
+    if len(indices) == 0 {
+        return nil;
+    }
+    axis := this.widestAxis(indices);
+    sort.Sort(byAxis{indices, this.points, axis});
+    median := len(indices) / 2;
+    return &kdNode{
+        point: indices[median],
+        axis: axis,
+        left: this.build(indices[:median]),
+        right: this.build(indices[median + 1:]),
+    };
+};
+
+func (this *kdTree) widestAxis(indices []int) int {
+    axis, widest := 0, math.Inf(-1);
+    for d := 0; d < len(this.points[indices[0]]); d++ {
+        low, high := math.Inf(1), math.Inf(-1);
+        for _, i := range indices {
+            low = math.Min(low, this.points[i][d]);
+            high = math.Max(high, this.points[i][d]);
+        }
+        if high - low > widest {
+            axis, widest = d, high - low;
+        }
+    }
+    return axis;
+};
+
+func (this *kdTree) nearest(vec []float64) (int, float64) {
+    best, bestDistance := -1, math.Inf(1);
+    this.search(this.root, vec, &best, &bestDistance);
+    return best, bestDistance;
+};
+
+// A subtree is skipped only when the splitting plane is strictly farther
+// than the best distance, so equally near points are still compared and the
+// lowest index wins the tie.
+func (this *kdTree) search(node *kdNode, vec []float64, best *int, bestDistance *float64) {
+    if node == nil {
+        return;
+    }
+    distance := utils.Distance(vec, this.points[node.point]);
+    if distance < *bestDistance || (distance == *bestDistance && node.point < *best) {
+        *best, *bestDistance = node.point, distance;
+    }
+    offset := vec[node.axis] - this.points[node.point][node.axis];
+    near, far := node.left, node.right;
+    if offset > 0 {
+        near, far = node.right, node.left;
+    }
+    this.search(near, vec, best, bestDistance);
+    if math.Abs(offset) <= *bestDistance {
+        this.search(far, vec, best, bestDistance);
+    }
+};
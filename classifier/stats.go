@@ -0,0 +1,43 @@
+package classifier;
+
+import (
+    "math"
+);
+
+// DistanceStats keeps the running mean and variance of the distances from a
+// cluster's members to its centroid, using Welford's update so the distances
+// themselves are never stored.
+type DistanceStats struct {
+    Count int64;
+    Mean float64;
+    M2 float64;
+};
+
+func (this *DistanceStats) Add(distance float64) {
+    this.Count++;
+    delta := distance - this.Mean;
+    this.Mean += delta / float64(this.Count);
+    this.M2 += delta * (distance - this.Mean);
+};
+
+// The population standard deviation of the distances seen so far.
+func (this *DistanceStats) Std() float64 {
+    if this.Count < 1 {
+        return 0;
+    }
+    return math.Sqrt(this.M2 / float64(this.Count));
+};
+
+// How many standard deviations distance lies beyond the mean.
+// With no spread, a distance equal to the mean scores 0 and any other
+// distance scores an infinity of the matching sign.
+func (this *DistanceStats) ZScore(distance float64) float64 {
+    std := this.Std();
+    if std == 0 {
+        if distance == this.Mean {
+            return 0;
+        }
+        return math.Inf(int(math.Copysign(1, distance - this.Mean)));
+    }
+    return (distance - this.Mean) / std;
+};
@@ -0,0 +1,81 @@
+package clusterer;
+
+import (
+    "github.com/wenkesj/rphash/types"
+    "github.com/wenkesj/rphash/utils"
+);
+
+// Dissolve every cluster holding fewer than minSize vectors and give its
+// vectors to the nearest surviving centroid, which is then recomputed as the
+// mean of everything now assigned to it. Centroids that gain nothing are left
+// as they were. The result has fewer centroids than the input whenever a
+// cluster is dissolved; if every cluster is too small the largest is kept.
+// The vectors are read twice and the iterator is reset after each pass.
+// Vectors go to the centroid nearest by Euclidean distance, see
+// DissolveSmallClustersWithDistance.
+func DissolveSmallClusters(vecs types.Iterator, centroids [][]float64, minSize int) [][]float64 {
+    return DissolveSmallClustersWithDistance(vecs, centroids, minSize, nil);
+};
+
+// DissolveSmallClusters with every vector going to the centroid nearest by
+// distance, or by Euclidean distance if it is nil. The recomputed centroids
+// are still plain means, as in KMeans.
+func DissolveSmallClustersWithDistance(vecs types.Iterator, centroids [][]float64, minSize int, distance types.Distance) [][]float64 {
+    if distance == nil {
+        distance = utils.Distance;
+    }
+    if minSize < 2 || len(centroids) < 2 {
+        return centroids;
+    }
+    sizes := make([]int, len(centroids));
+    vecs.Reset();
+    for vecs.HasNext() {
+        sizes[utils.FindNearest(vecs.Next(), centroids, distance)]++;
+    }
+    vecs.Reset();
+
+    var survivors [][]float64;
+    largest := 0;
+    for i, size := range sizes {
+        if size >= minSize {
+            survivors = append(survivors, centroids[i]);
+        }
+        if size > sizes[largest] {
+            largest = i;
+        }
+    }
+    if len(survivors) == len(centroids) {
+        return centroids;
+    }
+    if len(survivors) == 0 {
+        return [][]float64{centroids[largest]};
+    }
+
+    sums := make([][]float64, len(survivors));
+    for i := range sums {
+        sums[i] = make([]float64, len(survivors[i]));
+    }
+    counts := make([]int, len(survivors));
+    affected := make([]bool, len(survivors));
+    for vecs.HasNext() {
+        vec := vecs.Next();
+        nearest := utils.FindNearest(vec, survivors, distance);
+        if sizes[utils.FindNearest(vec, centroids, distance)] < minSize {
+            affected[nearest] = true;
+        }
+        counts[nearest]++;
+        for j, x := range vec {
+            sums[nearest][j] += x;
+        }
+    }
+    vecs.Reset();
+
+    result := make([][]float64, len(survivors));
+    for i, centroid := range survivors {
+        result[i] = centroid;
+        if affected[i] && counts[i] > 0 {
+            result[i] = utils.Scale(sums[i], 1 / float64(counts[i]));
+        }
+    }
+    return result;
+};
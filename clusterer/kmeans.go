@@ -3,21 +3,35 @@ package clusterer;
 import (
     "fmt"
     "log"
-    "math/rand"
     "github.com/wenkesj/rphash/reader"
     "github.com/wenkesj/rphash/utils"
     "github.com/wenkesj/rphash/projector"
     "github.com/wenkesj/rphash/types"
 );
 
+const (
+    // The default cap on refinement passes. It is a guard against oscillating
+    // assignments rather than a budget most runs come close to.
+    DefaultMaxIterations = 10000;
+    // The default centroid tolerance. Zero disables the movement check so that
+    // only the swap count ends the refinement.
+    DefaultTolerance = 0.0;
+);
+
 type KMeans struct {
     k int;
     n int;
+    maxIterations int;
+    tolerance float64;
     data [][]float64;
     projectionDimension int;
+    projectionSeed int64;
     means [][]float64;
     clusters [][]int; //Each row of clusters contatins all vectors in the data currently assigned to it.
     weights []int64;
+    initialMeans [][]float64;
+    distance types.Distance;
+    logger types.Logger;
 };
 
 func NewKMeansStream(k int, data [][]float64, weights []int64) *KMeans{
@@ -26,10 +40,14 @@ func NewKMeansStream(k int, data [][]float64, weights []int64) *KMeans{
     }
     return &KMeans{
         k: k,
+        maxIterations: DefaultMaxIterations,
+        tolerance: DefaultTolerance,
         data: data,
         projectionDimension: 0,
         clusters: nil,
         weights: weights, //Weight for each vector in the data when finding means
+        distance: utils.Distance,
+        logger: utils.NewNopLogger(),
     };
 };
 
@@ -43,13 +61,89 @@ func NewKMeansSimple(k int, data [][]float64) *KMeans{
     }
     return &KMeans{
         k: k,
+        maxIterations: DefaultMaxIterations,
+        tolerance: DefaultTolerance,
         data: data,
         projectionDimension: 0,
         clusters: nil,
         weights: weights,
+        distance: utils.Distance,
+        logger: utils.NewNopLogger(),
     };
 };
 
+// Start the refinement from these means instead of evenly spaced vectors of
+// the data, so mean i of the result is the one that grew out of means[i].
+// They are ignored unless there are exactly k of them.
+func (this *KMeans) SetInitialMeans(means [][]float64) {
+    this.initialMeans = means;
+};
+
+func (this *KMeans) GetInitialMeans() [][]float64 {
+    return this.initialMeans;
+};
+
+// Assign vectors to means in a random projection of the data down to
+// dimension instead of in the data's own space, which makes each pass cheaper
+// for high dimensional data at the cost of assignments that only approximate
+// the true distances. The final means are still computed from the original
+// vectors, so they are in the data's units. 0 (the default) disables it.
+func (this *KMeans) SetProjection(dimension int, seed int64) {
+    this.projectionDimension = dimension;
+    this.projectionSeed = seed;
+};
+
+func (this *KMeans) GetProjectionDimension() int {
+    return this.projectionDimension;
+};
+
+// Assign vectors to the mean nearest by distance rather than by Euclidean
+// distance, which nil restores. Whatever the metric, each mean is the
+// weighted average of its vectors and is not renormalized, so the means stay
+// in the data's units. That suits Cosine too: for vectors of equal length the
+// average points the way that minimizes the summed cosine distance, and
+// cosine ignores the average's own length. Vectors of very different lengths
+// pull the average toward the longer ones, so normalize them first if only
+// their direction should count. The tolerance is always measured in
+// Euclidean distance.
+func (this *KMeans) SetDistance(distance types.Distance) {
+    if distance == nil {
+        distance = utils.Distance;
+    }
+    this.distance = distance;
+};
+
+func (this *KMeans) GetDistance() types.Distance {
+    return this.distance;
+};
+
+// Warn logger when Run stops at the maximum number of passes before the
+// assignments settle. Passing nil silences it again.
+func (this *KMeans) SetLogger(logger types.Logger) {
+    if logger == nil {
+        logger = utils.NewNopLogger();
+    }
+    this.logger = logger;
+};
+
+// Set the maximum number of update/assign passes Run will make.
+func (this *KMeans) SetMaxIterations(maxIterations int) {
+    this.maxIterations = maxIterations;
+};
+
+func (this *KMeans) GetMaxIterations() int {
+    return this.maxIterations;
+};
+
+// Set the distance every mean must move less than for Run to stop early.
+func (this *KMeans) SetTolerance(tolerance float64) {
+    this.tolerance = tolerance;
+};
+
+func (this *KMeans) GetTolerance() float64 {
+    return this.tolerance;
+};
+
 //Vectors is a list of all assignedVectors currently assigned to the centriod we are computing
 func (this *KMeans) ComputeCentroid(assignedVectors []int, data [][]float64) []float64 {
     d := len(data[0]);
@@ -71,10 +165,20 @@ func (this *KMeans) ComputeCentroid(assignedVectors []int, data [][]float64) []f
     return centroid;
 };
 
-func (this *KMeans) UpdateMeans(data [][]float64) {
+// Update the means and return the largest distance any of them moved.
+func (this *KMeans) UpdateMeans(data [][]float64) float64 {
+    var shift, moved float64;
     for i := 0; i < this.k; i++ {
-        this.means[i] = this.ComputeCentroid(this.clusters[i], data);
+        mean := this.ComputeCentroid(this.clusters[i], data);
+        if this.means[i] != nil && len(this.means[i]) == len(mean) {
+            moved = utils.Distance(this.means[i], mean);
+            if moved > shift {
+                shift = moved;
+            }
+        }
+        this.means[i] = mean;
     }
+    return shift;
 };
 
 func (this *KMeans) AssignClusters(data [][]float64) int {
@@ -86,7 +190,7 @@ func (this *KMeans) AssignClusters(data [][]float64) int {
     }
     for clusterid := 0; clusterid < this.k; clusterid++ {
         for _, member := range this.clusters[clusterid] {
-            nearest := utils.FindNearestDistance(data[member], this.means);
+            nearest := utils.FindNearest(data[member], this.means, this.distance);
             newClusters[nearest] = append(newClusters[nearest], member);
             if nearest != clusterid {
                 swaps++;
@@ -97,15 +201,19 @@ func (this *KMeans) AssignClusters(data [][]float64) int {
     return swaps;
 };
 
-func (this *KMeans) Run() {
+func (this *KMeans) Run() error {
+    if this.k < 1 || len(this.data) < this.k {
+        return fmt.Errorf("kmeans: cannot find %d means in %d vectors", this.k, len(this.data));
+    }
     //This is a condition to avoid infinite Run..
-    maxiters := 10000;
+    maxiters := this.maxIterations;
     swaps := 3;
+    shift := this.tolerance + 1;
     fulldata := this.data;
     data := make([][]float64, 0);
     var p types.Projector = nil;
     if this.projectionDimension != 0 {
-        p = projector.NewDBFriendly(len(fulldata[0]), this.projectionDimension, rand.Int63());
+        p = projector.NewDBFriendly(len(fulldata[0]), this.projectionDimension, this.projectionSeed);
     }
     for _, v := range fulldata {
         if p != nil {
@@ -129,21 +237,33 @@ func (this *KMeans) Run() {
         }
         this.clusters[i] = cluster;
     }
-    for swaps > 2 && maxiters > 0 {
+    if len(this.initialMeans) == this.k {
+        for i, mean := range this.initialMeans {
+            if p != nil {
+                mean = p.Project(mean);
+            }
+            this.means[i] = append([]float64{}, mean...);
+        }
+        this.AssignClusters(data);
+    }
+    for swaps > 2 && shift > this.tolerance && maxiters > 0 {
         maxiters--;
-        this.UpdateMeans(data);
+        shift = this.UpdateMeans(data);
         swaps = this.AssignClusters(data);
     }
-    if maxiters == 0 {
-        fmt.Println("Warning: Max Iterations Reached");
+    if maxiters == 0 && swaps > 2 {
+        this.logger.Warnf("kmeans: stopped after the maximum of %d iterations with %d assignments still changing", this.maxIterations, swaps);
     }
     data = fulldata;
     this.UpdateMeans(data);
+    return nil;
 };
 
 func (this *KMeans) GetCentroids() [][]float64 {
     if this.means == nil {
-        this.Run();
+        if err := this.Run(); err != nil {
+            return nil;
+        }
     }
     return this.means;
 };
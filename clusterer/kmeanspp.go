@@ -0,0 +1,66 @@
+package clusterer;
+
+import (
+    "math/rand"
+    "github.com/wenkesj/rphash/types"
+);
+
+// Choose k starting means from data the k-means++ way: the first uniformly at
+// random, then each next one with probability proportional to its squared
+// distance from the nearest mean chosen so far, measured by distance, or by
+// Euclidean distance if it is nil. Spreading the means over the
+// data like this makes the refinement both better and more repeatable than
+// starting from arbitrary vectors. The same seed always gives the same means.
+// Returns nil if data holds fewer than k vectors.
+func PlusPlusMeans(k int, data [][]float64, seed int64, distance types.Distance) [][]float64 {
+    if k < 1 || len(data) < k {
+        return nil;
+    }
+    random := rand.New(rand.NewSource(seed));
+    means := make([][]float64, 0, k);
+    means = append(means, append([]float64{}, data[random.Intn(len(data))]...));
+    // The squared distance from each vector to its nearest chosen mean.
+    nearest := make([]float64, len(data));
+    for i, vec := range data {
+        nearest[i] = squaredDistance(vec, means[0], distance);
+    }
+    for len(means) < k {
+        var total float64;
+        for _, d := range nearest {
+            total += d;
+        }
+        next := 0;
+        if total > 0 {
+            target := random.Float64() * total;
+            for next = 0; next < len(data) - 1; next++ {
+                target -= nearest[next];
+                if target < 0 {
+                    break;
+                }
+            }
+        } else {
+            // Every vector sits on a mean already, so any will do.
+            next = random.Intn(len(data));
+        }
+        mean := append([]float64{}, data[next]...);
+        means = append(means, mean);
+        for i, vec := range data {
+            if d := squaredDistance(vec, mean, distance); d < nearest[i] {
+                nearest[i] = d;
+            }
+        }
+    }
+    return means;
+};
+
+func squaredDistance(x, y []float64, distance types.Distance) float64 {
+    if distance != nil {
+        d := distance(x, y);
+        return d * d;
+    }
+    var sum float64;
+    for i := range x {
+        sum += (x[i] - y[i]) * (x[i] - y[i]);
+    }
+    return sum;
+};
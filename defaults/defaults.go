@@ -1,6 +1,8 @@
 package defaults;
 
 import (
+    "errors"
+    "github.com/wenkesj/rphash/assigner"
     "github.com/wenkesj/rphash/clusterer"
     "github.com/wenkesj/rphash/types"
     "github.com/wenkesj/rphash/decoder"
@@ -24,6 +26,14 @@ func NewProjector(n, t int, randomseed int64) types.Projector {
     return projector.NewDBFriendly(n, t, randomseed);
 };
 
+func NewGaussianProjector(n, t int, randomseed int64) types.Projector {
+    return projector.NewGaussian(n, t, randomseed);
+};
+
+func NewLogger() types.Logger {
+    return utils.NewNopLogger();
+};
+
 func NewHash(hashMod int64) types.Hash {
     return hash.NewMurmur(hashMod);
 };
@@ -36,6 +46,77 @@ func NewKMeansSimple(k int, centroids [][]float64) types.Clusterer {
     return clusterer.NewKMeansSimple(k, centroids);
 };
 
+// KMeans refinement defaults used by Simple when nothing else is set.
+const (
+    KMeansIterations = clusterer.DefaultMaxIterations;
+    KMeansTolerance = clusterer.DefaultTolerance;
+);
+
+// KMeans assigning by distance, or by Euclidean distance if it is nil.
+func NewKMeans(k int, centroids [][]float64, maxIterations int, tolerance float64, distance types.Distance) types.Clusterer {
+    kmeans := clusterer.NewKMeansSimple(k, centroids);
+    kmeans.SetMaxIterations(maxIterations);
+    kmeans.SetTolerance(tolerance);
+    kmeans.SetDistance(distance);
+    return kmeans;
+};
+
+// NewKMeans starting from the given means rather than from the data, and
+// assigning in a projection of the data if projectionDimension is not 0.
+func NewKMeansSeeded(k int, centroids, means [][]float64, maxIterations int, tolerance float64, projectionDimension int, projectionSeed int64, distance types.Distance) types.Clusterer {
+    kmeans := clusterer.NewKMeansSimple(k, centroids);
+    kmeans.SetMaxIterations(maxIterations);
+    kmeans.SetTolerance(tolerance);
+    kmeans.SetInitialMeans(means);
+    kmeans.SetProjection(projectionDimension, projectionSeed);
+    kmeans.SetDistance(distance);
+    return kmeans;
+};
+
+// The distances KMeans and Simple can assign by. Euclidean is the default.
+// SquaredEuclidean ranks vectors the same way a little faster, and Cosine
+// compares direction only, which often suits the normalized features parse
+// produces. See clusterer.KMeans.SetDistance for how means behave under each.
+func Euclidean(a, b []float64) float64 {
+    return utils.Distance(a, b);
+};
+
+func SquaredEuclidean(a, b []float64) float64 {
+    return utils.SquaredDistance(a, b);
+};
+
+func Cosine(a, b []float64) float64 {
+    return utils.CosineDistance(a, b);
+};
+
+// KMeans over data by distance, or by Euclidean distance if it is nil,
+// starting from k-means++ means drawn with seed, see PlusPlusMeans.
+func NewKMeansPP(k int, data [][]float64, seed int64, distance types.Distance) types.Clusterer {
+    kmeans := clusterer.NewKMeansSimple(k, data);
+    kmeans.SetInitialMeans(clusterer.PlusPlusMeans(k, data, seed, distance));
+    kmeans.SetDistance(distance);
+    return kmeans;
+};
+
+// Choose k starting means from data the k-means++ way, weighted by distance,
+// so the refinement does not depend on the order of the data.
+func PlusPlusMeans(k int, data [][]float64, seed int64, distance types.Distance) [][]float64 {
+    return clusterer.PlusPlusMeans(k, data, seed, distance);
+};
+
+// The tolerance CanonicalizeClustering rounds centroids to.
+const CanonicalTolerance = 1e-6;
+
+// Put a clustering in a canonical form so tests can compare two runs for
+// equality regardless of centroid order.
+func CanonicalizeClustering(centroids [][]float64) [][]float64 {
+    return utils.CanonicalCentroids(centroids, CanonicalTolerance);
+};
+
+func DissolveSmallClusters(vecs types.Iterator, centroids [][]float64, minSize int, distance types.Distance) [][]float64 {
+    return clusterer.DissolveSmallClustersWithDistance(vecs, centroids, minSize, distance);
+};
+
 func NewCentroidStream(vec []float64) types.Centroid {
     return itemset.NewCentroidStream(vec);
 };
@@ -48,14 +129,41 @@ func NewCountMinSketch(k int) types.CountItemSet {
     return itemset.NewKHHCountMinSketch(k);
 };
 
+func NewCountMinSketchWithCapacity(capacity int) types.CountItemSet {
+    return itemset.NewKHHCountMinSketchWithCapacity(capacity);
+};
+
+func NewCountMinSketchSeeded(capacity int, seed int64) types.CountItemSet {
+    return itemset.NewKHHCountMinSketchSeeded(capacity, seed);
+};
+
+// Fold the counts of from into into, see itemset.KHHCountMinSketch.Merge.
+// Both must be count-min sketches made with the same seed.
+func MergeCountMinSketches(into, from types.CountItemSet) error {
+    first, ok := into.(*itemset.KHHCountMinSketch);
+    second, otherOk := from.(*itemset.KHHCountMinSketch);
+    if !ok || !otherOk {
+        return errors.New("Only count-min sketches can be merged.");
+    }
+    return first.Merge(second);
+};
+
 func NewCentroidCounter(k int) types.CentroidItemSet {
     return itemset.NewKHHCentroidCounter(k);
 };
 
+func NewAssigner() types.Assigner {
+    return assigner.NewBucket();
+};
+
 func NewLSH(hash types.Hash, decoder types.Decoder, projector types.Projector) types.LSH {
     return lsh.NewLSH(hash, decoder, projector);
 };
 
+func HashVector(projected []float64, decoder types.Decoder, hash types.Hash) int64 {
+    return lsh.HashVector(projected, decoder, hash);
+};
+
 func NewStatTest(vari float64) types.StatTest {
     return utils.NewStatTest(vari);
 };
@@ -17,16 +17,25 @@ const (
 func main() {
   parser := parse.NewParser();
   bytes, _ := ioutil.ReadFile(exampleInputFileName);
-  jsonData := parser.BytesToJSON(bytes);
-  data := parser.JSONToFloat64Matrix(exampleDataLabel, jsonData);
+  jsonData, err := parser.BytesToJSON(bytes);
+  if err != nil {
+    panic(err);
+  }
+  data, err := parser.JSONToFloat64Matrix(exampleDataLabel, jsonData);
+  if err != nil {
+    panic(err);
+  }
   cluster := api.NewRPHash(data, numberOfClusters);
 
   topCentroids := cluster.GetCentroids();
 
   jsonCentroids := parser.Float64MatrixToJSON(exampleDataLabel, topCentroids);
 
-  jsonBytes := parser.JSONToBytes(jsonCentroids);
-  err := ioutil.WriteFile(exampleOutputFileName, jsonBytes, 0644);
+  jsonBytes, err := parser.JSONToBytes(jsonCentroids);
+  if err != nil {
+    panic(err);
+  }
+  err = ioutil.WriteFile(exampleOutputFileName, jsonBytes, 0644);
   if err != nil {
     panic(err);
   }
@@ -8,6 +8,7 @@ import (
 type Centroid struct {
     vec []float64;
     count int64;
+    weight float64;
     ids types.HashSet;
     id int64;
 };
@@ -17,6 +18,7 @@ func NewCentroidStream(data []float64) *Centroid {
         vec: data,
         ids: utils.NewHash64Set(),
         count: 1,
+        weight: 1,
         id: 0,
     };
 };
@@ -29,17 +31,27 @@ func NewCentroidSimple(dim int, lsh int64) *Centroid {
         vec: data,
         ids: ids,
         count: 0,
+        weight: 0,
         id: lsh,
     };
 };
 
 func (this *Centroid) UpdateCentroidVector(data []float64) {
+    this.UpdateWeightedVector(data, 1);
+};
+
+// Fold a vector into the running mean as if it appeared weight times.
+func (this *Centroid) UpdateWeightedVector(data []float64, weight float64) {
     var delta, x float64;
+    if weight <= 0 {
+        return;
+    }
     this.count++;
+    this.weight += weight;
     for i := 0; i < len(data); i++ {
         x = data[i];
         delta = x - this.vec[i];
-        this.vec[i] = this.vec[i] + delta / float64(this.count);
+        this.vec[i] = this.vec[i] + delta * weight / this.weight;
     }
 };
 
@@ -56,6 +68,11 @@ func (this *Centroid) GetCount() int64 {
     return this.count;
 };
 
+// The total weight of the vectors in the centroid.
+func (this *Centroid) GetWeight() float64 {
+    return this.weight;
+};
+
 // ID Represents the LSH of the center vector of the centroid.
 func (this *Centroid) GetID() int64 {
     return this.id;
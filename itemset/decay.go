@@ -0,0 +1,100 @@
+package itemset;
+
+import (
+    "math"
+    "time"
+);
+
+// Decaying sketches count in fixed point with this many units to an item, so
+// that decayed counts keep their fractions.
+const decayUnit = 1 << 16;
+
+// A sketch of m ln m heavy hitters whose counts halve every halfLife, so the
+// heavy hitters follow the items trending now rather than the all time
+// leaders. Decay is lazy: the counters an item hashes to are decayed for the
+// time since they were last touched when it is added, and the heavy hitters
+// are decayed together before every add and on GetTop, which costs
+// O(k log k) whenever the clock has moved. Counts read back are rounded to
+// whole items. A halfLife of zero or less gives a sketch that never decays.
+func NewDecayingKHHCountMinSketch(m int, halfLife time.Duration) *KHHCountMinSketch {
+    result := NewKHHCountMinSketch(m);
+    if halfLife <= 0 {
+        return result;
+    }
+    result.halfLife = halfLife;
+    result.unit = decayUnit;
+    result.touched = make([][]int64, result.depth);
+    for i := range result.touched {
+        result.touched[i] = make([]int64, result.width);
+    }
+    return result;
+};
+
+// Read the time from clock rather than time.Now when decaying. Time that runs
+// backwards decays nothing.
+func (this *KHHCountMinSketch) SetClock(clock func() time.Time) {
+    if clock == nil {
+        clock = time.Now;
+    }
+    this.clock = clock;
+};
+
+func (this *KHHCountMinSketch) GetHalfLife() time.Duration {
+    return this.halfLife;
+};
+
+// The fraction of a count left after elapsed nanoseconds.
+func (this *KHHCountMinSketch) decayFactor(elapsed int64) float64 {
+    return math.Pow(0.5, float64(elapsed) / float64(this.halfLife));
+};
+
+// Counter j of row i as of now, without storing it.
+func (this *KHHCountMinSketch) decayed(i, j int, now int64) int64 {
+    count := this.sketchTable[i][j];
+    if this.halfLife <= 0 || count == 0 {
+        return count;
+    }
+    elapsed := now - this.touched[i][j];
+    if elapsed <= 0 {
+        return count;
+    }
+    return int64(float64(count) * this.decayFactor(elapsed) + 0.5);
+};
+
+// Bring the heavy hitters and the counters of item up to the current time.
+func (this *KHHCountMinSketch) decayItem(item int64) {
+    now := this.clock().UnixNano();
+    this.decayTop(now);
+    for i := 0; i < this.depth; i++ {
+        j := this.Hash(item, i);
+        this.sketchTable[i][j] = this.decayed(i, j, now);
+        this.touched[i][j] = now;
+    }
+};
+
+// Scale every heavy hitter's count by the decay since the last call. All
+// decay by the same factor, so they are requeued in the order they leave.
+func (this *KHHCountMinSketch) decayTop(now int64) {
+    elapsed := now - this.lastDecay;
+    if elapsed <= 0 {
+        return;
+    }
+    this.lastDecay = now;
+    if this.priorityQueue.IsEmpty() {
+        return;
+    }
+    factor := this.decayFactor(elapsed);
+    var items, priorities []int64;
+    for !this.priorityQueue.IsEmpty() {
+        priorities = append(priorities, this.priorityQueue.PeakMinPriority());
+        items = append(items, this.priorityQueue.Poll());
+    }
+    for i, item := range items {
+        this.priorityQueue.Enqueue(item, int64(float64(priorities[i]) * factor + 0.5));
+    }
+};
+
+// A count in table units rounded to whole items.
+func (this *KHHCountMinSketch) toCount(raw int64) int64 {
+    return (raw + this.unit / 2) / this.unit;
+};
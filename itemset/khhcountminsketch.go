@@ -1,72 +1,311 @@
 package itemset;
 
 import (
+    "encoding/binary"
+    "io"
     "math"
     "math/rand"
     "time"
+    "github.com/wenkesj/rphash/types"
     "github.com/wenkesj/rphash/utils"
 );
 
 type KHHCountMinSketch struct {
     depth int;
     width int;
-    sketchTable [depth][width]int64;
+    sketchTable [][]int64;
     hashVector []int64;
     size int64;
     priorityQueue *utils.Int64PriorityQueue;
     k int;
-    items map[int64]int64;
+    // The tracked heavy hitters, keyed by the item itself.
+    items map[int64]bool;
     count int64;
     counts []int64;
     topCentroid []int64;
+    bounded bool;
+    spill io.Writer;
+    spillErr error;
+    logger types.Logger;
+    saturated bool;
+    // Decay, see NewDecayingKHHCountMinSketch. Counters hold multiples of
+    // unit, and touched and lastDecay are the times in nanoseconds that each
+    // counter and the heavy hitters were last decayed.
+    halfLife time.Duration;
+    clock func() time.Time;
+    unit int64;
+    touched [][]int64;
+    lastDecay int64;
 };
 
+// Rough per item costs used by MemoryEstimate: a queue entry is an item and
+// its priority, a map entry is a key, a value and the map's own overhead.
+const (
+    queueEntryBytes = 16;
+    mapEntryBytes = 48;
+);
+
+// The error bounds giving the default table of depth rows of width counters.
+var (
+    defaultEpsilon = math.E / width;
+    defaultDelta = math.Exp(-depth);
+);
+
 func NewKHHCountMinSketch(m int) *KHHCountMinSketch {
+    return NewKHHCountMinSketchWithParams(defaultEpsilon, defaultDelta, m);
+};
+
+// A sketch tracking m ln m heavy hitters whose estimates overcount an item by
+// at most epsilon times the total count with probability 1 - delta. The table
+// is ceil(e / epsilon) counters wide and ceil(ln(1 / delta)) rows deep. An
+// epsilon or delta outside (0, 1) falls back to the default.
+func NewKHHCountMinSketchWithParams(epsilon, delta float64, m int) *KHHCountMinSketch {
     k := int(float64(m) * math.Log(float64(m)));
-    seed := int64(time.Now().UnixNano() / int64(time.Millisecond));
-    items := make(map[int64]int64);
-    var sketchTable [depth][width]int64;
-    hashVector := make([]int64, depth);
+    // m ln(m) falls below m for m < 3, but callers rely on m items coming back.
+    if k < m {
+        k = m;
+    }
+    sketchDepth, sketchWidth := dimensions(epsilon, delta);
+    return newKHHCountMinSketch(k, sketchDepth, sketchWidth, timeSeed());
+};
+
+// A sketch that keeps exactly k heavy hitters rather than m ln m of them.
+func NewKHHCountMinSketchWithCapacity(k int) *KHHCountMinSketch {
+    return NewKHHCountMinSketchSeeded(k, timeSeed());
+};
+
+// A sketch of capacity k whose row hashes are drawn from seed, so the same
+// stream always gives the same estimates.
+func NewKHHCountMinSketchSeeded(k int, seed int64) *KHHCountMinSketch {
+    return newKHHCountMinSketch(k, depth, width, seed);
+};
+
+func timeSeed() int64 {
+    return int64(time.Now().UnixNano() / int64(time.Millisecond));
+};
+
+// The table depth and width needed for the error bounds epsilon and delta.
+func dimensions(epsilon, delta float64) (int, int) {
+    if !(epsilon > 0 && epsilon < 1) {
+        epsilon = defaultEpsilon;
+    }
+    if !(delta > 0 && delta < 1) {
+        delta = defaultDelta;
+    }
+    sketchDepth := int(math.Ceil(math.Log(1 / delta)));
+    sketchWidth := int(math.Ceil(math.E / epsilon));
+    return sketchDepth, sketchWidth;
+};
+
+func newKHHCountMinSketch(k, sketchDepth, sketchWidth int, seed int64) *KHHCountMinSketch {
+    items := make(map[int64]bool);
+    sketchTable := make([][]int64, sketchDepth);
+    for i := range sketchTable {
+        sketchTable[i] = make([]int64, sketchWidth);
+    }
+    hashVector := make([]int64, sketchDepth);
     random := rand.New(rand.NewSource(seed));
-    for i := 0; i < depth; i++ {
+    for i := 0; i < sketchDepth; i++ {
         hashVector[i] = random.Int63n(math.MaxInt64);
     }
     result := new(KHHCountMinSketch);
     result.k = k;
     result.items = items;
     result.sketchTable = sketchTable;
-    result.width = width;
-    result.depth = depth;
+    result.width = sketchWidth;
+    result.depth = sketchDepth;
     result.size = 0;
     result.hashVector = hashVector;
     result.priorityQueue = utils.NewInt64PriorityQueue();
     result.topCentroid = nil;
+    result.logger = utils.NewNopLogger();
+    result.clock = time.Now;
+    result.unit = 1;
     return result;
 };
 
+func (this *KHHCountMinSketch) GetDepth() int {
+    return this.depth;
+};
+
+func (this *KHHCountMinSketch) GetWidth() int {
+    return this.width;
+};
+
+// Rank the retained items with a comparator over (item, count) instead of the
+// count alone. The lowest ranked item is the one evicted once more than k are
+// held, and GetTop lists items from the lowest to the highest rank. By default
+// a larger count ranks higher.
+func (this *KHHCountMinSketch) SetComparator(comparator utils.Int64Comparator) {
+    this.priorityQueue.SetComparator(comparator);
+    this.topCentroid = nil;
+    this.counts = nil;
+};
+
+// Report when the heavy hitters first fill up and start evicting.
+func (this *KHHCountMinSketch) SetLogger(logger types.Logger) {
+    this.logger = logger;
+};
+
 func (this *KHHCountMinSketch) Hash(item int64, i int) int {
-    PRIME_MODULUS := int64(math.MaxInt64);
-    hash := this.hashVector[i] * item;
-    hash += hash >> 64;
+    PRIME_MODULUS := uint64(math.MaxInt64);
+    hash := uint64(this.hashVector[i] * item);
+    hash += hash >> 32;
     hash &= PRIME_MODULUS;
-    return int(hash) % this.width;
+    return int(hash % uint64(this.width));
 };
 
 func (this *KHHCountMinSketch) Add(e int64) {
-    var hashCode = utils.HashCode(e);
-    count := this.AddLong(hashCode, 1);
-    if this.items[hashCode] != 0 {
+    this.AddWeighted(e, 1);
+};
+
+// Add an item as if it had been seen count times.
+func (this *KHHCountMinSketch) AddWeighted(e int64, count int64) {
+    if count < 1 {
+        return;
+    }
+    estimate := this.AddLong(e, count);
+    this.updateTop(e, estimate);
+};
+
+// Requeue an item at its new estimate, evicting the lowest ranked item once
+// more than k are held.
+func (this *KHHCountMinSketch) updateTop(e, estimate int64) {
+    // The top GetTop listed may change with every add.
+    this.topCentroid = nil;
+    this.counts = nil;
+    tracked := this.items[e];
+    if !tracked && this.bounded && this.priorityQueue.Size() >= this.k && this.priorityQueue.RanksBelowMin(e, estimate) {
+        this.spillItem(e, estimate);
+        return;
+    }
+    if tracked {
       this.priorityQueue.Remove(e);
     }
-    this.items[hashCode] = e;
-    this.priorityQueue.Enqueue(e, count);
+    this.items[e] = true;
+    this.priorityQueue.Enqueue(e, estimate);
+    if !this.saturated && this.priorityQueue.Size() >= this.k {
+        this.saturated = true;
+        this.logger.Infof("itemset: count-min sketch saturated, tracking %d heavy hitters after %d items", this.k, this.size);
+    }
     if this.priorityQueue.Size() > this.k {
+        estimate := this.priorityQueue.PeakMinPriority();
         removed := this.priorityQueue.Poll();
         delete(this.items, removed);
+        this.spillItem(removed, estimate);
+    }
+};
+
+// Bounded mode holds at most maxTracked heavy hitters, fewer than the default
+// of m ln m if maxTracked is smaller, and never admits an item that would be
+// evicted straight away, so the queue and item map stop growing once full no
+// matter how many distinct items the stream holds. Together with the fixed
+// size table the sketch then uses constant memory, see MemoryEstimate.
+//
+// Counts are kept by the table, not the heavy hitters, so an item that is
+// evicted or refused loses nothing: it re-enters with its full estimate if it
+// later outranks the lowest tracked item. The cost of a smaller maxTracked is
+// recall, as fewer candidates are compared when GetTop is read, and items
+// whose counts are close to the cutoff may be dropped in favor of each other.
+// If spill is not nil every item leaving or refused by the heavy hitters is
+// written to it with its estimate at that time, see ReadSpill.
+func (this *KHHCountMinSketch) SetBounded(maxTracked int, spill io.Writer) {
+    this.bounded = true;
+    if maxTracked > 0 && maxTracked < this.k {
+        this.k = maxTracked;
+    }
+    this.spill = spill;
+    for this.priorityQueue.Size() > this.k {
+        estimate := this.priorityQueue.PeakMinPriority();
+        removed := this.priorityQueue.Poll();
+        delete(this.items, removed);
+        this.spillItem(removed, estimate);
+    }
+};
+
+// The first error writing to the spill, if any. Spilling stops after it.
+func (this *KHHCountMinSketch) SpillErr() error {
+    return this.spillErr;
+};
+
+func (this *KHHCountMinSketch) spillItem(e, estimate int64) {
+    if this.spill == nil || this.spillErr != nil {
+        return;
+    }
+    this.spillErr = binary.Write(this.spill, binary.LittleEndian, [2]int64{e, this.toCount(estimate)});
+};
+
+// Read back the items and estimates written to a spill, oldest first. An item
+// appears once for every time it left the heavy hitters.
+func ReadSpill(r io.Reader) ([]int64, []int64, error) {
+    var items, estimates []int64;
+    for {
+        var record [2]int64;
+        err := binary.Read(r, binary.LittleEndian, &record);
+        if err == io.EOF {
+            return items, estimates, nil;
+        }
+        if err != nil {
+            return items, estimates, err;
+        }
+        items = append(items, record[0]);
+        estimates = append(estimates, record[1]);
+    }
+};
+
+// An estimate in bytes of the memory the sketch holds once its heavy hitters
+// are full: the count table, its hash seeds and k queue and map entries, and
+// the time each counter was last decayed if it decays.
+// It is an upper bound in bounded mode. Without it the queue and map briefly
+// hold one more item on each insert, and Go maps keep their peak size.
+func (this *KHHCountMinSketch) MemoryEstimate() int64 {
+    table := int64(this.depth) * int64(this.width) * 8;
+    seeds := int64(len(this.hashVector)) * 8;
+    tracked := int64(this.k) * (queueEntryBytes + mapEntryBytes);
+    if this.touched != nil {
+        table *= 2;
+    }
+    return table + seeds + tracked;
+};
+
+// Add a pre-collected batch of items. The bucket of every item is hashed one
+// row at a time up front, then the items are counted in order so the table and
+// the heavy hitters end up exactly as if each had been passed to Add.
+func (this *KHHCountMinSketch) AddBatch(items []int64) {
+    if this.halfLife > 0 {
+        // Every add decays by the time it happens at, so there is nothing to
+        // hash ahead of time.
+        for _, e := range items {
+            this.Add(e);
+        }
+        return;
+    }
+    buckets := make([][]int, this.depth);
+    for i := 0; i < this.depth; i++ {
+        buckets[i] = make([]int, len(items));
+        for j, e := range items {
+            buckets[i][j] = this.Hash(e, i);
+        }
+    }
+    for j, e := range items {
+        this.sketchTable[0][buckets[0][j]]++;
+        estimate := this.sketchTable[0][buckets[0][j]];
+        for i := 1; i < this.depth; i++ {
+            this.sketchTable[i][buckets[i][j]]++;
+            if this.sketchTable[i][buckets[i][j]] < estimate {
+                estimate = this.sketchTable[i][buckets[i][j]];
+            }
+        }
+        this.size++;
+        this.updateTop(e, estimate);
     }
 };
 
 func (this *KHHCountMinSketch) AddLong(item, count int64) int64 {
+    if this.halfLife > 0 {
+        this.decayItem(item);
+    }
+    count *= this.unit;
     this.sketchTable[0][this.Hash(item, 0)] += count;
     min := int64(this.sketchTable[0][this.Hash(item, 0)]);
     for i := 1; i < this.depth; i++ {
@@ -75,7 +314,29 @@ func (this *KHHCountMinSketch) AddLong(item, count int64) int64 {
             min = int64(this.sketchTable[i][this.Hash(item, i)]);
         }
     }
-    this.size += count;
+    this.size += count / this.unit;
+    return min;
+};
+
+// The estimated number of times e has been added, whether or not it is a
+// heavy hitter: the smallest counter it hashes to. It never undercounts, and
+// an item never added estimates to zero unless it collides in every row.
+func (this *KHHCountMinSketch) EstimateCount(e int64) int64 {
+    return this.toCount(this.rawEstimate(e));
+};
+
+// The estimate of e in the units the table counts in.
+func (this *KHHCountMinSketch) rawEstimate(e int64) int64 {
+    var now int64;
+    if this.halfLife > 0 {
+        now = this.clock().UnixNano();
+    }
+    min := this.decayed(0, this.Hash(e, 0), now);
+    for i := 1; i < this.depth; i++ {
+        if count := this.decayed(i, this.Hash(e, i), now); count < min {
+            min = count;
+        }
+    }
     return min;
 };
 
@@ -91,15 +352,22 @@ func (this *KHHCountMinSketch) GetCounts() []int64 {
     return this.counts;
 };
 
+// The heavy hitters from the lowest to the highest rank. The list is kept
+// until the next add, and reading it leaves them tracked.
 func (this *KHHCountMinSketch) GetTop() []int64 {
     if this.topCentroid != nil {
         return this.topCentroid;
     }
+    if this.halfLife > 0 {
+        this.decayTop(this.clock().UnixNano());
+    }
+    // Poll a copy, so the heavy hitters keep being tracked by later adds.
+    queue := this.priorityQueue.Copy();
     this.topCentroid = []int64{};
     this.counts = []int64{};
-    for !this.priorityQueue.IsEmpty() {
-        this.counts = append(this.counts, this.priorityQueue.PeakMinPriority());
-        tmp := this.priorityQueue.Poll();
+    for !queue.IsEmpty() {
+        this.counts = append(this.counts, this.toCount(queue.PeakMinPriority()));
+        tmp := queue.Poll();
         this.topCentroid = append(this.topCentroid, tmp);
     }
     return this.topCentroid;
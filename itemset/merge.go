@@ -0,0 +1,61 @@
+package itemset;
+
+import (
+    "errors"
+    "sort"
+);
+
+type int64Slice []int64;
+
+func (this int64Slice) Len() int { return len(this); };
+func (this int64Slice) Less(i, j int) bool { return this[i] < this[j]; };
+func (this int64Slice) Swap(i, j int) { this[i], this[j] = this[j], this[i]; };
+
+// Fold the counts of other into this sketch, as if this had also seen the
+// stream other was built from. Both must have been made with the same table
+// size and hash seeds, or their counters would not describe the same items.
+// The heavy hitters are rebuilt from the items either one tracked, ranked by
+// their estimates in the merged table and cut back to this sketch's capacity.
+func (this *KHHCountMinSketch) Merge(other *KHHCountMinSketch) error {
+    if this.halfLife > 0 || other.halfLife > 0 {
+        return errors.New("Decaying count-min sketches cannot be merged.");
+    }
+    if this.depth != other.depth || this.width != other.width {
+        return errors.New("Count-min sketches with different table sizes cannot be merged.");
+    }
+    for i := range this.hashVector {
+        if this.hashVector[i] != other.hashVector[i] {
+            return errors.New("Count-min sketches with different hash seeds cannot be merged.");
+        }
+    }
+    for i := range this.sketchTable {
+        for j, count := range other.sketchTable[i] {
+            this.sketchTable[i][j] += count;
+        }
+    }
+    this.size += other.size;
+
+    candidates := make([]int64, 0, len(this.items) + len(other.items));
+    for item := range this.items {
+        candidates = append(candidates, item);
+    }
+    for item := range other.items {
+        if !this.items[item] {
+            candidates = append(candidates, item);
+        }
+    }
+    // Queue in a fixed order so items tied on their estimate rank the same
+    // way on every merge.
+    sort.Sort(int64Slice(candidates));
+
+    for !this.priorityQueue.IsEmpty() {
+        this.priorityQueue.Poll();
+    }
+    this.items = make(map[int64]bool);
+    this.topCentroid = nil;
+    this.counts = nil;
+    for _, item := range candidates {
+        this.updateTop(item, this.rawEstimate(item));
+    }
+    return nil;
+};
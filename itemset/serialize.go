@@ -0,0 +1,120 @@
+package itemset;
+
+import (
+    "bytes"
+    "encoding/gob"
+    "errors"
+    "time"
+    "github.com/wenkesj/rphash/utils"
+);
+
+// The stored form of a KHHCountMinSketch. The heavy hitters are kept in heap
+// order so the restored queue breaks ties exactly as the saved one did.
+type sketchState struct {
+    Depth int;
+    Width int;
+    HashVector []int64;
+    Table [][]int64;
+    Size int64;
+    K int;
+    Items []int64;
+    QueueItems []int64;
+    QueuePriorities []int64;
+    Top []int64;
+    Counts []int64;
+    Bounded bool;
+    Saturated bool;
+    HalfLife time.Duration;
+    Unit int64;
+    Touched [][]int64;
+    LastDecay int64;
+};
+
+// Checkpoint the sketch: its table, hash seeds, heavy hitters and decay. The
+// comparator, logger, spill and clock are not saved and have to be set again on the
+// restored sketch if they were changed from the defaults.
+func (this *KHHCountMinSketch) MarshalBinary() ([]byte, error) {
+    state := sketchState{
+        Depth: this.depth,
+        Width: this.width,
+        HashVector: this.hashVector,
+        Table: this.sketchTable,
+        Size: this.size,
+        K: this.k,
+        Top: this.topCentroid,
+        Counts: this.counts,
+        Bounded: this.bounded,
+        Saturated: this.saturated,
+        HalfLife: this.halfLife,
+        Unit: this.unit,
+        Touched: this.touched,
+        LastDecay: this.lastDecay,
+    };
+    for item := range this.items {
+        state.Items = append(state.Items, item);
+    }
+    state.QueueItems, state.QueuePriorities = this.priorityQueue.Entries();
+    var buffer bytes.Buffer;
+    if err := gob.NewEncoder(&buffer).Encode(state); err != nil {
+        return nil, err;
+    }
+    return buffer.Bytes(), nil;
+};
+
+// Restore a sketch written by MarshalBinary. Adds and GetTop carry on exactly
+// as they would have on the saved sketch.
+func (this *KHHCountMinSketch) UnmarshalBinary(data []byte) error {
+    var state sketchState;
+    if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&state); err != nil {
+        return err;
+    }
+    if state.Depth < 1 || state.Width < 1 || len(state.HashVector) != state.Depth || len(state.Table) != state.Depth {
+        return errors.New("Stored count-min sketch has a malformed table.");
+    }
+    for _, row := range state.Table {
+        if len(row) != state.Width {
+            return errors.New("Stored count-min sketch has a malformed table.");
+        }
+    }
+    if state.HalfLife > 0 && len(state.Touched) != state.Depth {
+        return errors.New("Stored count-min sketch has a malformed table.");
+    }
+    if len(state.QueueItems) != len(state.QueuePriorities) {
+        return errors.New("Stored count-min sketch has malformed heavy hitters.");
+    }
+
+    this.depth = state.Depth;
+    this.width = state.Width;
+    this.hashVector = state.HashVector;
+    this.sketchTable = state.Table;
+    this.size = state.Size;
+    this.k = state.K;
+    this.items = make(map[int64]bool);
+    for _, item := range state.Items {
+        this.items[item] = true;
+    }
+    this.priorityQueue = utils.NewInt64PriorityQueue();
+    for i, item := range state.QueueItems {
+        this.priorityQueue.Enqueue(item, state.QueuePriorities[i]);
+    }
+    this.topCentroid = state.Top;
+    this.counts = state.Counts;
+    this.bounded = state.Bounded;
+    this.saturated = state.Saturated;
+    this.halfLife = state.HalfLife;
+    this.unit = state.Unit;
+    if this.unit < 1 {
+        this.unit = 1;
+    }
+    this.touched = state.Touched;
+    this.lastDecay = state.LastDecay;
+    if this.clock == nil {
+        this.clock = time.Now;
+    }
+    this.spill = nil;
+    this.spillErr = nil;
+    if this.logger == nil {
+        this.logger = utils.NewNopLogger();
+    }
+    return nil;
+};
@@ -61,11 +61,16 @@ func (this *LSH) LSHHashStream(r []float64, times int) []int64 {
     return ret;
 };
 
+// Decode an already projected vector and hash the result, the last two steps
+// of LSHHashSimple without a projector.
+func HashVector(projected []float64, decoder types.Decoder, hash types.Hash) int64 {
+    decodedSpace := decoder.Decode(projected);
+    return hash.Hash(decodedSpace);
+};
+
 func (this *LSH) LSHHashSimple(r []float64) int64 {
     projectedSpace := this.projector.Project(r);
-    decodedSpace := this.decoder.Decode(projectedSpace);
-    hashedResult := this.hash.Hash(decodedSpace);
-    return hashedResult;
+    return HashVector(projectedSpace, this.decoder, this.hash);
 };
 
 func (this *LSH) Distance() float64 {
@@ -0,0 +1,174 @@
+package metrics;
+
+import (
+    "math"
+    "math/rand"
+    "github.com/wenkesj/rphash/types"
+    "github.com/wenkesj/rphash/utils"
+);
+
+// Assign every vector the index of its nearest centroid by Euclidean distance.
+func Assign(data [][]float64, centroids [][]float64) []int {
+    return AssignWithDistance(data, centroids, nil);
+};
+
+// Assign by distance, or by Euclidean distance if it is nil.
+func AssignWithDistance(data [][]float64, centroids [][]float64, distance types.Distance) []int {
+    if distance == nil {
+        distance = utils.Distance;
+    }
+    labels := make([]int, len(data));
+    for i, vec := range data {
+        labels[i] = utils.FindNearest(vec, centroids, distance);
+    }
+    return labels;
+};
+
+// Within-cluster sum of squares, the squared distance from every vector to its
+// nearest centroid summed over the data set. Lower is tighter, but it always
+// falls as centroids are added, so it cannot choose K on its own.
+// It is defined by Euclidean distance whatever distance clustered the data.
+func WCSS(data [][]float64, centroids [][]float64) float64 {
+    if len(centroids) == 0 {
+        return 0;
+    }
+    var sum, distance float64;
+    for _, vec := range data {
+        distance = utils.Distance(vec, centroids[utils.FindNearestDistance(vec, centroids)]);
+        sum += distance * distance;
+    }
+    return sum;
+};
+
+// Mean silhouette coefficient of the data assigned to its nearest centroids,
+// from -1 (assigned to the wrong cluster) to 1 (tight, well separated
+// clusters). A vector alone in its cluster scores 0, and fewer than two
+// non-empty clusters score 0 overall.
+// Every pair of vectors is compared, so the cost is O(n^2) distances.
+// Vectors are compared by Euclidean distance, see SilhouetteWithDistance.
+func Silhouette(data [][]float64, centroids [][]float64) float64 {
+    return SilhouetteWithDistance(data, centroids, nil);
+};
+
+// Silhouette with vectors assigned and compared by distance, or by Euclidean
+// distance if it is nil. Score a clustering by the distance that produced it.
+func SilhouetteWithDistance(data [][]float64, centroids [][]float64, distance types.Distance) float64 {
+    if distance == nil {
+        distance = utils.Distance;
+    }
+    if len(data) < 2 || len(centroids) < 2 {
+        return 0;
+    }
+    labels := AssignWithDistance(data, centroids, distance);
+    sizes := make([]int, len(centroids));
+    for _, label := range labels {
+        sizes[label]++;
+    }
+    nonEmpty := 0;
+    for _, size := range sizes {
+        if size > 0 {
+            nonEmpty++;
+        }
+    }
+    if nonEmpty < 2 {
+        return 0;
+    }
+    var total float64;
+    sums := make([]float64, len(centroids));
+    for i, vec := range data {
+        for c := range sums {
+            sums[c] = 0;
+        }
+        for j, other := range data {
+            if i != j {
+                sums[labels[j]] += distance(vec, other);
+            }
+        }
+        own := labels[i];
+        if sizes[own] < 2 {
+            continue;
+        }
+        a := sums[own] / float64(sizes[own] - 1);
+        b := math.Inf(1);
+        for c, sum := range sums {
+            if c != own && sizes[c] > 0 && sum / float64(sizes[c]) < b {
+                b = sum / float64(sizes[c]);
+            }
+        }
+        if max := math.Max(a, b); max > 0 {
+            total += (b - a) / max;
+        }
+    }
+    return total / float64(len(data));
+};
+
+// Silhouette over a uniform random sample of at most sampleSize vectors drawn
+// with seed, for data sets too large for the O(n^2) comparisons of every
+// pair. The sample is scored on its own, so the cost is O(sampleSize^2) and
+// the result estimates the full silhouette more closely as the sample grows.
+// A sample at least as large as the data scores it all.
+func SilhouetteSample(data [][]float64, centroids [][]float64, sampleSize int, seed int64) float64 {
+    if sampleSize >= len(data) {
+        return Silhouette(data, centroids);
+    }
+    if sampleSize < 0 {
+        sampleSize = 0;
+    }
+    sample := make([][]float64, sampleSize);
+    for i, j := range rand.New(rand.NewSource(seed)).Perm(len(data))[:sampleSize] {
+        sample[i] = data[j];
+    }
+    return Silhouette(sample, centroids);
+};
+
+// Davies-Bouldin index of a labeled clustering, the average over clusters of
+// the worst ratio of summed spread (mean distance to the centroid) to the
+// distance between the two centroids. Lower is better, with 0 the best.
+// Labels index centroids; vectors with a label outside them are ignored, as
+// are clusters no vector is labeled with. Fewer than two non-empty clusters
+// score 0, and two distinct clusters sharing a centroid score +Inf.
+// Spreads and separations are Euclidean distances.
+func DaviesBouldin(data [][]float64, labels []int, centroids [][]float64) float64 {
+    spreads := make([]float64, len(centroids));
+    sizes := make([]int, len(centroids));
+    for i := 0; i < len(data) && i < len(labels); i++ {
+        label := labels[i];
+        if label < 0 || label >= len(centroids) {
+            continue;
+        }
+        spreads[label] += utils.Distance(data[i], centroids[label]);
+        sizes[label]++;
+    }
+    var clusters []int;
+    for c, size := range sizes {
+        if size > 0 {
+            spreads[c] /= float64(size);
+            clusters = append(clusters, c);
+        }
+    }
+    if len(clusters) < 2 {
+        return 0;
+    }
+    var total float64;
+    for _, i := range clusters {
+        worst := 0.0;
+        for _, j := range clusters {
+            if i == j {
+                continue;
+            }
+            spread := spreads[i] + spreads[j];
+            separation := utils.Distance(centroids[i], centroids[j]);
+            ratio := 0.0;
+            if separation > 0 {
+                ratio = spread / separation;
+            } else if spread > 0 {
+                ratio = math.Inf(1);
+            }
+            if ratio > worst {
+                worst = ratio;
+            }
+        }
+        total += worst;
+    }
+    return total / float64(len(clusters));
+};
@@ -0,0 +1,161 @@
+package parse;
+
+import (
+  "fmt"
+  "math"
+);
+
+// How the parser turns string valued fields into numbers.
+type CategoricalMode int;
+
+const (
+  // One column holding each category's code, codes counting up from 0 in the
+  // order categories are first seen.
+  Ordinal CategoricalMode = iota;
+  // One column per category, named field=category, that is 1 for the
+  // category and 0 otherwise.
+  OneHot;
+);
+
+// The distinct strings of a categorical field and their codes.
+type categories struct {
+  names []string;
+  codes map[string]int;
+};
+
+func newCategories() *categories {
+  return &categories{
+    names: nil,
+    codes: make(map[string]int),
+  };
+};
+
+// The code of a category, giving it the next one if it is new.
+func (this *categories) add(name string) int {
+  if code, ok := this.codes[name]; ok {
+    return code;
+  }
+  this.codes[name] = len(this.names);
+  this.names = append(this.names, name);
+  return this.codes[name];
+};
+
+// The code of a known category.
+func (this *categories) code(name string) (int, bool) {
+  code, ok := this.codes[name];
+  return code, ok;
+};
+
+// The category of a code, if there is one.
+func (this *categories) name(code int) (string, bool) {
+  if code < 0 || code >= len(this.names) {
+    return "", false;
+  }
+  return this.names[code], true;
+};
+
+// A field is categorical when its first value is a string. Later values that
+// are not strings count as the category of their printed form.
+func isCategorical(value interface{}) bool {
+  _, ok := value.(string);
+  return ok;
+};
+
+func categoryOf(value interface{}) (string, bool) {
+  switch v := value.(type) {
+    case string:
+      return v, true;
+    case nil:
+      return "", false;
+  }
+  return fmt.Sprint(value), true;
+};
+
+func oneHotKey(field, category string) string {
+  return field + "=" + category;
+};
+
+func newCategoricalSchema() *Schema {
+  return &Schema{
+    dataType: stringType,
+    categories: newCategories(),
+  };
+};
+
+func newOneHotSchema(source, category string) *Schema {
+  return &Schema{
+    dataType: floatType,
+    min: 0,
+    max: 1,
+    source: source,
+    category: category,
+  };
+};
+
+// Ordinal codes are scaled by the range of codes seen when the schema was
+// created, at least [0, 1].
+func (this *Schema) fitCodes() {
+  this.min = 0;
+  this.max = float64(len(this.categories.names) - 1);
+  if this.max < 1 {
+    this.max = 1;
+  }
+};
+
+// The categories of an ordinal field in code order, nil for other fields.
+func (this *Schema) GetCategories() []string {
+  if this.categories == nil {
+    return nil;
+  }
+  return this.categories.names;
+};
+
+// Set how string valued fields are encoded by the schemas created from now on.
+// Ordinal is the default.
+func (this *Parser) SetCategoricalMode(mode CategoricalMode) {
+  this.categoricalMode = mode;
+};
+
+func (this *Parser) GetCategoricalMode() CategoricalMode {
+  return this.categoricalMode;
+};
+
+// Convert the value of one schema column of a JSON object, normalized.
+// A missing or unconvertible value gives an error along with the value it
+// stands in for, as JSONToFloat64 has always used it. The schema is never
+// changed: an ordinal field holding a category it was not created with, or no
+// category at all, is an error standing in as NaN, which decodes to no
+// category, and a one-hot field leaves all its columns 0.
+func (this *Parser) encode(key string, jsonMap map[string]interface{}) (float64, error) {
+  schema, ok := this.schema[key];
+  if ok && schema.source != "" {
+    value, present := jsonMap[schema.source];
+    if !present {
+      return 0, fmt.Errorf("Missing field %s", schema.source);
+    }
+    if name, ok := categoryOf(value); ok && name == schema.category {
+      return 1, nil;
+    }
+    return 0, nil;
+  }
+  value, present := jsonMap[key];
+  if !present {
+    return this.normalize(key, 0), fmt.Errorf("Missing field %s", key);
+  }
+  if ok && schema.categories != nil {
+    name, ok := categoryOf(value);
+    if !ok {
+      return math.NaN(), fmt.Errorf("Field %s has no category", key);
+    }
+    code, known := schema.categories.code(name);
+    if !known {
+      return math.NaN(), fmt.Errorf("Field %s has the category %q, which the schema was not created with", key, name);
+    }
+    return NormalizeWith(float64(code), schema.min, schema.max), nil;
+  }
+  float, err := this.ConvertInterfaceToFloat64(value);
+  if err != nil {
+    return this.normalize(key, float), fmt.Errorf("Field %s: %v", key, err);
+  }
+  return this.normalize(key, float), nil;
+};
@@ -0,0 +1,116 @@
+package parse;
+
+import (
+  "encoding/csv"
+  "errors"
+  "fmt"
+  "io"
+  "strconv"
+  "strings"
+);
+
+// Treat the first CSV column as a row label or id. CSVToFloat64Matrix then
+// keeps it aside, see GetRowLabels, instead of making it a schema field.
+func (this *Parser) SetCSVRowLabels(rowLabels bool) {
+  this.csvRowLabels = rowLabels;
+};
+
+func (this *Parser) GetCSVRowLabels() bool {
+  return this.csvRowLabels;
+};
+
+// The first column of each row read by the last CSVToFloat64Matrix, in row
+// order, when SetCSVRowLabels is on.
+func (this *Parser) GetRowLabels() []string {
+  return this.rowLabels;
+};
+
+// Read a CSV table whose header row names the fields, the CSV counterpart of
+// JSONToFloat64Matrix. The schema is created from the columns in header order
+// with the range of every column, and the rows are normalized by it, so
+// Float64ToJSON and WriteCSV work on the result as they do after JSON.
+// A row with a different number of fields than the header, or a cell that is
+// not a number, is an error naming its line.
+func (this *Parser) CSVToFloat64Matrix(r io.Reader) ([][]float64, error) {
+  reader := csv.NewReader(r);
+  reader.FieldsPerRecord = -1;
+  header, err := reader.Read();
+  if err == io.EOF {
+    return nil, errors.New("CSV has no header row");
+  }
+  if err != nil {
+    return nil, err;
+  }
+  first := 0;
+  if this.csvRowLabels {
+    first = 1;
+  }
+  keys := header[first:];
+  if len(keys) == 0 {
+    return nil, errors.New("CSV header has no fields");
+  }
+  seen := make(map[string]bool);
+  for _, key := range keys {
+    if seen[key] {
+      return nil, fmt.Errorf("CSV header repeats the field %s", key);
+    }
+    seen[key] = true;
+  }
+
+  var values [][]float64;
+  var labels []string;
+  schema := make(map[string]*Schema);
+  for line := 2; ; line++ {
+    record, err := reader.Read();
+    if err == io.EOF {
+      break;
+    }
+    if err != nil {
+      return nil, err;
+    }
+    if len(record) != len(header) {
+      return nil, fmt.Errorf("CSV line %d has %d fields but the header has %d", line, len(record), len(header));
+    }
+    if this.csvRowLabels {
+      labels = append(labels, record[0]);
+    }
+    row := make([]float64, len(keys));
+    for j, cell := range record[first:] {
+      value, err := strconv.ParseFloat(strings.TrimSpace(cell), 64);
+      if err != nil {
+        return nil, fmt.Errorf("CSV line %d field %s: %q is not a number", line, keys[j], cell);
+      }
+      row[j] = value;
+      // Track the range of the column as CreateSchema does.
+      if field, ok := schema[keys[j]]; !ok {
+        schema[keys[j]] = NewSchema(value);
+      } else if value < field.GetMin() {
+        field.SetMin(value);
+      } else if value > field.GetMax() {
+        field.SetMax(value);
+      }
+    }
+    values = append(values, row);
+  }
+
+  for _, key := range keys {
+    if _, ok := schema[key]; !ok {
+      schema[key] = NewSchema(0);
+    }
+  }
+  this.schema = schema;
+  this.schemaKeys = append([]string{}, keys...);
+  this.rowLabels = labels;
+  this.correlation = nil;
+  this.logger.Infof("parse: schema fitted with %d fields from %d entries", len(keys), len(values));
+
+  matrix := make([][]float64, len(values));
+  for i, row := range values {
+    for j, value := range row {
+      row[j] = this.normalize(keys[j], value);
+    }
+    this.observeCorrelation(row);
+    matrix[i] = row;
+  }
+  return matrix, nil;
+};
@@ -2,22 +2,52 @@ package parse;
 
 import (
   "errors"
+  "fmt"
+  "io"
   "math"
   "reflect"
+  "sort"
+  "strings"
+  "bytes"
+  "strconv"
+  "encoding/csv"
   "encoding/json"
+  "github.com/wenkesj/rphash/types"
+  "github.com/wenkesj/rphash/utils"
 );
 
 var (
   fixedDecimalPoint = 18
   floatType = reflect.TypeOf(float64(0))
+  stringType = reflect.TypeOf("")
   weightMax = math.Abs(ToFixed(math.MaxFloat64, fixedDecimalPoint))
   weightMin = float64(0)
+  // The largest integer a 64 bit float holds exactly, 2^53.
+  maxExactInteger = int64(1) << 53
+);
+
+// What the parser does with integers too large to be held exactly by a
+// 64 bit float, such as large ids.
+type LargeNumberPolicy int;
+
+const (
+  // Convert them to the nearest float, losing precision.
+  LargeNumberFloat LargeNumberPolicy = iota;
+  // Fail the conversion.
+  LargeNumberError;
+  // Treat them as labels, giving each distinct value its own code.
+  LargeNumberLabel;
 );
 
 type Schema struct {
   dataType reflect.Type;
   max float64;
   min float64;
+  // The codes of an ordinal categorical field.
+  categories *categories;
+  // The field and category a one hot column stands for.
+  source string;
+  category string;
 };
 
 func NewSchema(value float64) *Schema {
@@ -61,10 +91,37 @@ func DeNormalize(normalized float64) float64 {
 	return (normalized * (weightMax - weightMin) + weightMin);
 };
 
+// Scale a value of a field observed between min and max into [0, 1].
+// A field that never varies, max == min, scales to 0.
+func NormalizeWith(value, min, max float64) float64 {
+  if max == min {
+    return 0;
+  }
+  return (value - min) / (max - min);
+};
+
+// Undo NormalizeWith. Every value of a field that never varies maps back to
+// its only value, min.
+func DeNormalizeWith(normalized, min, max float64) float64 {
+  if max == min {
+    return min;
+  }
+  return normalized * (max - min) + min;
+};
+
 type Parser struct {
   schemaKeys []string;
   schema map[string]*Schema;
   label string;
+  nonFiniteSentinel interface{};
+  nonFiniteCount int;
+  largeNumberPolicy LargeNumberPolicy;
+  labelCodes map[string]float64;
+  logger types.Logger;
+  correlation *utils.Correlation;
+  categoricalMode CategoricalMode;
+  csvRowLabels bool;
+  rowLabels []string;
 };
 
 func NewParser() *Parser {
@@ -73,47 +130,320 @@ func NewParser() *Parser {
     label: "",
     schema: nil,
     schemaKeys: schemaKeys,
+    largeNumberPolicy: LargeNumberFloat,
+    labelCodes: make(map[string]float64),
+    logger: utils.NewNopLogger(),
   };
 };
 
+// The fields of the schema in the order they appear in the float vectors.
+func (this *Parser) GetSchemaKeys() []string {
+  return this.schemaKeys;
+};
+
+// The label of the data set the schema was created from.
+func (this *Parser) GetLabel() string {
+  return this.label;
+};
+
+// Set the value written in place of NaN and infinite values when converting
+// floats back to JSON, since encoding/json cannot marshal them.
+// By default they are written as null.
+func (this *Parser) SetNonFiniteSentinel(sentinel float64) {
+  this.nonFiniteSentinel = sentinel;
+};
+
+// The number of NaN or infinite values replaced since the parser was created
+// or the count was last reset.
+func (this *Parser) GetNonFiniteCount() int {
+  return this.nonFiniteCount;
+};
+
+func (this *Parser) ResetNonFiniteCount() {
+  this.nonFiniteCount = 0;
+};
+
+// Report each schema the parser creates to logger.
+func (this *Parser) SetLogger(logger types.Logger) {
+  this.logger = logger;
+};
+
+// Set how integers beyond 2^53 are converted.
+// With any policy other than LargeNumberFloat, BytesToJSON keeps numbers as
+// json.Number so large integers reach the conversion intact.
+func (this *Parser) SetLargeNumberPolicy(policy LargeNumberPolicy) {
+  this.largeNumberPolicy = policy;
+};
+
+func (this *Parser) GetLargeNumberPolicy() LargeNumberPolicy {
+  return this.largeNumberPolicy;
+};
+
+// The code given to a large integer under the LargeNumberLabel policy.
+// Codes are handed out in the order values are first converted.
+func (this *Parser) GetLabelCode(value string) (float64, bool) {
+  code, ok := this.labelCodes[value];
+  return code, ok;
+};
+
+// The normalization of one schema field, for reproducing it outside Go.
+// A "minmax" scaler maps a value x to (x - Min) / (Max - Min), or to 0 when
+// Max == Min. Min and Max are the range of the field in the data the schema
+// was created from, which ObservedMin and ObservedMax repeat.
+// An "ordinal" scaler first maps a string to its index in Categories.
+// A "onehot" column is 1 when the Source field holds Category and 0 otherwise.
+type FieldStats struct {
+  Field string `json:"field"`;
+  Scaler string `json:"scaler"`;
+  Min float64 `json:"min"`;
+  Max float64 `json:"max"`;
+  ObservedMin float64 `json:"observedMin"`;
+  ObservedMax float64 `json:"observedMax"`;
+  Categories []string `json:"categories,omitempty"`;
+  Source string `json:"source,omitempty"`;
+  Category string `json:"category,omitempty"`;
+};
+
+// The normalization statistics of every schema field as a JSON array, in the
+// same order as the fields of the float vectors.
+func (this *Parser) ExportStats() ([]byte, error) {
+  stats, err := this.FieldStatistics();
+  if err != nil {
+    return nil, err;
+  }
+  return json.MarshalIndent(stats, "", "  ");
+};
+
+// The normalization of every schema field, in schema order, as ExportStats
+// writes it.
+func (this *Parser) FieldStatistics() ([]FieldStats, error) {
+  if len(this.schemaKeys) == 0 {
+    return nil, errors.New("Parser has no schema to export");
+  }
+  stats := make([]FieldStats, len(this.schemaKeys));
+  for i, key := range this.schemaKeys {
+    stats[i] = FieldStats{
+      Field: key,
+      Scaler: "minmax",
+      Min: weightMin,
+      Max: weightMax,
+    };
+    if schema, ok := this.schema[key]; ok {
+      stats[i].Min, stats[i].Max = schema.GetMin(), schema.GetMax();
+      stats[i].ObservedMin, stats[i].ObservedMax = schema.GetMin(), schema.GetMax();
+      if schema.categories != nil {
+        stats[i].Scaler = "ordinal";
+        stats[i].Categories = append([]string{}, schema.categories.names...);
+      } else if schema.source != "" {
+        stats[i].Scaler = "onehot";
+        stats[i].Source, stats[i].Category = schema.source, schema.category;
+      }
+    }
+  }
+  return stats, nil;
+};
+
+// Restore a schema from FieldStatistics, such as one saved with a model, so
+// Transform converts new data exactly as the original parser did.
+func (this *Parser) ImportStats(label string, stats []FieldStats) error {
+  if len(stats) == 0 {
+    return errors.New("Cannot import a schema without fields");
+  }
+  schema := make(map[string]*Schema);
+  keys := make([]string, len(stats));
+  for i, field := range stats {
+    if field.Min > field.Max {
+      return fmt.Errorf("Field %s has a minimum %v above its maximum %v", field.Field, field.Min, field.Max);
+    }
+    if _, ok := schema[field.Field]; ok {
+      return fmt.Errorf("Field %s appears more than once", field.Field);
+    }
+    switch field.Scaler {
+      case "minmax":
+        schema[field.Field] = NewSchema(field.Min);
+      case "ordinal":
+        schema[field.Field] = newCategoricalSchema();
+        for _, name := range field.Categories {
+          schema[field.Field].categories.add(name);
+        }
+        schema[field.Field].min = field.Min;
+      case "onehot":
+        schema[field.Field] = newOneHotSchema(field.Source, field.Category);
+      default:
+        return fmt.Errorf("Field %s uses a scaler this parser does not apply", field.Field);
+    }
+    schema[field.Field].SetMax(field.Max);
+    keys[i] = field.Field;
+  }
+  this.label = label;
+  this.schema = schema;
+  this.schemaKeys = keys;
+  this.correlation = nil;
+  return nil;
+};
+
 // Convert an array of bytes to a JSON struct.
-func (this *Parser) BytesToJSON(bytesContents []byte) map[string]interface{} {
+// Malformed or truncated JSON, or JSON that is not an object, is an error.
+func (this *Parser) BytesToJSON(bytesContents []byte) (map[string]interface{}, error) {
   var data map[string]interface{}
-  if err := json.Unmarshal(bytesContents, &data); err != nil {
-    panic(err);
+  decoder := json.NewDecoder(bytes.NewReader(bytesContents));
+  if this.largeNumberPolicy != LargeNumberFloat {
+    decoder.UseNumber();
+  }
+  if err := decoder.Decode(&data); err != nil {
+    return nil, err;
   }
-  return data;
+  return data, nil;
 };
 
-func (this *Parser) JSONToBytes(jsonMap interface{}) []byte {
-  bytesContents, _ := json.MarshalIndent(jsonMap, "", "  ");
-  return bytesContents;
+// Convert a JSON struct to an indented array of bytes.
+// Values encoding/json cannot represent, such as NaN or infinite floats,
+// are reported as an error rather than producing empty output.
+func (this *Parser) JSONToBytes(jsonMap interface{}) ([]byte, error) {
+  return json.MarshalIndent(jsonMap, "", "  ");
 };
 
 // Convert a json object with a schema to an array of 64 bit floats.
+// A value an ordinal field was not created with is NaN, so it never decodes
+// to one of the field's categories, and the row is left out of the
+// correlation. Transform reports such values as an error instead.
 func (this *Parser) JSONToFloat64(jsonMap map[string]interface{}) []float64 {
 
   // Create an array of 64 bit floats of the same size.
   result := make([]float64, len(this.schemaKeys));
 
-  // Iterate over the json fields and assign floating point values to each field value.
+  // Iterate over the schema columns and assign the normalized value of each.
+  finite := true;
   for i := 0; i < len(this.schemaKeys); i++ {
-    // Normalize the mapped value
-    key := this.schemaKeys[i];
-    float, _ := this.ConvertInterfaceToFloat64(jsonMap[key]);
-    result[i] = Normalize(float);
+    result[i], _ = this.encode(this.schemaKeys[i], jsonMap);
+    if math.IsNaN(result[i]) {
+      finite = false;
+    }
+  }
+  if finite {
+    this.observeCorrelation(result);
   }
   return result;
 };
 
+// Normalize a value by the range of its field, or by the global range if the
+// field is not in the schema.
+func (this *Parser) normalize(key string, value float64) float64 {
+  if schema, ok := this.schema[key]; ok {
+    return NormalizeWith(value, schema.GetMin(), schema.GetMax());
+  }
+  return Normalize(value);
+};
+
+func (this *Parser) deNormalize(key string, normalized float64) float64 {
+  if schema, ok := this.schema[key]; ok {
+    return DeNormalizeWith(normalized, schema.GetMin(), schema.GetMax());
+  }
+  return DeNormalize(normalized);
+};
+
+// Track the correlation of the fields over every row the parser converts,
+// starting over whenever the schema changes.
+func (this *Parser) observeCorrelation(vec []float64) {
+  if this.correlation == nil || this.correlation.Dimension() != len(vec) {
+    this.correlation = utils.NewCorrelation(len(vec));
+  }
+  this.correlation.Add(vec);
+};
+
+// The pairs of schema keys whose correlation, positive or negative, is above
+// threshold over the rows converted since the schema last changed. One field
+// of each pair adds little the other does not, so it can be dropped before
+// projection. Fields that never vary are not reported.
+func (this *Parser) ReportCorrelatedFeatures(threshold float64) [][2]string {
+  var pairs [][2]string;
+  if this.correlation == nil || this.correlation.Dimension() != len(this.schemaKeys) {
+    return pairs;
+  }
+  for i := 0; i < len(this.schemaKeys); i++ {
+    for j := i + 1; j < len(this.schemaKeys); j++ {
+      if math.Abs(this.correlation.Correlation(i, j)) > threshold {
+        pairs = append(pairs, [2]string{this.schemaKeys[i], this.schemaKeys[j]});
+      }
+    }
+  }
+  return pairs;
+};
+
+// Add a field to the end of the schema without refitting, for data that gains
+// a feature over time. The existing fields keep their positions, so vectors
+// converted before can be brought up to the new length with PadVector.
+// initialMin and initialMax stand in for the range observed so far.
+func (this *Parser) AppendFeature(key string, initialMin, initialMax float64) error {
+  if key == "" {
+    return errors.New("Cannot append a feature without a key");
+  }
+  if initialMin > initialMax {
+    return fmt.Errorf("Feature %s has a minimum %v above its maximum %v", key, initialMin, initialMax);
+  }
+  if this.schema == nil {
+    this.schema = make(map[string]*Schema);
+  }
+  if _, ok := this.schema[key]; ok {
+    return fmt.Errorf("Feature %s is already in the schema", key);
+  }
+  schema := NewSchema(initialMin);
+  schema.SetMax(initialMax);
+  this.schema[key] = schema;
+  this.schemaKeys = append(this.schemaKeys, key);
+  return nil;
+};
+
+// Zero pad a vector converted under an older, shorter schema to the length
+// of the current one. Vectors already as long are returned as they are.
+func (this *Parser) PadVector(vec []float64) []float64 {
+  if len(vec) >= len(this.schemaKeys) {
+    return vec;
+  }
+  padded := make([]float64, len(this.schemaKeys));
+  copy(padded, vec);
+  return padded;
+};
+
 // Convert an array of 64 bit floats to JSON according to a schema.
+// NaN and infinite values are replaced, see SetNonFiniteSentinel.
 func (this *Parser) Float64ToJSON(floats []float64) map[string]interface{} {
   // Create an JSON object.
   jsonMap := make(map[string]interface{});
 
+  // The largest one hot column seen so far of each categorical field.
+  hottest := make(map[string]float64);
   for i := 0; i < len(this.schemaKeys); i++ {
-    // DeNormalize the mapped value
-    jsonMap[this.schemaKeys[i]] = DeNormalize(floats[i]);
+    schema, ok := this.schema[this.schemaKeys[i]];
+    if ok && schema.source != "" {
+      // A one hot field takes the category of its largest column, or null
+      // if none is above 0.
+      if _, seen := jsonMap[schema.source]; !seen {
+        jsonMap[schema.source] = nil;
+      }
+      if best, seen := hottest[schema.source]; floats[i] > 0 && (!seen || floats[i] > best) {
+        hottest[schema.source] = floats[i];
+        jsonMap[schema.source] = schema.category;
+      }
+      continue;
+    }
+    // DeNormalize the mapped value, replacing anything that cannot be marshaled.
+    value := this.deNormalize(this.schemaKeys[i], floats[i]);
+    if math.IsNaN(value) || math.IsInf(value, 0) {
+      this.nonFiniteCount++;
+      jsonMap[this.schemaKeys[i]] = this.nonFiniteSentinel;
+      continue;
+    }
+    if ok && schema.categories != nil {
+      // The nearest code, or null if it names no category.
+      if name, ok := schema.categories.name(Round(value)); ok {
+        jsonMap[this.schemaKeys[i]] = name;
+      } else {
+        jsonMap[this.schemaKeys[i]] = nil;
+      }
+      continue;
+    }
+    jsonMap[this.schemaKeys[i]] = value;
   }
   return jsonMap;
 };
@@ -129,25 +459,40 @@ func (this *Parser) Float64ToJSON(floats []float64) map[string]interface{} {
 //  ...
 //  }]
 // }
-func (this *Parser) JSONToFloat64Matrix(label string, dataSet map[string]interface{}) [][]float64 {
-  // Assign a label to the specific schema.
-  this.label = label;
-
+// A missing label, or one that does not hold an array of objects, is an error
+// and leaves the parser's schema as it was.
+func (this *Parser) JSONToFloat64Matrix(label string, dataSet map[string]interface{}) ([][]float64, error) {
   // Read the data in as an array of json objects.
-  data := dataSet[label].([]interface{});
+  value, ok := dataSet[label];
+  if !ok {
+    return nil, fmt.Errorf("Data set has no label %s", label);
+  }
+  data, ok := value.([]interface{});
+  if !ok {
+    return nil, fmt.Errorf("Label %s does not hold an array", label);
+  }
+  for i := range data {
+    if _, ok := data[i].(map[string]interface{}); !ok {
+      return nil, fmt.Errorf("Entry %d of %s is not a JSON object", i, label);
+    }
+  }
   count := len(data);
 
+  // Assign a label to the specific schema.
+  this.label = label;
+
   // Allocate an array of arrays for the return.
   matrix := make([][]float64, count, count);
 
   // Create a schema based on an entry in the data.
   this.schema = this.CreateSchema(data);
+  this.correlation = nil;
 
   // Convert the json data to weighted float values.
   for i := 0; i < count; i++ {
     matrix[i] = this.JSONToFloat64(data[i].(map[string]interface{}));
   }
-  return matrix;
+  return matrix, nil;
 };
 
 // Convert a matrix of 64 bit floats to JSON according to a json schema.
@@ -172,11 +517,92 @@ func (this *Parser) Float64MatrixToJSON(label string, dataSet [][]float64) map[s
   return result;
 };
 
+// Write a matrix of 64 bit floats to w as JSON according to the schema,
+// in the same shape as Float64MatrixToJSON.
+// Each row is converted and written on its own, so only one JSON object is
+// held in memory at a time no matter how large the data set is.
+func (this *Parser) EncodeMatrix(w io.Writer, label string, dataSet [][]float64) error {
+  labelBytes, err := json.Marshal(label);
+  if err != nil {
+    return err;
+  }
+  if _, err := io.WriteString(w, "{\n  " + string(labelBytes) + ": ["); err != nil {
+    return err;
+  }
+  for i := 0; i < len(dataSet); i++ {
+    objectBytes, err := json.Marshal(this.Float64ToJSON(dataSet[i]));
+    if err != nil {
+      return err;
+    }
+    separator := ",\n    ";
+    if i == 0 {
+      separator = "\n    ";
+    }
+    if _, err := io.WriteString(w, separator); err != nil {
+      return err;
+    }
+    if _, err := w.Write(objectBytes); err != nil {
+      return err;
+    }
+  }
+  closing := "\n  ]\n}\n";
+  if len(dataSet) == 0 {
+    closing = "]\n}\n";
+  }
+  _, err = io.WriteString(w, closing);
+  return err;
+};
+
+// Write a matrix, such as the normalized output of JSONToFloat64Matrix, to w
+// as CSV for tools like pandas or numpy. With header the first row holds the
+// schema keys. Every row must have one column per schema field.
+func (this *Parser) WriteCSV(w io.Writer, matrix [][]float64, header bool) error {
+  if len(this.schemaKeys) == 0 {
+    return errors.New("Parser has no schema to write the columns of");
+  }
+  writer := csv.NewWriter(w);
+  if header {
+    if err := writer.Write(this.schemaKeys); err != nil {
+      return err;
+    }
+  }
+  record := make([]string, len(this.schemaKeys));
+  for i, row := range matrix {
+    if len(row) != len(this.schemaKeys) {
+      return fmt.Errorf("Row %d has %d columns but the schema has %d fields", i, len(row), len(this.schemaKeys));
+    }
+    for j, value := range row {
+      record[j] = strconv.FormatFloat(value, 'g', -1, 64);
+    }
+    if err := writer.Write(record); err != nil {
+      return err;
+    }
+  }
+  writer.Flush();
+  return writer.Error();
+};
+
 // Convert an unknown interface to a 64 bit floating point.
 // From stackoverflow.com
 func (this *Parser) ConvertInterfaceToFloat64(unk interface{}) (float64, error) {
+  if number, ok := unk.(json.Number); ok {
+    return this.convertNumber(number);
+  }
   v := reflect.ValueOf(unk);
   v = reflect.Indirect(v);
+  if !v.IsValid() {
+    return 0, errors.New("Cannot convert nil to float64");
+  }
+  switch v.Kind() {
+    case reflect.Int, reflect.Int64:
+      if n := v.Int(); n > maxExactInteger || n < -maxExactInteger {
+        return this.convertLargeInteger(fmt.Sprint(n), float64(n));
+      }
+    case reflect.Uint, reflect.Uint64:
+      if n := v.Uint(); n > uint64(maxExactInteger) {
+        return this.convertLargeInteger(fmt.Sprint(n), float64(n));
+      }
+  }
   if !v.Type().ConvertibleTo(floatType) {
       return 0, errors.New("Cannot convert" + v.Type().String() + "to float64");
   }
@@ -184,39 +610,180 @@ func (this *Parser) ConvertInterfaceToFloat64(unk interface{}) (float64, error)
   return fv.Float(), nil;
 };
 
+// Convert a number decoded with json.Decoder.UseNumber, applying the large
+// number policy to integers a float cannot hold exactly.
+func (this *Parser) convertNumber(number json.Number) (float64, error) {
+  if n, err := number.Int64(); err == nil {
+    if n > maxExactInteger || n < -maxExactInteger {
+      return this.convertLargeInteger(number.String(), float64(n));
+    }
+    return float64(n), nil;
+  }
+  float, err := number.Float64();
+  if err != nil {
+    return 0, err;
+  }
+  if !strings.ContainsAny(number.String(), ".eE") {
+    // An integer too large even for int64.
+    return this.convertLargeInteger(number.String(), float);
+  }
+  return float, nil;
+};
+
+func (this *Parser) convertLargeInteger(text string, float float64) (float64, error) {
+  switch this.largeNumberPolicy {
+    case LargeNumberError:
+      return 0, errors.New("Cannot convert " + text + " to float64 without losing precision");
+    case LargeNumberLabel:
+      code, ok := this.labelCodes[text];
+      if !ok {
+        code = float64(len(this.labelCodes));
+        this.labelCodes[text] = code;
+      }
+      return code, nil;
+  }
+  return float, nil;
+};
+
+// Learn the schema from an array of JSON objects, replacing any schema the
+// parser already has. Every value must be numeric or a string, strings being
+// encoded as set by SetCategoricalMode.
+func (this *Parser) Fit(data []interface{}) error {
+  if len(data) == 0 {
+    return errors.New("Cannot fit a schema to an empty data set");
+  }
+  for i := 0; i < len(data); i++ {
+    jsonMap, ok := data[i].(map[string]interface{});
+    if !ok {
+      return fmt.Errorf("Entry %d is not a JSON object", i);
+    }
+    for key, value := range jsonMap {
+      if isCategorical(value) {
+        continue;
+      }
+      if _, err := this.ConvertInterfaceToFloat64(value); err != nil {
+        return fmt.Errorf("Entry %d field %s: %v", i, key, err);
+      }
+    }
+  }
+  this.schemaKeys = nil;
+  this.schema = this.CreateSchema(data);
+  this.correlation = nil;
+  return nil;
+};
+
+// Convert a JSON object to an array of normalized 64 bit floats using the
+// schema learned by Fit. Every field of the schema must be present. Numeric
+// fields must hold numbers, and an ordinal categorical field one of the
+// categories the schema was created with; a category a one-hot field was not
+// created with sets none of its columns. The schema itself is not changed.
+func (this *Parser) Transform(jsonMap map[string]interface{}) ([]float64, error) {
+  if len(this.schemaKeys) == 0 {
+    return nil, errors.New("Parser has no schema, call Fit first");
+  }
+  result := make([]float64, len(this.schemaKeys));
+  for i, key := range this.schemaKeys {
+    value, err := this.encode(key, jsonMap);
+    if err != nil {
+      return nil, err;
+    }
+    result[i] = value;
+  }
+  this.observeCorrelation(result);
+  return result, nil;
+};
+
+// Fit the schema to an array of JSON objects and transform each of them.
+func (this *Parser) FitTransform(data []interface{}) ([][]float64, error) {
+  if err := this.Fit(data); err != nil {
+    return nil, err;
+  }
+  matrix := make([][]float64, len(data));
+  for i := 0; i < len(data); i++ {
+    vector, err := this.Transform(data[i].(map[string]interface{}));
+    if err != nil {
+      return nil, fmt.Errorf("Entry %d: %v", i, err);
+    }
+    matrix[i] = vector;
+  }
+  return matrix, nil;
+};
+
 // Create a schema based on a JSON object.
 func (this *Parser) CreateSchema(data []interface{}) map[string]*Schema {
   count := len(data);
 
-  // Set up a base schema.
-  schema := make(map[string]*Schema);
+  // The schema of each field, in the order the fields are first seen.
+  var fields []string;
+  found := make(map[string]*Schema);
 
   // Loop over each JSON object in the array update the schema associated schema.
   for i := 0; i < count; i++ {
     // Convert the data to a json object.
     jsonMap := data[i].(map[string]interface{});
 
-    // Loop over its key -> value pairs.
-    for key, value := range jsonMap {
+    // Loop over its key -> value pairs, in sorted key order so the schema
+    // keys come out the same on every run.
+    keys := make([]string, 0, len(jsonMap));
+    for key := range jsonMap {
+      keys = append(keys, key);
+    }
+    sort.Strings(keys);
+    for _, key := range keys {
+      value := jsonMap[key];
+      field, ok := found[key];
+      if !ok && isCategorical(value) {
+        field = newCategoricalSchema();
+        found[key] = field;
+        fields = append(fields, key);
+      }
+      if field != nil && field.categories != nil {
+        if name, ok := categoryOf(value); ok {
+          field.categories.add(name);
+        }
+        continue;
+      }
+
       floatValue, _ := this.ConvertInterfaceToFloat64(value);
       // Has the schema not been added for the key?
-      if _, ok := schema[key]; !ok {
+      if !ok {
         // Assign the key associated with the JSON field to its value type max and min.
-        schema[key] = NewSchema(floatValue);
-
-        // Assure the keys are in the proper order.
-        this.schemaKeys = append(this.schemaKeys, key);
+        found[key] = NewSchema(floatValue);
+        fields = append(fields, key);
         continue;
       }
 
       // Check if the next value is less than the current minimum
       // Check if the next value is greater than the current maximum
-      if floatValue < schema[key].GetMin() {
-        schema[key].SetMin(floatValue);
-      } else if floatValue > schema[key].GetMax() {
-        schema[key].SetMax(floatValue);
+      if floatValue < field.GetMin() {
+        field.SetMin(floatValue);
+      } else if floatValue > field.GetMax() {
+        field.SetMax(floatValue);
+      }
+    }
+  }
+
+  // Lay out the columns. A one hot field expands into a column per category,
+  // next to each other in the order the categories were seen.
+  schema := make(map[string]*Schema);
+  var columns []string;
+  for _, key := range fields {
+    field := found[key];
+    if field.categories != nil && this.categoricalMode == OneHot {
+      for _, name := range field.categories.names {
+        column := oneHotKey(key, name);
+        schema[column] = newOneHotSchema(key, name);
+        columns = append(columns, column);
       }
+      continue;
+    }
+    if field.categories != nil {
+      field.fitCodes();
     }
+    schema[key] = field;
+    columns = append(columns, key);
   }
+  this.schemaKeys = columns;
+  this.logger.Infof("parse: schema fitted with %d fields from %d entries", len(columns), count);
   return schema;
 };
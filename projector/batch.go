@@ -0,0 +1,84 @@
+package projector;
+
+import (
+    "runtime"
+    "sync"
+);
+
+type lengthCheckedProjector interface {
+    vectorProjector;
+    CheckLength(v []float64) error;
+};
+
+/**
+ * Project every row of a matrix with workers goroutines, each taking a
+ * contiguous share of the rows. The matrix is only read once built, so the
+ * result is the same for any number of workers. Every row is checked before
+ * any is projected so a bad one panics here rather than in a worker.
+ */
+func projectMatrix(p lengthCheckedProjector, vs [][]float64, workers int) [][]float64 {
+    for _, v := range vs {
+        if err := p.CheckLength(v); err != nil {
+            panic(err);
+        }
+    }
+    if workers < 1 {
+        workers = runtime.NumCPU();
+    }
+    if workers > len(vs) {
+        workers = len(vs);
+    }
+    result := make([][]float64, len(vs));
+    if workers <= 1 {
+        for i, v := range vs {
+            result[i] = p.Project(v);
+        }
+        return result;
+    }
+    var group sync.WaitGroup;
+    for w := 0; w < workers; w++ {
+        start, end := w * len(vs) / workers, (w + 1) * len(vs) / workers;
+        group.Add(1);
+        go func(start, end int) {
+            defer group.Done();
+            for i := start; i < end; i++ {
+                result[i] = p.Project(vs[i]);
+            }
+        }(start, end);
+    }
+    group.Wait();
+    return result;
+};
+
+/**
+ * Project every row of a matrix, see Project.
+ * @return {[][]float64} reducedVectors - One reduced vector per row, in order.
+ */
+func (this *DBFriendly) ProjectMatrix(vs [][]float64) [][]float64 {
+    return projectMatrix(this, vs, 1);
+};
+
+/**
+ * Project every row of a matrix across goroutines. The rows come back in
+ * order and identical to ProjectMatrix whatever the number of workers.
+ * @param {int} workers - Goroutines to use, the number of CPUs if less than 1.
+ * @return {[][]float64} reducedVectors - One reduced vector per row, in order.
+ */
+func (this *DBFriendly) ProjectMatrixParallel(vs [][]float64, workers int) [][]float64 {
+    return projectMatrix(this, vs, workers);
+};
+
+/**
+ * Project every row of a matrix, see DBFriendly.ProjectMatrix.
+ */
+func (this *Gaussian) ProjectMatrix(vs [][]float64) [][]float64 {
+    return projectMatrix(this, vs, 1);
+};
+
+/**
+ * Project every row of a matrix across goroutines, see
+ * DBFriendly.ProjectMatrixParallel.
+ */
+func (this *Gaussian) ProjectMatrixParallel(vs [][]float64, workers int) [][]float64 {
+    return projectMatrix(this, vs, workers);
+};
@@ -1,8 +1,12 @@
 package projector;
 
 import (
+    "errors"
+    "fmt"
     "math"
     "math/rand"
+    "github.com/wenkesj/rphash/types"
+    "github.com/wenkesj/rphash/utils"
 );
 
 type DBFriendly struct {
@@ -11,37 +15,87 @@ type DBFriendly struct {
     inputDimensionality int;
     targetDimensionality int;
     random *rand.Rand;
+    ignoreTail bool;
+    logger types.Logger;
+    dimensionWeights []float64;
+};
+
+/**
+ * The smallest target dimension the Johnson-Lindenstrauss lemma guarantees will
+ * keep all pairwise distances of numberOfPoints vectors within a factor of
+ * (1 +/- epsilon), t >= 4 ln(n) / (epsilon^2 / 2 - epsilon^3 / 3).
+ * @param {int} numberOfPoints - Number of vectors to be projected.
+ * @param {float64} epsilon - Allowed distortion, in (0, 1).
+ * @return {int} targetDimensionality - Suggested target dimension.
+ */
+func JLDimension(numberOfPoints int, epsilon float64) int {
+    if numberOfPoints < 2 || epsilon <= 0 || epsilon >= 1 {
+        return 1;
+    }
+    bound := epsilon * epsilon / 2 - epsilon * epsilon * epsilon / 3;
+    return int(math.Ceil(4 * math.Log(float64(numberOfPoints)) / bound));
+};
+
+/**
+ * Check that a projection actually reduces the dimension of its input.
+ * @param {int} inputDimensionality - Original dimension.
+ * @param {int} targetDimensionality - Target/Projected dimension.
+ * @return {error} err - Non-nil when the target is not below the original.
+ */
+func CheckTargetDimension(inputDimensionality, targetDimensionality int) error {
+    if targetDimensionality < 1 {
+        return errors.New("projector: target dimension must be positive");
+    }
+    if targetDimensionality >= inputDimensionality {
+        return fmt.Errorf("projector: target dimension %d does not reduce input dimension %d, " +
+            "choose t < n (JLDimension gives the smallest t that preserves distances for a data set size)",
+            targetDimensionality, inputDimensionality);
+    }
+    return nil;
+};
+
+/**
+ * Allocate a new instance of DBFriendly, refusing a target dimension that does
+ * not reduce the input.
+ * @param {int} inputDimensionality - Original dimension.
+ * @param {int} targetDimensionality - Target/Projected dimension.
+ * @param {int} randomseed - Random seed.
+ */
+func NewDBFriendlyStrict(inputDimensionality, targetDimensionality int, randomseed int64) (*DBFriendly, error) {
+    if err := CheckTargetDimension(inputDimensionality, targetDimensionality); err != nil {
+        return nil, err;
+    }
+    return newDBFriendly(inputDimensionality, targetDimensionality, randomseed), nil;
 };
 
 /**
  * Allocate a new instance of DBFriendly.
+ * A target dimension at or above the original is allowed, and warned about
+ * once a logger is set; use NewDBFriendlyStrict to treat it as an error.
  * @param {int} inputDimensionality - Original dimension.
  * @param {int} targetDimensionality - Target/Projected dimension.
  * @param {int} randomseed - Random seed.
  */
 func NewDBFriendly(inputDimensionality, targetDimensionality int, randomseed int64) *DBFriendly {
+    return newDBFriendly(inputDimensionality, targetDimensionality, randomseed);
+};
+
+func newDBFriendly(inputDimensionality, targetDimensionality int, randomseed int64) *DBFriendly {
+    // Each entry is -1 or +1 with probability 1/6 apiece and 0 otherwise.
     const NONZEROINDICESCHANCE = 6;
     rando := rand.New(rand.NewSource(randomseed));
     negativeVectorIndices, positiveVectorIndices := make([][]int, targetDimensionality), make([][]int, targetDimensionality);
-    rM, rP := 0, 0;
     probability := inputDimensionality / NONZEROINDICESCHANCE;
     for i := 0; i < targetDimensionality; i++ {
-        orderedNegativeIndices, orderedPositiveIndices := make([]int, probability), make([]int, probability);
+        negativeRow, positiveRow := make([]int, 0, probability), make([]int, 0, probability);
         for j := 0; j < inputDimensionality; j++ {
-            rM, rP = rando.Intn(NONZEROINDICESCHANCE), rando.Intn(NONZEROINDICESCHANCE);
-            if rM == 0 {
-                orderedNegativeIndices = append(orderedNegativeIndices, int(j));
-            } else if rP == 0 {
-                orderedPositiveIndices = append(orderedPositiveIndices, int(j));
+            switch rando.Intn(NONZEROINDICESCHANCE) {
+            case 0:
+                negativeRow = append(negativeRow, j);
+            case 1:
+                positiveRow = append(positiveRow, j);
             }
         }
-        negativeRow, positiveRow := make([]int, len(orderedNegativeIndices)), make([]int, len(orderedPositiveIndices));
-        for k, val := range orderedNegativeIndices {
-            negativeRow[k] = val;
-        }
-        for k, val := range orderedPositiveIndices {
-            positiveRow[k] = val;
-        }
         negativeVectorIndices[i], positiveVectorIndices[i] = negativeRow, positiveRow;
     }
 
@@ -51,14 +105,65 @@ func NewDBFriendly(inputDimensionality, targetDimensionality int, randomseed int
         inputDimensionality: inputDimensionality,
         targetDimensionality: targetDimensionality,
         random: rando,
+        logger: utils.NewNopLogger(),
     };
 };
 
+/**
+ * Describe the projection to logger once it is set, warning if it does not
+ * reduce the dimension.
+ * @param {types.Logger} logger - Receives the projector's events.
+ */
+func (this *DBFriendly) SetLogger(logger types.Logger) {
+    this.logger = logger;
+    this.logger.Debugf("projector: projecting %d dimensions to %d, ignoring the tail: %v",
+        this.inputDimensionality, this.targetDimensionality, this.ignoreTail);
+    if err := CheckTargetDimension(this.inputDimensionality, this.targetDimensionality); err != nil {
+        this.logger.Warnf("%v", err);
+    }
+};
+
+/**
+ * Project only the first n coordinates of longer vectors, so a trailing label
+ * or id column can ride along with the data. Off by default, when a vector of
+ * any length other than n is a caller error and Project panics.
+ * Shorter vectors are always an error.
+ * @param {bool} ignoreTail - Whether coordinates past n are ignored.
+ */
+func (this *DBFriendly) SetIgnoreTail(ignoreTail bool) {
+    this.ignoreTail = ignoreTail;
+};
+
+func (this *DBFriendly) GetIgnoreTail() bool {
+    return this.ignoreTail;
+};
+
+/**
+ * Check that Project will accept a vector, to validate input without a panic.
+ * @param {[]float64} inputVector - Vector to be projected.
+ * @return {error} err - Non-nil when the length does not suit the projection.
+ */
+func (this *DBFriendly) CheckLength(inputVector []float64) error {
+    n := len(inputVector);
+    if n == this.inputDimensionality || (this.ignoreTail && n > this.inputDimensionality) {
+        return nil;
+    }
+    return fmt.Errorf("projector: vector has %d dimensions, expected %d", n, this.inputDimensionality);
+};
+
 /**
  * Project onto a random matrix of {-1, 1} to produce a reduced dimensional vector.
+ * This is Achlioptas' database friendly projection: entries of sqrt(3) * {-1, 0, +1}
+ * with probabilities {1/6, 2/3, 1/6} have mean 0 and variance 1, so each output
+ * coordinate has E[y_i^2] = |x|^2 and dividing by sqrt(t) keeps
+ * E[|y|^2] = |x|^2. Only the nonzero indices are stored, leaving the combined
+ * scale sqrt(3) / sqrt(t) = sqrt(3 / t) to apply to the sums.
  * @return {[]float64} reducedVector - Returns a reduced dimensional vector with dimension t.
  */
 func (this *DBFriendly) Project(inputVector []float64) []float64 {
+    if err := this.CheckLength(inputVector); err != nil {
+        panic(err);
+    }
     var sum float64;
     reducedVector := make([]float64, this.targetDimensionality);
     scale := math.Sqrt(3 / float64(this.targetDimensionality));
@@ -72,5 +177,113 @@ func (this *DBFriendly) Project(inputVector []float64) []float64 {
         }
         reducedVector[i] = sum;
     }
+    if this.dimensionWeights != nil {
+        for i := range reducedVector {
+            reducedVector[i] *= this.dimensionWeights[i];
+        }
+    }
     return reducedVector;
 };
+
+/**
+ * Lift a projected vector back to the input dimension by applying the
+ * transpose of the projection, with the same indices, scale and dimension
+ * weights. This is only approximate: the projection discards information, so
+ * Reproject(Project(x)) is not x. The transpose of the sparse matrix
+ * satisfies E[R^T R] = I, though, so it points back toward x and is useful
+ * for viewing projected centroids in the original feature space.
+ * Coordinates past n that Project ignored are not recovered.
+ * @param {[]float64} reducedVector - Vector of the target dimension t.
+ * @return {[]float64} inputVector - Returns an approximate vector with dimension n.
+ */
+func (this *DBFriendly) Reproject(reducedVector []float64) []float64 {
+    if len(reducedVector) != this.targetDimensionality {
+        panic(fmt.Errorf("projector: projected vector has %d dimensions, expected %d", len(reducedVector), this.targetDimensionality));
+    }
+    inputVector := make([]float64, this.inputDimensionality);
+    scale := math.Sqrt(3 / float64(this.targetDimensionality));
+    for i, val := range reducedVector {
+        if this.dimensionWeights != nil {
+            val *= this.dimensionWeights[i];
+        }
+        val *= scale;
+        for _, j := range this.negativeVectorIndices[i] {
+            inputVector[j] -= val;
+        }
+        for _, j := range this.positiveVectorIndices[i] {
+            inputVector[j] += val;
+        }
+    }
+    return inputVector;
+};
+
+/**
+ * Weight each projected dimension by its variance over a sample, so the
+ * dimensions that spread the data most count most when the projection is
+ * decoded. The weights are scaled to average 1, and Project applies them
+ * from then on. A sample with no variance at all leaves every weight at 1.
+ * @param {[][]float64} sample - Vectors Project accepts.
+ * @return {error} err - Non-nil for an empty sample or a vector Project would reject.
+ */
+func (this *DBFriendly) FitDimensionWeights(sample [][]float64) error {
+    if len(sample) == 0 {
+        return errors.New("projector: cannot fit dimension weights to an empty sample");
+    }
+    for _, vec := range sample {
+        if err := this.CheckLength(vec); err != nil {
+            return err;
+        }
+    }
+    this.dimensionWeights = nil;
+    means := make([]float64, this.targetDimensionality);
+    squares := make([]float64, this.targetDimensionality);
+    for _, vec := range sample {
+        for i, x := range this.Project(vec) {
+            means[i] += x;
+            squares[i] += x * x;
+        }
+    }
+    weights := make([]float64, this.targetDimensionality);
+    var total float64;
+    for i := range weights {
+        means[i] /= float64(len(sample));
+        weights[i] = math.Max(squares[i] / float64(len(sample)) - means[i] * means[i], 0);
+        total += weights[i];
+    }
+    for i := range weights {
+        if total == 0 {
+            weights[i] = 1;
+        } else {
+            weights[i] *= float64(len(weights)) / total;
+        }
+    }
+    this.dimensionWeights = weights;
+    return nil;
+};
+
+/**
+ * The weights FitDimensionWeights set, or nil if Project does not weight.
+ */
+func (this *DBFriendly) GetDimensionWeights() []float64 {
+    return this.dimensionWeights;
+};
+
+/**
+ * Stop weighting the projected dimensions.
+ */
+func (this *DBFriendly) ClearDimensionWeights() {
+    this.dimensionWeights = nil;
+};
+
+/**
+ * Measure how well this projection keeps the distances of a sample of the
+ * data, as empirical evidence that the target dimension is large enough
+ * beyond the JLDimension bound. Every pair is compared, so keep the sample
+ * to a few hundred vectors.
+ * @param {[][]float64} sample - Vectors of the input dimension.
+ * @return {float64} meanRatio - Mean of projected over original distance, near 1 when distances are kept.
+ * @return {float64} maxRatio - The worst distortion of any pair as a factor of at least 1.
+ */
+func (this *DBFriendly) MeasureDistortion(sample [][]float64) (meanRatio, maxRatio float64) {
+    return measureDistortion(this, sample);
+};
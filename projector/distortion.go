@@ -0,0 +1,52 @@
+package projector;
+
+import (
+    "math"
+);
+
+type vectorProjector interface {
+    Project(v []float64) []float64;
+};
+
+func distance(x, y []float64) float64 {
+    var sum float64;
+    for i := range x {
+        sum += (x[i] - y[i]) * (x[i] - y[i]);
+    }
+    return math.Sqrt(sum);
+};
+
+/**
+ * Compare every pairwise distance of a sample before and after projection.
+ * Pairs at distance 0 are skipped.
+ * @return {float64} meanRatio - Mean of projected over original distance, 1 when distances are kept on average.
+ * @return {float64} maxRatio - The worst distortion of any pair as a factor of at least 1,
+ *   the larger of projected / original and original / projected.
+ */
+func measureDistortion(p vectorProjector, sample [][]float64) (meanRatio, maxRatio float64) {
+    projected := make([][]float64, len(sample));
+    for i, vec := range sample {
+        projected[i] = p.Project(vec);
+    }
+    var pairs float64;
+    for i := 0; i < len(sample); i++ {
+        for j := i + 1; j < len(sample); j++ {
+            original := distance(sample[i], sample[j]);
+            if original == 0 {
+                continue;
+            }
+            ratio := distance(projected[i], projected[j]) / original;
+            meanRatio += ratio;
+            pairs++;
+            worst := ratio;
+            if ratio < 1 {
+                worst = 1 / ratio;
+            }
+            maxRatio = math.Max(maxRatio, worst);
+        }
+    }
+    if pairs == 0 {
+        return 0, 0;
+    }
+    return meanRatio / pairs, maxRatio;
+};
@@ -0,0 +1,91 @@
+package projector;
+
+import (
+    "fmt"
+    "math"
+    "math/rand"
+    "github.com/wenkesj/rphash/types"
+);
+
+type Gaussian struct {
+    matrix [][]float64;
+    inputDimensionality int;
+    targetDimensionality int;
+};
+
+/**
+ * Allocate a dense Gaussian projection, an alternative to DBFriendly that
+ * distorts distances less for small target dimensions at the cost of a full
+ * t x n matrix and multiply. Every entry is drawn from N(0, 1 / t), so
+ * E[|y|^2] = |x|^2 as for DBFriendly.
+ * A target dimension at or above the original is allowed, and warned about
+ * once a logger is set.
+ * @param {int} inputDimensionality - Original dimension.
+ * @param {int} targetDimensionality - Target/Projected dimension.
+ * @param {int} randomseed - Random seed.
+ */
+func NewGaussian(inputDimensionality, targetDimensionality int, randomseed int64) *Gaussian {
+    random := rand.New(rand.NewSource(randomseed));
+    deviation := 1 / math.Sqrt(float64(targetDimensionality));
+    matrix := make([][]float64, targetDimensionality);
+    for i := range matrix {
+        matrix[i] = make([]float64, inputDimensionality);
+        for j := range matrix[i] {
+            matrix[i][j] = random.NormFloat64() * deviation;
+        }
+    }
+    return &Gaussian{
+        matrix: matrix,
+        inputDimensionality: inputDimensionality,
+        targetDimensionality: targetDimensionality,
+    };
+};
+
+/**
+ * Warn logger if the projection does not reduce the dimension.
+ * @param {types.Logger} logger - Receives the projector's events.
+ */
+func (this *Gaussian) SetLogger(logger types.Logger) {
+    if err := CheckTargetDimension(this.inputDimensionality, this.targetDimensionality); err != nil {
+        logger.Warnf("%v", err);
+    }
+};
+
+/**
+ * Check that Project will accept a vector, to validate input without a panic.
+ * @param {[]float64} inputVector - Vector to be projected.
+ * @return {error} err - Non-nil when the length is not the input dimension.
+ */
+func (this *Gaussian) CheckLength(inputVector []float64) error {
+    if len(inputVector) != this.inputDimensionality {
+        return fmt.Errorf("projector: vector has %d dimensions, expected %d", len(inputVector), this.inputDimensionality);
+    }
+    return nil;
+};
+
+/**
+ * Multiply by the dense matrix to produce a reduced dimensional vector.
+ * @return {[]float64} reducedVector - Returns a reduced dimensional vector with dimension t.
+ */
+func (this *Gaussian) Project(inputVector []float64) []float64 {
+    if err := this.CheckLength(inputVector); err != nil {
+        panic(err);
+    }
+    reducedVector := make([]float64, this.targetDimensionality);
+    for i, row := range this.matrix {
+        var sum float64;
+        for j, x := range inputVector {
+            sum += row[j] * x;
+        }
+        reducedVector[i] = sum;
+    }
+    return reducedVector;
+};
+
+/**
+ * Measure how well this projection keeps the distances of a sample, see
+ * DBFriendly.MeasureDistortion.
+ */
+func (this *Gaussian) MeasureDistortion(sample [][]float64) (meanRatio, maxRatio float64) {
+    return measureDistortion(this, sample);
+};
@@ -0,0 +1,65 @@
+package projector;
+
+import (
+    "encoding/json"
+    "fmt"
+    "github.com/wenkesj/rphash/utils"
+);
+
+// The stored form of a DBFriendly projection: the nonzero indices of every
+// row of the matrix, so a loaded projection matches the saved one exactly.
+type dbFriendlyJSON struct {
+    InputDimensionality int `json:"inputDimensionality"`;
+    TargetDimensionality int `json:"targetDimensionality"`;
+    NegativeIndices [][]int `json:"negativeIndices"`;
+    PositiveIndices [][]int `json:"positiveIndices"`;
+    IgnoreTail bool `json:"ignoreTail"`;
+    DimensionWeights []float64 `json:"dimensionWeights,omitempty"`;
+};
+
+/**
+ * Encode the projection matrix, and its tail and weight settings, as JSON.
+ */
+func (this *DBFriendly) MarshalJSON() ([]byte, error) {
+    return json.Marshal(dbFriendlyJSON{
+        InputDimensionality: this.inputDimensionality,
+        TargetDimensionality: this.targetDimensionality,
+        NegativeIndices: this.negativeVectorIndices,
+        PositiveIndices: this.positiveVectorIndices,
+        IgnoreTail: this.ignoreTail,
+        DimensionWeights: this.dimensionWeights,
+    });
+};
+
+/**
+ * Restore a projection written by MarshalJSON.
+ */
+func (this *DBFriendly) UnmarshalJSON(data []byte) error {
+    var stored dbFriendlyJSON;
+    if err := json.Unmarshal(data, &stored); err != nil {
+        return err;
+    }
+    if len(stored.NegativeIndices) != stored.TargetDimensionality || len(stored.PositiveIndices) != stored.TargetDimensionality {
+        return fmt.Errorf("projector: expected %d rows of indices", stored.TargetDimensionality);
+    }
+    if stored.DimensionWeights != nil && len(stored.DimensionWeights) != stored.TargetDimensionality {
+        return fmt.Errorf("projector: expected %d dimension weights, got %d", stored.TargetDimensionality, len(stored.DimensionWeights));
+    }
+    for _, rows := range [][][]int{stored.NegativeIndices, stored.PositiveIndices} {
+        for _, row := range rows {
+            for _, index := range row {
+                if index < 0 || index >= stored.InputDimensionality {
+                    return fmt.Errorf("projector: index %d is outside the input dimension %d", index, stored.InputDimensionality);
+                }
+            }
+        }
+    }
+    this.inputDimensionality = stored.InputDimensionality;
+    this.targetDimensionality = stored.TargetDimensionality;
+    this.negativeVectorIndices = stored.NegativeIndices;
+    this.positiveVectorIndices = stored.PositiveIndices;
+    this.ignoreTail = stored.IgnoreTail;
+    this.dimensionWeights = stored.DimensionWeights;
+    this.logger = utils.NewNopLogger();
+    return nil;
+};
@@ -0,0 +1,19 @@
+package reader;
+
+import (
+    "fmt"
+);
+
+// Check a centroid against the dimension of the data, or when that is not
+// known against the centroids already held, so a vector of the wrong length
+// is caught where it is added rather than deep in the k-means refinement.
+func checkCentroid(v []float64, dimension int, centroids [][]float64) error {
+    expected := dimension;
+    if expected < 1 && len(centroids) > 0 {
+        expected = len(centroids[0]);
+    }
+    if expected > 0 && len(v) != expected {
+        return fmt.Errorf("centroid has %d dimensions, expected %d", len(v), expected);
+    }
+    return nil;
+};
@@ -0,0 +1,65 @@
+package reader;
+
+import (
+    "errors"
+    "io"
+    "github.com/wenkesj/rphash/types"
+);
+
+// An iterator that counts the vectors taken from the one it wraps. Weight,
+// Close and Err pass through, so a weighted, closable or failing iterator
+// keeps working when wrapped, while a plain one weighs 1, closes as a no-op
+// and never fails.
+type countingIterator struct {
+    types.Iterator;
+    // Vectors taken since the last Reset, and the most taken in any pass.
+    count int;
+    seen int;
+};
+
+func newCountingIterator(data types.Iterator) *countingIterator {
+    return &countingIterator{Iterator: data};
+};
+
+func (this *countingIterator) Next() []float64 {
+    vec := this.Iterator.Next();
+    this.count++;
+    if this.count > this.seen {
+        this.seen = this.count;
+    }
+    return vec;
+};
+
+func (this *countingIterator) Reset() {
+    this.count = 0;
+    this.Iterator.Reset();
+};
+
+func (this *countingIterator) Weight() float64 {
+    if weighted, ok := this.Iterator.(types.WeightedIterator); ok {
+        return weighted.Weight();
+    }
+    return 1;
+};
+
+func (this *countingIterator) Close() error {
+    if closer, ok := this.Iterator.(io.Closer); ok {
+        return closer.Close();
+    }
+    return nil;
+};
+
+func (this *countingIterator) Err() error {
+    if failing, ok := this.Iterator.(interface{ Err() error }); ok {
+        return failing.Err();
+    }
+    return nil;
+};
+
+// A copy of the wrapped iterator. Vectors read from it are not counted.
+func (this *countingIterator) Copy() (types.Iterator, error) {
+    if copyable, ok := this.Iterator.(types.CopyableIterator); ok {
+        return copyable.Copy();
+    }
+    return nil, errors.New("reader: the stream's iterator cannot be copied");
+};
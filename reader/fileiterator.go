@@ -0,0 +1,209 @@
+package reader;
+
+import (
+    "bufio"
+    "bytes"
+    "compress/gzip"
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "strconv"
+    "strings"
+    "github.com/wenkesj/rphash/types"
+);
+
+const (
+    formatCSV = iota;
+    formatJSONL;
+);
+
+// FileIterator streams vectors from a file, one per CSV row or JSON Lines
+// array, decompressing it first when the path ends in ".gz".
+// It holds the file open until Close is called. Reset rewinds it, reopening
+// the file, whether or not it was closed.
+type FileIterator struct {
+    path string;
+    format int;
+    file *os.File;
+    gzipReader *gzip.Reader;
+    csvReader *csv.Reader;
+    lineReader *bufio.Reader;
+    line int;
+    position int;
+    peeked bool;
+    nextVector []float64;
+    lshVals []int64;
+    err error;
+};
+
+// Open a CSV file of numeric rows without a header.
+func NewCSVIterator(path string) (*FileIterator, error) {
+    return newFileIterator(path, formatCSV);
+};
+
+// Open a JSON Lines file with one array of numbers per line.
+func NewJSONLIterator(path string) (*FileIterator, error) {
+    return newFileIterator(path, formatJSONL);
+};
+
+func newFileIterator(path string, format int) (*FileIterator, error) {
+    this := &FileIterator{
+        path: path,
+        format: format,
+    };
+    if err := this.open(); err != nil {
+        return nil, err;
+    }
+    return this, nil;
+};
+
+func (this *FileIterator) open() error {
+    file, err := os.Open(this.path);
+    if err != nil {
+        return err;
+    }
+    var source io.Reader = file;
+    this.gzipReader = nil;
+    if strings.HasSuffix(this.path, ".gz") {
+        this.gzipReader, err = gzip.NewReader(file);
+        if err != nil {
+            file.Close();
+            return err;
+        }
+        source = this.gzipReader;
+    }
+    this.file = file;
+    if this.format == formatCSV {
+        this.csvReader = csv.NewReader(source);
+        this.csvReader.FieldsPerRecord = -1;
+    } else {
+        // Lines are read whole however long they are, as a wide vector can
+        // run past any fixed buffer.
+        this.lineReader = bufio.NewReader(source);
+    }
+    this.line = 0;
+    this.position = -1;
+    this.peeked = false;
+    this.nextVector = nil;
+    return nil;
+};
+
+func (this *FileIterator) read() []float64 {
+    if this.file == nil || this.err != nil {
+        return nil;
+    }
+    this.line++;
+    if this.format == formatCSV {
+        record, err := this.csvReader.Read();
+        if err != nil {
+            if err != io.EOF {
+                this.err = err;
+            }
+            return nil;
+        }
+        vector := make([]float64, len(record));
+        for i, cell := range record {
+            vector[i], err = strconv.ParseFloat(strings.TrimSpace(cell), 64);
+            if err != nil {
+                this.err = fmt.Errorf("reader: %s line %d: %v", this.path, this.line, err);
+                return nil;
+            }
+        }
+        return vector;
+    }
+    for {
+        line, err := this.lineReader.ReadBytes('\n');
+        if text := bytes.TrimSpace(line); len(text) > 0 {
+            var vector []float64;
+            if err := json.Unmarshal(text, &vector); err != nil {
+                this.err = fmt.Errorf("reader: %s line %d: %v", this.path, this.line, err);
+                return nil;
+            }
+            return vector;
+        }
+        if err != nil {
+            if err != io.EOF {
+                this.err = err;
+            }
+            return nil;
+        }
+        this.line++;
+    }
+};
+
+func (this *FileIterator) HasNext() (ok bool) {
+    if !this.peeked {
+        this.nextVector = this.read();
+        this.peeked = true;
+    }
+    return this.nextVector != nil;
+};
+
+func (this *FileIterator) Next() (value []float64) {
+    if !this.HasNext() {
+        return nil;
+    }
+    this.peeked = false;
+    this.position++;
+    return this.nextVector;
+};
+
+func (this *FileIterator) PeakLSH() (lshValue int64) {
+    if this.lshVals == nil {
+        panic("Cannot call PeakLSH until after StoreLSHValues");
+    }
+    return this.lshVals[this.position];
+};
+
+func (this *FileIterator) StoreLSHValues(lshVals []int64) {
+    this.lshVals = lshVals;
+};
+
+// Read the whole file into memory. This defeats the point of streaming and is
+// only here to satisfy Iterator; the iterator is rewound afterwards.
+func (this *FileIterator) GetS() [][]float64 {
+    this.Reset();
+    var data [][]float64;
+    for this.HasNext() {
+        data = append(data, this.Next());
+    }
+    this.Reset();
+    return data;
+};
+
+// Rewind to the first vector by reopening the file.
+func (this *FileIterator) Reset() {
+    this.Close();
+    this.err = nil;
+    if err := this.open(); err != nil {
+        this.err = err;
+    }
+};
+
+// Open the file again as a new iterator, to be closed by the caller.
+func (this *FileIterator) Copy() (types.Iterator, error) {
+    return newFileIterator(this.path, this.format);
+};
+
+// The first read or parse error encountered, if any.
+func (this *FileIterator) Err() error {
+    return this.err;
+};
+
+// Release the file handle. The iterator reports no more vectors until Reset.
+func (this *FileIterator) Close() error {
+    if this.file == nil {
+        return nil;
+    }
+    if this.gzipReader != nil {
+        this.gzipReader.Close();
+        this.gzipReader = nil;
+    }
+    err := this.file.Close();
+    this.file = nil;
+    this.peeked = true;
+    this.nextVector = nil;
+    return err;
+};
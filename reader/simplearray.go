@@ -1,6 +1,7 @@
 package reader;
 
 import (
+    "fmt"
     "math"
     "math/rand"
     "github.com/wenkesj/rphash/decoder"
@@ -18,8 +19,10 @@ type SimpleArray struct {
     k int;
     numberOfBlurs int;
     decoder types.Decoder;
+    projector types.Projector;
     centroids [][]float64;
     topIDs []int64;
+    logger types.Logger;
 };
 
 func NewSimpleArray(inData [][]float64, k int) *SimpleArray {
@@ -55,9 +58,22 @@ func NewSimpleArray(inData [][]float64, k int) *SimpleArray {
         decoder: decoder,
         centroids: centroids,
         topIDs: topIDs,
+        logger: utils.NewNopLogger(),
     };
 };
 
+// Warn logger of the settings and centroids the object refuses or corrects.
+func (this *SimpleArray) SetLogger(logger types.Logger) {
+    this.logger = logger;
+};
+
+// Like NewSimpleArray, but each vector counts weights[i] times in Map and Reduce.
+func NewWeightedSimpleArray(inData [][]float64, weights []float64, k int) *SimpleArray {
+    result := NewSimpleArray(inData, k);
+    result.data = NewWeightedSliceIterator(inData, weights);
+    return result;
+};
+
 func (this *SimpleArray) GetVectorIterator() types.Iterator {
     return this.data;
 };
@@ -70,6 +86,13 @@ func (this *SimpleArray) GetK() int {
     return this.k;
 };
 
+// Clear the centroids and top ids of a run so a second run over the same
+// data does not add to them. The data and configuration are kept.
+func (this *SimpleArray) Reset() {
+    this.centroids = [][]float64{};
+    this.topIDs = []int64{};
+};
+
 func (this *SimpleArray) GetDimensions() int {
     if this.dimension == 0 {
         this.dimension = len(this.data.GetS()[0]);
@@ -85,8 +108,22 @@ func (this *SimpleArray) GetRandomSeed() int64 {
     return this.randomSeed;
 };
 
-func (this *SimpleArray) AddCentroid(v []float64) {
+// Add a centroid, refusing it with an error if its length differs from the
+// dimension of the data.
+func (this *SimpleArray) AddCentroidChecked(v []float64) error {
+    if err := checkCentroid(v, this.GetDimensions(), this.centroids); err != nil {
+        return fmt.Errorf("reader: %v", err);
+    }
     this.centroids = append(this.centroids, v);
+    return nil;
+};
+
+// Add a centroid, refusing it with a warning if its length differs from the
+// dimension of the data, see AddCentroidChecked.
+func (this *SimpleArray) AddCentroid(v []float64) {
+    if err := this.AddCentroidChecked(v); err != nil {
+        this.logger.Warnf("%v", err);
+    }
 };
 
 func (this *SimpleArray) SetCentroids(l [][]float64) {
@@ -101,6 +138,10 @@ func (this *SimpleArray) GetNumberOfBlurs() int {
     return this.numberOfBlurs;
 };
 
+func (this *SimpleArray) SetNumberOfBlurs(numberOfBlurs int) {
+    this.numberOfBlurs = numberOfBlurs;
+};
+
 func (this *SimpleArray) GetPreviousTopID() []int64 {
     return this.topIDs;
 };
@@ -140,3 +181,14 @@ func (this *SimpleArray) SetVariance(data [][]float64) {
 func (this *SimpleArray) GetVariance() float64 {
     return this.decoder.GetVariance();
 };
+
+// Hold a projector for Simple to reuse on every run instead of building one
+// from the random seed. It must project from GetDimensions() to the
+// dimensionality of the decoder Simple hashes with.
+func (this *SimpleArray) SetProjector(projector types.Projector) {
+    this.projector = projector;
+};
+
+func (this *SimpleArray) GetProjector() types.Projector {
+    return this.projector;
+};
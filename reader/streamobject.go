@@ -1,13 +1,14 @@
 package reader;
 
 import (
+    "fmt"
     "github.com/wenkesj/rphash/decoder"
     "github.com/wenkesj/rphash/types"
     "github.com/wenkesj/rphash/utils"
 );
 
 type StreamObject struct {
-    data types.Iterator;
+    data *countingIterator;
     numberOfProjections int;
     decoderMultiplier int;
     randomSeed int64;
@@ -18,8 +19,21 @@ type StreamObject struct {
     centroids [][]float64;
     topIDs []int64;
     decoder types.Decoder;
+    projector types.Projector;
+    varianceSampleInterval int64;
+    varianceHistory []VarianceSample;
+    angularVariance bool;
+    logger types.Logger;
 };
 
+// The decoder variance in use after a number of vectors had been processed.
+type VarianceSample struct {
+    VectorsProcessed int64;
+    Variance float64;
+};
+
+const DefaultVarianceSampleInterval int64 = 1000;
+
 func NewStreamObject(dimension, k int) *StreamObject {
     innerDecoder := decoder.InnerDecoder();
     decoderMultiplier := 1;
@@ -35,19 +49,46 @@ func NewStreamObject(dimension, k int) *StreamObject {
         numberOfProjections: 2,
         numberOfBlurs: 2,
         k: k,
-        data: nil,
         topIDs: topIDs,
         centroids: centroids,
+        varianceSampleInterval: DefaultVarianceSampleInterval,
+        varianceHistory: nil,
+        logger: utils.NewNopLogger(),
     };
 };
 
+// Warn logger of the settings and centroids the object refuses or corrects.
+func (this *StreamObject) SetLogger(logger types.Logger) {
+    this.logger = logger;
+};
+
 func (this *StreamObject) GetK() int {
     return this.k;
 };
+
+// Change the number of clusters, usually after a Reset.
+func (this *StreamObject) SetK(k int) {
+    this.k = k;
+};
+
+// Clear the results of a run, the centroids, top ids and variance history,
+// so the object can be reused for a fresh run. The configuration, including
+// the dimension, K, seed, decoder and projector, is kept.
+func (this *StreamObject) Reset() {
+    this.centroids = nil;
+    this.topIDs = nil;
+    this.varianceHistory = nil;
+};
+
+// The number of vectors streamed so far, counted as they are taken from the
+// vector iterator. Rereading the stream after a Reset of the iterator does not
+// add to the count, it is the most vectors read in any one pass.
 func (this *StreamObject) NumDataPoints() int {
-  //JF TODO count the streamed data
-  return 0;
-}
+    if this.data == nil {
+        return 0;
+    }
+    return this.data.seen;
+};
 
 func (this *StreamObject) GetDimensions() int {
     return this.dimension;
@@ -62,9 +103,21 @@ func (this *StreamObject) GetNumberOfBlurs() int {
 };
 
 func (this *StreamObject) GetVectorIterator() types.Iterator {
+    if this.data == nil {
+        return nil;
+    }
     return this.data;
 };
 
+// Stream the vectors of data, counting them for NumDataPoints as they are read.
+func (this *StreamObject) SetVectorIterator(data types.Iterator) {
+    if data == nil {
+        this.data = nil;
+        return;
+    }
+    this.data = newCountingIterator(data);
+};
+
 func (this *StreamObject) GetCentroids() [][]float64 {
     return this.centroids;
 };
@@ -77,8 +130,22 @@ func (this *StreamObject) SetPreviousTopID(top []int64) {
     this.topIDs = top;
 };
 
-func (this *StreamObject) AddCentroid(v []float64) {
+// Add a centroid, refusing it with an error if its length differs from the
+// dimension of the data.
+func (this *StreamObject) AddCentroidChecked(v []float64) error {
+    if err := checkCentroid(v, this.dimension, this.centroids); err != nil {
+        return fmt.Errorf("reader: %v", err);
+    }
     this.centroids = append(this.centroids, v);
+    return nil;
+};
+
+// Add a centroid, refusing it with a warning if its length differs from the
+// dimension of the data, see AddCentroidChecked.
+func (this *StreamObject) AddCentroid(v []float64) {
+    if err := this.AddCentroidChecked(v); err != nil {
+        this.logger.Warnf("%v", err);
+    }
 };
 
 func (this *StreamObject) SetCentroids(l [][]float64) {
@@ -89,12 +156,48 @@ func (this *StreamObject) GetNumberOfProjections() int {
     return this.numberOfProjections;
 };
 
-func (this *StreamObject) SetNumberOfProjections(probes int) {
+// Every vector is hashed numberOfProjections * numberOfBlurs times, so the
+// cost of a stream grows with the product of the two. More projections look at
+// the data through independent random subspaces and more blurs probe the
+// neighbouring buckets of each projection, both of which raise recall:
+//  - Projections: at least 1. Up to ceil(dimension / decoder dimensionality)
+//    they see mostly new coordinates; past that they still give new random
+//    subspaces, but each adds less.
+//  - Blurs: at least 1. A spherical decoder ORs the vertex picked by each of
+//    its k hash functions, one of 2d, into (2d)^k possible hashes, so blurs
+//    do not run out of distinct buckets at any practical count.
+// A count below 1 is refused with an error and the previous count kept.
+func (this *StreamObject) SetNumberOfProjectionsChecked(probes int) error {
+    if probes < 1 {
+        return fmt.Errorf("reader: number of projections must be at least 1, got %d", probes);
+    }
     this.numberOfProjections = probes;
+    return nil;
 };
 
-func (this *StreamObject) SetNumberOfBlurs(parseInt int) {
+// Set the number of projections, refusing a count below 1 with a warning,
+// see SetNumberOfProjectionsChecked.
+func (this *StreamObject) SetNumberOfProjections(probes int) {
+    if err := this.SetNumberOfProjectionsChecked(probes); err != nil {
+        this.logger.Warnf("%v", err);
+    }
+};
+
+// See SetNumberOfProjectionsChecked.
+func (this *StreamObject) SetNumberOfBlursChecked(parseInt int) error {
+    if parseInt < 1 {
+        return fmt.Errorf("reader: number of blurs must be at least 1, got %d", parseInt);
+    }
     this.numberOfBlurs = parseInt;
+    return nil;
+};
+
+// Set the number of blurs, refusing a count below 1 with a warning, see
+// SetNumberOfProjectionsChecked.
+func (this *StreamObject) SetNumberOfBlurs(parseInt int) {
+    if err := this.SetNumberOfBlursChecked(parseInt); err != nil {
+        this.logger.Warnf("%v", err);
+    }
 };
 
 func (this *StreamObject) SetRandomSeed(parseLong int64) {
@@ -117,10 +220,65 @@ func (this *StreamObject) GetDecoderType() types.Decoder {
     return this.decoder;
 };
 
+// Estimate the decoder variance from a sample of the data, over unit length
+// rows if angular variance is set.
 func (this *StreamObject) SetVariance(data [][]float64) {
+    if this.angularVariance {
+        this.decoder.SetVariance(utils.AngularVarianceSample(data, 0.01));
+        return;
+    }
     this.decoder.SetVariance(utils.VarianceSample(data, 0.01));
 };
 
+// Estimate the variance over unit length rows, for decoders tuned to cosine
+// LSH where vectors are compared by angle. Off by default.
+func (this *StreamObject) SetAngularVariance(angular bool) {
+    this.angularVariance = angular;
+};
+
+func (this *StreamObject) GetAngularVariance() bool {
+    return this.angularVariance;
+};
+
 func (this *StreamObject) GetVariance() float64 {
     return this.decoder.GetVariance();
 };
+
+// Record the variance in use after vectorsProcessed vectors. A sample is kept
+// for the first call and then once every variance sample interval, so a long
+// stream can report on every vector without the history growing with it.
+func (this *StreamObject) RecordVariance(vectorsProcessed int64, variance float64) {
+    if n := len(this.varianceHistory); n > 0 && vectorsProcessed - this.varianceHistory[n - 1].VectorsProcessed < this.varianceSampleInterval {
+        return;
+    }
+    this.varianceHistory = append(this.varianceHistory, VarianceSample{vectorsProcessed, variance});
+};
+
+// The variance samples recorded so far, oldest first.
+func (this *StreamObject) GetVarianceHistory() []VarianceSample {
+    history := make([]VarianceSample, len(this.varianceHistory));
+    copy(history, this.varianceHistory);
+    return history;
+};
+
+func (this *StreamObject) SetVarianceSampleInterval(interval int64) {
+    if interval < 1 {
+        interval = 1;
+    }
+    this.varianceSampleInterval = interval;
+};
+
+func (this *StreamObject) GetVarianceSampleInterval() int64 {
+    return this.varianceSampleInterval;
+};
+
+// Hold a projector for Simple to reuse on every run instead of building one
+// from the random seed. It must project from GetDimensions() to the
+// dimensionality of the decoder Simple hashes with.
+func (this *StreamObject) SetProjector(projector types.Projector) {
+    this.projector = projector;
+};
+
+func (this *StreamObject) GetProjector() types.Projector {
+    return this.projector;
+};
@@ -0,0 +1,46 @@
+package reader;
+
+import (
+    "github.com/wenkesj/rphash/types"
+    "github.com/wenkesj/rphash/utils"
+);
+
+// WeightedSliceIterator iterates an in-memory data set whose vectors each carry
+// a weight, counting a vector of weight w as if it appeared w times.
+type WeightedSliceIterator struct {
+    *utils.IterableSlice;
+    weights []float64;
+    position int;
+};
+
+// Pair each vector with the weight at the same index. Vectors without a
+// matching weight default to a weight of 1.
+func NewWeightedSliceIterator(data [][]float64, weights []float64) *WeightedSliceIterator {
+    return &WeightedSliceIterator{
+        IterableSlice: utils.NewIterator(data),
+        weights: weights,
+        position: -1,
+    };
+};
+
+func (this *WeightedSliceIterator) Next() (value []float64) {
+    this.position++;
+    return this.IterableSlice.Next();
+};
+
+func (this *WeightedSliceIterator) Reset() {
+    this.position = -1;
+    this.IterableSlice.Reset();
+};
+
+// A new iterator over the same vectors and weights.
+func (this *WeightedSliceIterator) Copy() (types.Iterator, error) {
+    return NewWeightedSliceIterator(this.GetS(), this.weights), nil;
+};
+
+func (this *WeightedSliceIterator) Weight() float64 {
+    if this.position < 0 || this.position >= len(this.weights) {
+        return 1;
+    }
+    return this.weights[this.position];
+};
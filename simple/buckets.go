@@ -0,0 +1,79 @@
+package simple;
+
+// bucketMeans keeps the running mean of the vectors hashed to a bounded number
+// of buckets during Map. Once full, a new bucket replaces the one holding the
+// least weight, as heavy buckets fill quickly and are rarely the lightest.
+type bucketMeans struct {
+    capacity int;
+    sums map[int64][]float64;
+    weights map[int64]float64;
+};
+
+func newBucketMeans(capacity int) *bucketMeans {
+    return &bucketMeans{
+        capacity: capacity,
+        sums: make(map[int64][]float64),
+        weights: make(map[int64]float64),
+    };
+};
+
+func (this *bucketMeans) add(bucket int64, vec []float64, weight float64) {
+    sum, ok := this.sums[bucket];
+    if !ok {
+        if len(this.sums) >= this.capacity {
+            this.evictLightest();
+        }
+        sum = make([]float64, len(vec));
+        this.sums[bucket] = sum;
+    }
+    for i, x := range vec {
+        sum[i] += x * weight;
+    }
+    this.weights[bucket] += weight;
+};
+
+// Ties go to the lowest bucket id, so the bucket evicted never depends on the
+// order the map is ranged in.
+func (this *bucketMeans) evictLightest() {
+    var lightest int64;
+    first := true;
+    for bucket, weight := range this.weights {
+        if first || weight < this.weights[lightest] || (weight == this.weights[lightest] && bucket < lightest) {
+            lightest, first = bucket, false;
+        }
+    }
+    delete(this.sums, lightest);
+    delete(this.weights, lightest);
+};
+
+// The mean of the vectors seen in a bucket, if it is still held.
+func (this *bucketMeans) mean(bucket int64) ([]float64, bool) {
+    sum, ok := this.sums[bucket];
+    if !ok || this.weights[bucket] <= 0 {
+        return nil, false;
+    }
+    mean := make([]float64, len(sum));
+    for i, x := range sum {
+        mean[i] = x / this.weights[bucket];
+    }
+    return mean, true;
+};
+
+// Fold the buckets of other into this one, then evict the lightest until no
+// more than the capacity are held.
+func (this *bucketMeans) merge(other *bucketMeans) {
+    for bucket, otherSum := range other.sums {
+        sum, ok := this.sums[bucket];
+        if !ok {
+            sum = make([]float64, len(otherSum));
+            this.sums[bucket] = sum;
+        }
+        for i, x := range otherSum {
+            sum[i] += x;
+        }
+        this.weights[bucket] += other.weights[bucket];
+    }
+    for len(this.sums) > this.capacity {
+        this.evictLightest();
+    }
+};
@@ -0,0 +1,46 @@
+package simple;
+
+import (
+    "math"
+    "sync"
+    "github.com/wenkesj/rphash/types"
+);
+
+type mapItem struct {
+    vec []float64;
+    hash int64;
+    // The sketch counts whole vectors, so weights are rounded to a count.
+    weight int64;
+};
+
+// Read the next block of up to blockSize vectors of vecs, reusing the storage
+// of block. It is empty once vecs is exhausted.
+func readMapBlock(vecs types.Iterator, block []mapItem) []mapItem {
+    block = block[:0];
+    for len(block) < blockSize && vecs.HasNext() {
+        vec := vecs.Next();
+        block = append(block, mapItem{vec: vec, weight: int64(math.Floor(vectorWeight(vecs) + 0.5))});
+    }
+    return block;
+};
+
+// Hash every vector of a block, split into one contiguous shard per worker.
+// The LSH function is only read once built, so the workers share it.
+func (this *Simple) hashBlock(LSH types.LSH, block []mapItem) {
+    workers := this.workers();
+    var group sync.WaitGroup;
+    for w := 0; w < workers; w++ {
+        shard := block[w * len(block) / workers : (w + 1) * len(block) / workers];
+        group.Add(1);
+        go func(shard []mapItem) {
+            defer group.Done();
+            for i := range shard {
+                // Project the Vector to lower dimension.
+                // Decode the new vector for meaningful integers
+                // Hash the new vector into a 64 bit int.
+                shard[i].hash = LSH.LSHHashSimple(shard[i].vec);
+            }
+        }(shard);
+    }
+    group.Wait();
+};
@@ -0,0 +1,71 @@
+package simple;
+
+import (
+    "sync"
+    "github.com/wenkesj/rphash/types"
+    "github.com/wenkesj/rphash/defaults"
+);
+
+// MapParallel is Map with every block of vectors split into contiguous
+// shards, one per worker, each counted into the worker's own sketch and bucket
+// means. The LSH function is only read once built, so the workers share it.
+// The sketches share Map's seed, so their tables are merged into the one Map
+// would have counted and the top is read from that, with every bucket any
+// worker kept ranked by its count over all the data. Workers below 1 use the
+// concurrency set with SetConcurrency. Any error the iterator reports, or the
+// sketches give when merged, is kept for Err.
+func (this *Simple) MapParallel(workers int) *Simple {
+    if workers < 1 {
+        workers = this.workers();
+    }
+    vecs := this.rphashObject.GetVectorIterator();
+    LSH := this.mapLSH(this.rphashObject);
+    capacity := this.candidateCapacity(this.rphashObject);
+
+    sketches := make([]types.CountItemSet, workers);
+    buckets := make([]*bucketMeans, workers);
+    for w := 0; w < workers; w++ {
+        sketches[w] = defaults.NewCountMinSketchSeeded(capacity, this.rphashObject.GetRandomSeed());
+        buckets[w] = newBucketMeans(capacity);
+    }
+    // Read the vectors a block at a time the way Map does, holding only the
+    // hashes of the blocks already counted.
+    var hashValues []int64;
+    var block []mapItem;
+    for block = readMapBlock(vecs, block); len(block) > 0; block = readMapBlock(vecs, block) {
+        var group sync.WaitGroup;
+        for w := 0; w < workers; w++ {
+            shard := block[w * len(block) / workers : (w + 1) * len(block) / workers];
+            group.Add(1);
+            go func(sketch types.CountItemSet, means *bucketMeans, shard []mapItem) {
+                defer group.Done();
+                for i := range shard {
+                    shard[i].hash = LSH.LSHHashSimple(shard[i].vec);
+                    sketch.AddWeighted(shard[i].hash, shard[i].weight);
+                    if shard[i].weight > 0 {
+                        means.add(shard[i].hash, shard[i].vec, float64(shard[i].weight));
+                    }
+                }
+            }(sketches[w], buckets[w], shard);
+        }
+        group.Wait();
+        for _, item := range block {
+            hashValues = append(hashValues, item.hash);
+        }
+    }
+    vecs.StoreLSHValues(hashValues);
+
+    this.err = iteratorErr(vecs);
+    for w := 1; w < workers; w++ {
+        if err := defaults.MergeCountMinSketches(sketches[0], sketches[w]); err != nil && this.err == nil {
+            this.err = err;
+        }
+        buckets[0].merge(buckets[w]);
+    }
+    top := sketches[0].GetTop();
+    this.buckets = buckets[0];
+    this.rphashObject.SetPreviousTopID(top);
+    this.logger.Infof("simple: map hashed %d vectors into %d candidate buckets with %d workers", len(hashValues), len(top), workers);
+    vecs.Reset();
+    return this;
+};
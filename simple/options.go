@@ -0,0 +1,106 @@
+package simple;
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "github.com/wenkesj/rphash/types"
+);
+
+// RunOptions overrides the RPHashObject's settings for a single RunWith, leaving
+// the object itself untouched. Only the seed can be overridden: Simple hashes
+// every vector once, so it never reads the number of projections or blurs.
+// Those are read by stream.Stream, which updates the variance of the object's
+// decoder as it reads and so cannot share one object between concurrent runs;
+// a sweep over them gives each setting its own StreamObject.
+type RunOptions struct {
+    // RandomSeed replaces the object's seed when UseRandomSeed is set, since
+    // zero is itself a valid seed.
+    RandomSeed int64;
+    UseRandomSeed bool;
+};
+
+// runObject reads the settings of an RPHashObject except where the options
+// override them, and keeps the results of a run and its own iterator to
+// itself, so the object it wraps is never written.
+type runObject struct {
+    types.RPHashObject;
+    options RunOptions;
+    vecs types.Iterator;
+    topIDs []int64;
+    centroids [][]float64;
+};
+
+func newRunObject(shared types.RPHashObject, options RunOptions) (*runObject, error) {
+    object := &runObject{RPHashObject: shared, options: options};
+    if vecs := shared.GetVectorIterator(); vecs != nil {
+        copyable, ok := vecs.(types.CopyableIterator);
+        if !ok {
+            return nil, errors.New("simple: RunWith needs an iterator that can be copied, see types.CopyableIterator");
+        }
+        copied, err := copyable.Copy();
+        if err != nil {
+            return nil, err;
+        }
+        object.vecs = copied;
+    }
+    return object, nil;
+};
+
+// Release the copy of the iterator, which the run opened.
+func (this *runObject) close() {
+    if closer, ok := this.vecs.(io.Closer); ok {
+        closer.Close();
+    }
+};
+
+func (this *runObject) GetVectorIterator() types.Iterator {
+    return this.vecs;
+};
+
+func (this *runObject) GetPreviousTopID() []int64 {
+    return this.topIDs;
+};
+
+func (this *runObject) SetPreviousTopID(top []int64) {
+    this.topIDs = top;
+};
+
+func (this *runObject) GetCentroids() [][]float64 {
+    return this.centroids;
+};
+
+func (this *runObject) AddCentroid(v []float64) {
+    this.centroids = append(this.centroids, v);
+};
+
+func (this *runObject) AddCentroidChecked(v []float64) error {
+    if len(v) != this.GetDimensions() {
+        return fmt.Errorf("simple: centroid has %d dimensions, expected %d", len(v), this.GetDimensions());
+    }
+    this.centroids = append(this.centroids, v);
+    return nil;
+};
+
+func (this *runObject) SetCentroids(l [][]float64) {
+    this.centroids = l;
+};
+
+func (this *runObject) GetRandomSeed() int64 {
+    if this.options.UseRandomSeed {
+        return this.options.RandomSeed;
+    }
+    return this.RPHashObject.GetRandomSeed();
+};
+
+// A run with its own seed builds its projector from that seed rather than
+// reusing one the object holds.
+func (this *runObject) GetProjector() types.Projector {
+    if this.options.UseRandomSeed {
+        return nil;
+    }
+    if holder, ok := this.RPHashObject.(types.ProjectorHolder); ok {
+        return holder.GetProjector();
+    }
+    return nil;
+};
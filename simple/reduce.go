@@ -0,0 +1,90 @@
+package simple;
+
+import (
+    "sync"
+    "github.com/wenkesj/rphash/types"
+);
+
+// Map and Reduce read the vectors in blocks of this many, so only one block is held
+// in memory no matter how large the data set is.
+const blockSize = 4096;
+
+type reduceItem struct {
+    vec []float64;
+    hash int64;
+    weight float64;
+};
+
+// reduceAccumulator holds the weighted sums of the vectors assigned to each
+// centroid. Every Reduce worker keeps its own, so the workers never share
+// state, and they are merged once every vector is assigned.
+type reduceAccumulator struct {
+    sums [][]float64;
+    weights []float64;
+    counts []int64;
+};
+
+func newReduceAccumulator(k, dimension int) *reduceAccumulator {
+    sums := make([][]float64, k);
+    for i := range sums {
+        sums[i] = make([]float64, dimension);
+    }
+    return &reduceAccumulator{
+        sums: sums,
+        weights: make([]float64, k),
+        counts: make([]int64, k),
+    };
+};
+
+func (this *reduceAccumulator) add(i int, vec []float64, weight float64) {
+    if weight <= 0 {
+        return;
+    }
+    this.counts[i]++;
+    this.weights[i] += weight;
+    for j, x := range vec {
+        this.sums[i][j] += x * weight;
+    }
+};
+
+func (this *reduceAccumulator) merge(other *reduceAccumulator) {
+    for i := range this.sums {
+        this.counts[i] += other.counts[i];
+        this.weights[i] += other.weights[i];
+        for j, x := range other.sums[i] {
+            this.sums[i][j] += x;
+        }
+    }
+};
+
+// The weighted mean of centroid i, or the zero vector if nothing was assigned.
+func (this *reduceAccumulator) mean(i int) []float64 {
+    mean := make([]float64, len(this.sums[i]));
+    if this.weights[i] > 0 {
+        for j, x := range this.sums[i] {
+            mean[j] = x / this.weights[i];
+        }
+    }
+    return mean;
+};
+
+// Assign each item of a block to a centroid and accumulate it. The block is
+// split into one contiguous shard per worker, and worker w always adds into
+// accumulators[w], so for a given concurrency the sums are built in the same
+// order on every run.
+func (this *Simple) reduceBlock(block []reduceItem, centroids []types.Centroid, accumulators []*reduceAccumulator) {
+    var workers sync.WaitGroup;
+    for w := range accumulators {
+        shard := block[w * len(block) / len(accumulators) : (w + 1) * len(block) / len(accumulators)];
+        workers.Add(1);
+        go func(shard []reduceItem, accumulator *reduceAccumulator) {
+            defer workers.Done();
+            for _, item := range shard {
+                if i := this.assigner.Assign(item.vec, item.hash, centroids); i >= 0 {
+                    accumulator.add(i, item.vec, item.weight);
+                }
+            }
+        }(shard, accumulators[w]);
+    }
+    workers.Wait();
+};
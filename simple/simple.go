@@ -1,16 +1,54 @@
 package simple;
 
 import (
+    "errors"
+    "fmt"
     "math"
+    "github.com/wenkesj/rphash/classifier"
     "github.com/wenkesj/rphash/types"
     "github.com/wenkesj/rphash/defaults"
+    "github.com/wenkesj/rphash/utils"
     "runtime"
 );
 
+// The error vecs reports for the pass just read, if it can fail. It must be
+// read before Reset, which rewinds a file iterator and clears its error.
+func iteratorErr(vecs types.Iterator) error {
+    if failing, ok := vecs.(interface{ Err() error }); ok {
+        return failing.Err();
+    }
+    return nil;
+};
+
+// The weight of the vector last returned by vecs, 1 unless it is weighted.
+func vectorWeight(vecs types.Iterator) float64 {
+    if weighted, ok := vecs.(types.WeightedIterator); ok {
+        return weighted.Weight();
+    }
+    return 1;
+};
+
 type Simple struct {
     centroids [][]float64;
     variance float64;
     rphashObject types.RPHashObject;
+    kmeansIterations int;
+    kmeansTolerance float64;
+    assigner types.Assigner;
+    clusterSizes []int64;
+    minClusterSize int;
+    initialCentroids [][]float64;
+    buckets *bucketMeans;
+    refineProjected bool;
+    candidateMultiplier float64;
+    logger types.Logger;
+    concurrency int;
+    distance types.Distance;
+    // The refined centroids GetCentroids returns, kept until the next Run or
+    // a change to a setting the refinement uses.
+    refined [][]float64;
+    // The error of the last Map, MapParallel or Reduce.
+    err error;
 };
 
 func NewSimple(_rphashObject types.RPHashObject) *Simple {
@@ -18,123 +56,535 @@ func NewSimple(_rphashObject types.RPHashObject) *Simple {
         variance: 0,
         centroids: nil,
         rphashObject: _rphashObject,
+        kmeansIterations: defaults.KMeansIterations,
+        kmeansTolerance: defaults.KMeansTolerance,
+        candidateMultiplier: 1,
+        assigner: defaults.NewAssigner(),
+        logger: defaults.NewLogger(),
     };
 };
 
-// Map is doing the count.
+// Emit the phases of a run, and what the sketch, projector, k-means refinement
+// and RPHash object report, to logger. Passing nil silences it again.
+func (this *Simple) SetLogger(logger types.Logger) {
+    if logger == nil {
+        logger = defaults.NewLogger();
+    }
+    this.logger = logger;
+    this.shareLogger(this.rphashObject);
+};
+
+func (this *Simple) GetLogger() types.Logger {
+    return this.logger;
+};
+
+// Hand the logger to a part of the pipeline that can use it.
+func (this *Simple) shareLogger(part interface{}) {
+    if loggable, ok := part.(types.Loggable); ok {
+        loggable.SetLogger(this.logger);
+    }
+};
+
+// Set the maximum number of KMeans passes used to refine the centroids.
+// Defaults to 10000, which is only a guard against oscillation; lowering it
+// bounds the refinement runtime at the cost of less settled centroids.
+func (this *Simple) SetKMeansIterations(n int) {
+    this.kmeansIterations = n;
+    this.refined = nil;
+};
+
+func (this *Simple) GetKMeansIterations() int {
+    return this.kmeansIterations;
+};
+
+// Set how far the refined centroids may still be moving when KMeans stops.
+// Defaults to 0, refining until the assignments settle. Raising it trades
+// centroid stability between runs for an earlier stop.
+func (this *Simple) SetKMeansTolerance(t float64) {
+    this.kmeansTolerance = t;
+    this.refined = nil;
+};
+
+func (this *Simple) GetKMeansTolerance() float64 {
+    return this.kmeansTolerance;
+};
+
+// Set the distance the KMeans refinement, the dissolving of small clusters,
+// Assign and GetClassifier pick the nearest centroid by, such as
+// defaults.Cosine, which also weights the k-means++ seeding of the refinement.
+// Defaults to nil, Euclidean distance. The candidates Map and
+// Reduce produce are found by hashing either way; an assigner.Nearest given to
+// SetAssigner takes its own distance.
+func (this *Simple) SetDistance(distance types.Distance) {
+    this.distance = distance;
+    this.refined = nil;
+};
+
+func (this *Simple) GetDistance() types.Distance {
+    if this.distance == nil {
+        return defaults.Euclidean;
+    }
+    return this.distance;
+};
+
+// Set how many workers Reduce shards the vectors across. Each worker sums the
+// vectors it assigns on its own and the sums are merged at the end, so the
+// centroids match a single worker's up to floating point rounding.
+// Defaults to 0, one worker per CPU.
+func (this *Simple) SetConcurrency(n int) {
+    this.concurrency = n;
+};
+
+func (this *Simple) GetConcurrency() int {
+    return this.concurrency;
+};
+
+func (this *Simple) workers() int {
+    if this.concurrency < 1 {
+        return runtime.NumCPU();
+    }
+    return this.concurrency;
+};
+
+// Keep f * K ln K candidate buckets in the sketch during Map, instead of
+// K ln K, before Reduce narrows them to the K heaviest. Retaining more
+// candidates raises recall, as a heavy bucket that is seen late or shares its
+// counters with others is less likely to be evicted, at the cost of a larger
+// heavy hitter queue and more bucket means, each a vector of the data's
+// dimension. The count table itself does not grow.
+// At least K candidates are always kept.
+func (this *Simple) SetCandidateMultiplier(f float64) {
+    this.candidateMultiplier = f;
+};
+
+func (this *Simple) GetCandidateMultiplier() float64 {
+    return this.candidateMultiplier;
+};
+
+// The number of candidate buckets Map keeps.
+func (this *Simple) candidateCapacity(object types.RPHashObject) int {
+    k := float64(object.GetK());
+    capacity := int(this.candidateMultiplier * k * math.Log(k));
+    if capacity < object.GetK() {
+        capacity = object.GetK();
+    }
+    return capacity;
+};
+
+// Choose the space the k-means refinement of GetCentroids assigns in.
+// By default it works in the data's own space, which costs a full distance
+// per vector and mean on every pass. In projected space the vectors are first
+// projected to the dimension Map hashes in, the dimensionality of the object's
+// decoder or half the data's without one, which is cheaper for high
+// dimensional data but only approximates the true distances.
+// Either way the centroids returned are in the data's units.
+func (this *Simple) SetRefineInProjectedSpace(projected bool) {
+    this.refineProjected = projected;
+    this.refined = nil;
+};
+
+func (this *Simple) GetRefineInProjectedSpace() bool {
+    return this.refineProjected;
+};
+
+// Warm start from the centroids of an earlier run, such as yesterday's job.
+// They seed the k-means refinement, so centroid i of the result is the one
+// nearest to where initial centroid i led and cluster identities stay stable
+// from run to run. If the data fills fewer than K buckets the missing
+// centroids are taken from them too.
+// There must be K of them, each with the data's dimension. Nil clears them.
+func (this *Simple) SetInitialCentroids(centroids [][]float64) error {
+    if centroids != nil {
+        if len(centroids) != this.rphashObject.GetK() {
+            return fmt.Errorf("simple: %d initial centroids given for K %d", len(centroids), this.rphashObject.GetK());
+        }
+        for i, centroid := range centroids {
+            if len(centroid) != this.rphashObject.GetDimensions() {
+                return fmt.Errorf("simple: initial centroid %d has %d dimensions, expected %d", i, len(centroid), this.rphashObject.GetDimensions());
+            }
+        }
+    }
+    this.initialCentroids = centroids;
+    this.refined = nil;
+    return nil;
+};
+
+func (this *Simple) GetInitialCentroids() [][]float64 {
+    return this.initialCentroids;
+};
+
+// Dissolve clusters of fewer than n vectors after refinement, moving their
+// vectors to the nearest remaining centroid. This trims the long tail of noise
+// clusters but means GetCentroids may return fewer than K centroids.
+// It costs two more passes over the data; 0 (the default) disables it.
+func (this *Simple) SetMinClusterSize(n int) {
+    this.minClusterSize = n;
+    this.refined = nil;
+};
+
+func (this *Simple) GetMinClusterSize() int {
+    return this.minClusterSize;
+};
+
+// Set how Reduce matches vectors to the top centroids.
+// Defaults to an exact match of the vector's LSH bucket against the centroid ids.
+func (this *Simple) SetAssigner(assigner types.Assigner) {
+    this.assigner = assigner;
+};
+
+func (this *Simple) GetAssigner() types.Assigner {
+    return this.assigner;
+};
+
+// The projector the object holds, if any, or a new one from its random seed.
+// A held projector keeps whatever logger its owner gave it, as runs may share
+// it concurrently.
+func (this *Simple) projector(object types.RPHashObject, targetDimension int) types.Projector {
+    if holder, ok := object.(types.ProjectorHolder); ok {
+        if projector := holder.GetProjector(); projector != nil {
+            return projector;
+        }
+    }
+    projector := defaults.NewProjector(object.GetDimensions(), targetDimension, object.GetRandomSeed());
+    this.shareLogger(projector);
+    return projector;
+};
+
+// The dimension Map projects the vectors to, that of the decoder mapLSH
+// picks.
+func (this *Simple) hashDimension(object types.RPHashObject) int {
+    if decoder := object.GetDecoderType(); decoder != nil {
+        return decoder.GetDimensionality();
+    }
+    return object.GetDimensions() / 2;
+};
+
+// The LSH function Map hashes every vector with, decoding with the object's
+// decoder if it has one.
+func (this *Simple) mapLSH(object types.RPHashObject) types.LSH {
+    hash := defaults.NewHash(object.GetHashModulus());
+    decoder := object.GetDecoderType();
+    if decoder == nil {
+        targetDimension := int(math.Floor(float64(object.GetDimensions() / 2)));
+        numberOfRotations := 6;
+        numberOfSearches := 1;
+        decoder = defaults.NewDecoder(targetDimension, numberOfRotations, numberOfSearches);
+    }
+    return defaults.NewLSH(hash, decoder, this.projector(object, decoder.GetDimensionality()));
+};
+
+// Map is doing the count. Any error the iterator reports is kept for Err.
 func (this *Simple) Map() *Simple {
+    this.buckets, this.err = this.mapObject(this.rphashObject);
+    return this;
+};
+
+// The error the iterator reported during the last Map, MapParallel or
+// Reduce, which return the Simple for chaining rather than an error.
+func (this *Simple) Err() error {
+    return this.err;
+};
+
+// Count the buckets the vectors of object hash to, leaving the heaviest as
+// its previous top IDs. The running means of the buckets are returned with
+// any error the iterator reported.
+func (this *Simple) mapObject(object types.RPHashObject) (*bucketMeans, error) {
     runtime.GOMAXPROCS(runtime.NumCPU());
-    vecs := this.rphashObject.GetVectorIterator();
-    //var hashResult int64;
-    targetDimension := int(math.Floor(float64(this.rphashObject.GetDimensions() / 2)));
-    numberOfRotations := 6;
-    numberOfSearches := 1;
-    vec := vecs.Next();
-    hash := defaults.NewHash(this.rphashObject.GetHashModulus());
-    decoder := defaults.NewDecoder(targetDimension, numberOfRotations, numberOfSearches);
-    projector := defaults.NewProjector(this.rphashObject.GetDimensions(), decoder.GetDimensionality(), this.rphashObject.GetRandomSeed());
-    LSH := defaults.NewLSH(hash, decoder, projector);
-    // k := int(float64(this.rphashObject.GetK()) * math.Log(float64(this.rphashObject.GetK())));
-    CountMinSketch := defaults.NewCountMinSketch(this.rphashObject.GetK());
-    var vecCount = 0;
-    //1000 is an arbitrary comprise between speed and size should be tweeked later.
-    hashChannel := make(chan int64, 1000000000);
-    hashValues := make([]int64, this.rphashObject.NumDataPoints(), this.rphashObject.NumDataPoints());
-    for vecs.HasNext() {
-      go func(vec []float64, index int) {
-        // Project the Vector to lower dimension.
-        // Decode the new vector for meaningful integers
-        // Hash the new vector into a 64 bit int.
-        value := LSH.LSHHashSimple(vec)
-        hashValues[index] =  value;
-        hashChannel <- value;
-        //hashResult = LSH.LSHHashSimple(vec);
-        // Add it to the count min sketch to update frequencies.
-      }(vec, vecCount)
-      vecCount++;
-      vec = vecs.Next();
+    vecs := object.GetVectorIterator();
+    LSH := this.mapLSH(object);
+    capacity := this.candidateCapacity(object);
+    CountMinSketch := defaults.NewCountMinSketchSeeded(capacity, object.GetRandomSeed());
+    this.shareLogger(CountMinSketch);
+    // Hold the means of as many buckets as the sketch holds heavy hitters.
+    buckets := newBucketMeans(capacity);
+    // Only a block of vectors is held at a time, and of the rest only their
+    // hashes, as a stream only knows how many it holds once it has been read.
+    var hashValues []int64;
+    var block []mapItem;
+    for block = readMapBlock(vecs, block); len(block) > 0; block = readMapBlock(vecs, block) {
+      this.hashBlock(LSH, block);
+      // Count in the order the vectors were read, so a run with a fixed seed
+      // always fills the sketch and the buckets the same way.
+      for _, item := range block {
+        hashValues = append(hashValues, item.hash);
+        CountMinSketch.AddWeighted(item.hash, item.weight);
+        if item.weight > 0 {
+            buckets.add(item.hash, item.vec, float64(item.weight));
+        }
+      }
     }
     vecs.StoreLSHValues(hashValues);
-    //TODO should we Paralelize this? slowest loop but also have to wait for LSH Loops
-    for i := 0; i < vecCount; i++ {
-      hashResult := <- hashChannel;
-      CountMinSketch.Add(hashResult);
-    }
-    this.rphashObject.SetPreviousTopID(CountMinSketch.GetTop());
+    object.SetPreviousTopID(CountMinSketch.GetTop());
+    this.logger.Infof("simple: map hashed %d vectors into %d candidate buckets", len(hashValues), len(CountMinSketch.GetTop()));
+    err := iteratorErr(vecs);
     vecs.Reset();
-    return this;
+    return buckets, err;
+};
+
+// A single pass approximation of the centroids: the mean of the vectors Map
+// hashed into each of the K heaviest buckets, without the Reduce pass or the
+// k-means refinement. Map keeps a running mean for as many buckets as the
+// sketch holds heavy hitters, replacing the lightest when a new bucket
+// arrives, so a heavy bucket first seen late may be missing or hold only its
+// later vectors. Fewer than K centroids are returned when buckets are missing.
+// Map is run first if it has not been.
+func (this *Simple) ApproxCentroids() [][]float64 {
+    if this.buckets == nil {
+        if this.rphashObject.GetVectorIterator() == nil {
+            return nil;
+        }
+        this.Map();
+    }
+    // The top buckets are listed from the lightest to the heaviest.
+    top := this.rphashObject.GetPreviousTopID();
+    var centroids [][]float64;
+    for i := len(top) - 1; i >= 0 && len(centroids) < this.rphashObject.GetK(); i-- {
+        if mean, ok := this.buckets.mean(top[i]); ok {
+            centroids = append(centroids, mean);
+        }
+    }
+    return centroids;
 };
 
 // Reduce is finding out where the centroids are in respect to the real data.
+// Any error the iterator reports is kept for Err.
 func (this *Simple) Reduce() *Simple {
-    vecs := this.rphashObject.GetVectorIterator();
+    sizes, err := this.reduceObject(this.rphashObject);
+    if sizes != nil {
+        this.clusterSizes = sizes;
+    }
+    this.err = err;
+    return this;
+};
+
+// Add the mean of the vectors in each of the K heaviest buckets of object to
+// its centroids. The number of vectors in each is returned, nil if there were
+// no vectors, with any error the iterator reported or the object gave when a
+// centroid was added.
+func (this *Simple) reduceObject(object types.RPHashObject) ([]int64, error) {
+    vecs := object.GetVectorIterator();
     if !vecs.HasNext() {
-        return this;
+        return nil, nil;
     }
 
+    // Data that falls into fewer than K buckets yields fewer centroids.
     var centroids []types.Centroid;
-    for i := 0; i < this.rphashObject.GetK(); i++ {
+    // The top buckets are listed from the lightest to the heaviest.
+    previousTop := object.GetPreviousTopID();
+    for i := len(previousTop) - 1; i >= 0 && len(centroids) < object.GetK(); i-- {
         // Get the top centroids.
-        previousTop := this.rphashObject.GetPreviousTopID();
-        centroid := defaults.NewCentroidSimple(this.rphashObject.GetDimensions(), previousTop[i]);
+        centroid := defaults.NewCentroidSimple(object.GetDimensions(), previousTop[i]);
         centroids = append(centroids, centroid);
     }
 
-    // Iterate over the dataset and check CountMinSketch.
-    //Paralelize loop
-    var centriodChannels []chan []float64;
-    for i, _ := range centroids {
-      centriodChannels = append(centriodChannels, make(chan []float64, 10000));
-      go func(id int) {
-       for true {
-         newVec, ok := <- centriodChannels[id];
-         if !ok {
-           return;
-         }
-         centroids[id].UpdateVector(newVec);
-     }
-     }(i)
-    }
-    vec := vecs.Next();
-    var hashResult = int64(0);
+    // Iterate over the dataset, assigning each vector to the centroid of its
+    // bucket. The workers each sum their share of every block on their own.
+    accumulators := make([]*reduceAccumulator, this.workers());
+    for w := range accumulators {
+        accumulators[w] = newReduceAccumulator(len(centroids), object.GetDimensions());
+    }
+    block := make([]reduceItem, 0, blockSize);
     for vecs.HasNext() {
-        hashResult = vecs.PeakLSH();
-        // For each vector, check if it is a centroid.
-        for i, cent := range centroids {
-            // Get an idea where the LSH is in respect to the vector.
-            if cent.GetIDs().Contains(hashResult) {
-                //centriodChannels[i] <- vec;
-                centriodChannels[i]<- vec;
-                break;
-            }
+        vec := vecs.Next();
+        block = append(block, reduceItem{vec, vecs.PeakLSH(), vectorWeight(vecs)});
+        if len(block) == blockSize {
+            this.reduceBlock(block, centroids, accumulators);
+            block = block[:0];
         }
-        vec = vecs.Next();
     }
-    for _, channel := range centriodChannels {
-      close(channel);
+    this.reduceBlock(block, centroids, accumulators);
+    for _, accumulator := range accumulators[1:] {
+        accumulators[0].merge(accumulator);
     }
 
-    for _, cent := range centroids {
-        this.rphashObject.AddCentroid(cent.Centroid());
+    sizes := make([]int64, len(centroids));
+    empty := 0;
+    var addErr error;
+    for i := range centroids {
+        if err := addCentroid(object, accumulators[0].mean(i)); err != nil && addErr == nil {
+            addErr = err;
+        }
+        sizes[i] = accumulators[0].counts[i];
+        if sizes[i] == 0 {
+            empty++;
+        }
+    }
+    this.logger.Infof("simple: reduce assigned vectors to %d clusters", len(centroids));
+    if empty > 0 {
+        this.logger.Infof("simple: %d of %d clusters are empty after reduce", empty, len(centroids));
     }
 
+    err := iteratorErr(vecs);
     vecs.Reset();
-    return this;
+    if err == nil {
+        err = addErr;
+    }
+    return sizes, err;
 };
 
+// Add a centroid to object, returning the error of an object that checks it.
+func addCentroid(object types.RPHashObject, v []float64) error {
+    if checker, ok := object.(types.CentroidChecker); ok {
+        return checker.AddCentroidChecked(v);
+    }
+    object.AddCentroid(v);
+    return nil;
+};
+
+// The refined centroids, running the clustering first if it has not run.
+// The refinement is done once and its result kept, so repeated calls and
+// Assign are cheap; Run and the setters of the refinement, such as
+// SetKMeansIterations or SetDistance, discard it. The slice is shared
+// between calls and must not be changed.
 func (this *Simple) GetCentroids() [][]float64 {
     if this.centroids == nil {
-        this.Run();
+        if err := this.Run(); err != nil {
+            return nil;
+        }
+    }
+    if this.refined == nil {
+        this.refined = this.refine(this.rphashObject, this.centroids);
+    }
+    return this.refined;
+};
+
+// Perform the KMeans on the candidates Reduce found for object, topping them
+// up from the initial centroids if the data filled fewer than K buckets.
+func (this *Simple) refine(object types.RPHashObject, candidates [][]float64) [][]float64 {
+    if len(this.initialCentroids) == object.GetK() && len(candidates) < len(this.initialCentroids) {
+        candidates = append(append([][]float64{}, candidates...), this.initialCentroids[len(candidates):]...);
+    }
+    projectionDimension := 0;
+    if this.refineProjected && object.GetDimensions() > 1 {
+        projectionDimension = this.hashDimension(object);
+    }
+    // Without initial centroids the refinement starts from k-means++ means
+    // drawn with the object's seed, so a run is repeatable.
+    means := this.initialCentroids;
+    if len(means) != object.GetK() {
+        means = defaults.PlusPlusMeans(object.GetK(), candidates, object.GetRandomSeed(), this.distance);
+    }
+    kmeans := defaults.NewKMeansSeeded(object.GetK(), candidates, means,
+        this.kmeansIterations, this.kmeansTolerance, projectionDimension, object.GetRandomSeed(), this.distance);
+    this.shareLogger(kmeans);
+    result := kmeans.GetCentroids();
+    this.logger.Infof("simple: refined %d candidates into %d centroids", len(candidates), len(result));
+    if this.minClusterSize > 1 {
+        refined := len(result);
+        result = defaults.DissolveSmallClusters(object.GetVectorIterator(), result, this.minClusterSize, this.distance);
+        if dropped := refined - len(result); dropped > 0 {
+            this.logger.Infof("simple: dropped %d clusters smaller than %d vectors", dropped, this.minClusterSize);
+        }
+    }
+    return result;
+};
+
+// A classifier over the final centroids with the distance statistics of each
+// cluster, ready for Predict and AnomalyScore. The centroids only settle
+// after Reduce and the KMeans refinement, so the distances are gathered in
+// one more pass over the data.
+func (this *Simple) GetClassifier() *classifier.Classifier {
+    centroids := this.GetCentroids();
+    model := classifier.NewClassifier(centroids);
+    model.SetDistance(this.distance);
+    if vecs := this.rphashObject.GetVectorIterator(); vecs != nil {
+        model.ObserveIterator(vecs);
+    }
+    return model;
+};
+
+// The index of the centroid nearest to v, running the clustering first if it
+// has not run. It is -1 when there are no centroids, such as after an empty
+// stream, or when v does not have the centroids' dimension.
+func (this *Simple) Assign(v []float64) int {
+    return this.AssignAll([][]float64{v})[0];
+};
+
+// Assign every vector of vs, refining the centroids only once. See Assign.
+func (this *Simple) AssignAll(vs [][]float64) []int {
+    centroids := this.GetCentroids();
+    result := make([]int, len(vs));
+    for i, v := range vs {
+        if len(centroids) == 0 || len(v) != len(centroids[0]) {
+            result[i] = -1;
+            continue;
+        }
+        result[i] = utils.FindNearest(v, centroids, this.GetDistance());
     }
-    // Perform the KMeans on the centroids.
-    result := defaults.NewKMeansSimple(this.rphashObject.GetK(), this.centroids).GetCentroids();
     return result;
 };
 
-func (this *Simple) Run() {
-    this.Map().Reduce();
+// The number of vectors Reduce assigned to each centroid, in centroid order.
+func (this *Simple) GetClusterSizes() []int64 {
+    if this.centroids == nil {
+        this.Run();
+    }
+    return this.clusterSizes;
+};
+
+// Summarize the cluster sizes from the last Reduce, running it if needed.
+func (this *Simple) SizeDistribution() *SizeDistribution {
+    return NewSizeDistribution(this.GetClusterSizes());
+};
+
+// Run returns any error the iterator reports while reading. It leaves the
+// iterator open and rewound, as GetCentroids and GetClassifier may read it
+// again; whoever opened a file or connection closes it (see
+// types.ClosableIterator).
+func (this *Simple) Run() error {
+    buckets, sizes, err := this.run(this.rphashObject);
+    if err != nil {
+        return err;
+    }
+    this.buckets, this.clusterSizes = buckets, sizes;
     this.centroids = this.rphashObject.GetCentroids();
-}
+    this.refined = nil;
+    return nil;
+};
+
+// Map and Reduce over object, returning the bucket means and cluster sizes.
+// The candidate centroids are left in the object.
+func (this *Simple) run(object types.RPHashObject) (*bucketMeans, []int64, error) {
+    if object.GetK() < 1 {
+        return nil, nil, fmt.Errorf("simple: K must be at least 1, got %d", object.GetK());
+    }
+    vecs := object.GetVectorIterator();
+    if vecs == nil {
+        return nil, nil, errors.New("simple: the RPHash object has no vectors");
+    }
+    if !vecs.HasNext() {
+        return nil, nil, errors.New("simple: the vector iterator is empty");
+    }
+    this.logger.Infof("simple: map phase");
+    buckets, err := this.mapObject(object);
+    if err != nil {
+        return nil, nil, err;
+    }
+    this.logger.Infof("simple: reduce phase");
+    sizes, err := this.reduceObject(object);
+    if err != nil {
+        return nil, nil, err;
+    }
+    return buckets, sizes, nil;
+};
+
+// RunWith runs the clustering with the seed overridden for this run only and
+// returns its refined centroids. The run reads its own copy of the iterator
+// and keeps its results to itself, so neither the Simple nor its RPHash
+// object is changed and concurrent RunWith calls on one Simple are safe.
+// The iterator must be a types.CopyableIterator. See RunOptions for why
+// projections and blurs cannot be overridden.
+func (this *Simple) RunWith(options RunOptions) ([][]float64, error) {
+    object, err := newRunObject(this.rphashObject, options);
+    if err != nil {
+        return nil, err;
+    }
+    defer object.close();
+    if _, _, err := this.run(object); err != nil {
+        return nil, err;
+    }
+    return this.refine(object, object.GetCentroids()), nil;
+};
 
 func (this *Simple) GetRPHash() types.RPHashObject {
     return this.rphashObject;
@@ -0,0 +1,57 @@
+package simple;
+
+import (
+    "math"
+    "sort"
+);
+
+type int64Slice []int64;
+
+func (this int64Slice) Len() int { return len(this); };
+func (this int64Slice) Less(i, j int) bool { return this[i] < this[j]; };
+func (this int64Slice) Swap(i, j int) { this[i], this[j] = this[j], this[i]; };
+
+// SizeDistribution summarizes how many vectors landed in each cluster.
+// One giant cluster beside many tiny or empty ones suggests K, the projection
+// dimension, or the sketch size needs adjusting.
+type SizeDistribution struct {
+    Sizes []int64;
+    Min int64;
+    Max int64;
+    Median float64;
+    Empty int;
+};
+
+func NewSizeDistribution(sizes []int64) *SizeDistribution {
+    sorted := make([]int64, len(sizes));
+    copy(sorted, sizes);
+    sort.Sort(int64Slice(sorted));
+    result := &SizeDistribution{
+        Sizes: sorted,
+    };
+    if len(sorted) == 0 {
+        return result;
+    }
+    result.Min = sorted[0];
+    result.Max = sorted[len(sorted) - 1];
+    result.Median = result.Percentile(50);
+    for _, size := range sorted {
+        if size == 0 {
+            result.Empty++;
+        }
+    }
+    return result;
+};
+
+// The p-th percentile (0 to 100) of the cluster sizes, interpolating linearly
+// between the two nearest sizes.
+func (this *SizeDistribution) Percentile(p float64) float64 {
+    if len(this.Sizes) == 0 {
+        return 0;
+    }
+    rank := math.Max(0, math.Min(100, p)) / 100 * float64(len(this.Sizes) - 1);
+    lower := int(math.Floor(rank));
+    upper := int(math.Ceil(rank));
+    fraction := rank - float64(lower);
+    return float64(this.Sizes[lower]) * (1 - fraction) + float64(this.Sizes[upper]) * fraction;
+};
@@ -1,6 +1,7 @@
 package stream;
 
 import (
+    "errors"
     "math/rand"
     "github.com/wenkesj/rphash/utils"
     "github.com/wenkesj/rphash/types"
@@ -19,6 +20,7 @@ type Stream struct {
     projector types.Projector;
     hash types.Hash;
     varTracker types.StatTest;
+    vectorsProcessed int64;
 };
 
 func NewStream(_rphashObject types.RPHashObject) *Stream {
@@ -47,6 +49,7 @@ func NewStream(_rphashObject types.RPHashObject) *Stream {
         decoder: _decoder,
         projector: _projector,
         varTracker: _statTest,
+        vectorsProcessed: 0,
     };
 };
 
@@ -54,13 +57,19 @@ func (this *Stream) AddVectorOnlineStep(vec []float64) int64 {
     var hash []int64;
     c := defaults.NewCentroidStream(vec);
 
+    // Vectors the tracker skips report a variance of zero, which would
+    // collapse the decoder, so only sampled vectors update it.
     tmpvar := this.varTracker.UpdateVarianceSample(vec);
-    if this.variance != tmpvar {
+    if tmpvar > 0 && this.variance != tmpvar {
         for _, lsh := range this.lshGroup {
             lsh.UpdateDecoderVariance(tmpvar);
         }
         this.variance = tmpvar;
     }
+    this.vectorsProcessed++;
+    if recorder, ok := this.rphashObject.(types.VarianceRecorder); ok {
+        recorder.RecordVariance(this.vectorsProcessed, this.decoder.GetVariance());
+    }
     for _, lsh := range this.lshGroup {
         hash = lsh.LSHHashStream(vec, this.rphashObject.GetNumberOfBlurs());
         for _, h := range hash {
@@ -100,9 +109,13 @@ func (this *Stream) GetCentroidsOfflineStep() [][]float64 {
     return this.centroids;
 };
 
-func (this *Stream) Run() {
+func (this *Stream) Run() error {
     vecs := this.rphashObject.GetVectorIterator();
+    if vecs == nil {
+        return errors.New("stream: the RPHash object has no vectors");
+    }
     for vecs.HasNext() {
         this.AddVectorOnlineStep(vecs.Next());
     }
+    return nil;
 };
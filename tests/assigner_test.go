@@ -0,0 +1,64 @@
+package tests;
+
+import (
+  "testing"
+  "github.com/wenkesj/rphash/assigner"
+  "github.com/wenkesj/rphash/defaults"
+  "github.com/wenkesj/rphash/itemset"
+  "github.com/wenkesj/rphash/reader"
+  "github.com/wenkesj/rphash/simple"
+  "github.com/wenkesj/rphash/types"
+);
+
+func TestBucketAssigner(t *testing.T) {
+  centroids := []types.Centroid{
+    itemset.NewCentroidSimple(2, 11),
+    itemset.NewCentroidSimple(2, 22),
+  };
+  centroids[1].AddID(33);
+  bucket := assigner.NewBucket();
+  vec := []float64{0, 0};
+  if i := bucket.Assign(vec, 22, centroids); i != 1 {
+    t.Errorf("Hash 22 should match the second centroid's id. Actual index %v.", i);
+  }
+  if i := bucket.Assign(vec, 33, centroids); i != 1 {
+    t.Errorf("Hash 33 should match the second centroid's added id. Actual index %v.", i);
+  }
+  if i := bucket.Assign(vec, 44, centroids); i != -1 {
+    t.Errorf("A hash no centroid holds should be left unassigned. Actual index %v.", i);
+  }
+};
+
+func TestNearestAssigner(t *testing.T) {
+  centroids := []types.Centroid{
+    itemset.NewCentroidSimple(2, 11),
+    itemset.NewCentroidSimple(2, 22),
+  };
+  nearest := assigner.NewNearest([][]float64{{0, 0}, {10, 10}});
+  if i := nearest.Assign([]float64{9, 8}, 11, centroids); i != 1 {
+    t.Errorf("[9 8] should be nearest the second reference regardless of its hash. Actual index %v.", i);
+  }
+  if i := nearest.Assign([]float64{1, -1}, 22, centroids); i != 0 {
+    t.Errorf("[1 -1] should be nearest the first reference regardless of its hash. Actual index %v.", i);
+  }
+  if i := assigner.NewNearest(nil).Assign([]float64{1, 1}, 11, centroids); i != -1 {
+    t.Errorf("Without references nothing should be assigned. Actual index %v.", i);
+  }
+  angular := assigner.NewNearest([][]float64{{1, 0}, {10, 10}});
+  angular.SetDistance(defaults.Cosine);
+  if i := angular.Assign([]float64{20, 1}, 22, centroids); i != 0 {
+    t.Errorf("By cosine distance [20 1] should be nearest the first reference. Actual index %v.", i);
+  }
+};
+
+func TestSimpleSetAssigner(t *testing.T) {
+  simpleObject := simple.NewSimple(reader.NewSimpleArray([][]float64{{1, 2}, {3, 4}}, 1));
+  if _, ok := simpleObject.GetAssigner().(*assigner.Bucket); !ok {
+    t.Errorf("Simple should default to the bucket assigner, got %T.", simpleObject.GetAssigner());
+  }
+  nearest := assigner.NewNearest([][]float64{{0, 0}});
+  simpleObject.SetAssigner(nearest);
+  if simpleObject.GetAssigner() != nearest {
+    t.Error("SetAssigner should replace the assigner used by Reduce.");
+  }
+};
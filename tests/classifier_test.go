@@ -0,0 +1,162 @@
+package tests;
+
+import (
+    "math"
+    "math/rand"
+    "testing"
+    "github.com/wenkesj/rphash/classifier"
+    "github.com/wenkesj/rphash/utils"
+);
+
+func randomMatrix(random *rand.Rand, rows, dimension int) [][]float64 {
+    matrix := make([][]float64, rows);
+    for i := range matrix {
+        matrix[i] = make([]float64, dimension);
+        for d := range matrix[i] {
+            matrix[i][d] = random.NormFloat64();
+        }
+    }
+    return matrix;
+};
+
+func TestClassifierIndex(t *testing.T) {
+    random := rand.New(rand.NewSource(3));
+    centroids := randomMatrix(random, 500, 4);
+    // A duplicate centroid, which the index must break ties on like the scan.
+    centroids = append(centroids, centroids[10]);
+    queries := append(randomMatrix(random, 200, 4), centroids[10], centroids[42]);
+
+    scan := classifier.NewClassifier(centroids);
+    indexed := classifier.NewClassifier(centroids);
+    indexed.BuildIndex();
+    if scan.HasIndex() || !indexed.HasIndex() {
+        t.Fatal("Only the classifier that built an index should have one.");
+    }
+    for i, query := range queries {
+        expected := utils.FindNearestDistance(query, centroids);
+        if actual := scan.Predict(query); actual != expected {
+            t.Errorf("Query %v: the linear scan should predict %v, Actual %v.", i, expected, actual);
+        }
+        if actual := indexed.Predict(query); actual != expected {
+            t.Errorf("Query %v: the index should predict %v, Actual %v.", i, expected, actual);
+        }
+    }
+
+    empty := classifier.NewClassifier(nil);
+    empty.BuildIndex();
+    if empty.Predict([]float64{1, 2, 3, 4}) != -1 {
+        t.Error("A classifier without centroids should predict -1.");
+    }
+};
+
+func TestClassifierDistance(t *testing.T) {
+    centroids := [][]float64{{1, 0}, {10, 10}};
+    query := []float64{20, 1};
+    for _, index := range []bool{false, true} {
+        model := classifier.NewClassifier(centroids);
+        if index {
+            model.BuildIndex();
+        }
+        if label := model.Predict(query); label != 1 {
+            t.Errorf("By Euclidean distance %v should be nearest centroid 1, Actual %d.", query, label);
+        }
+        // The k-d tree prunes by Euclidean distance, so another distance
+        // must scan every centroid even with the index built.
+        model.SetDistance(utils.CosineDistance);
+        label, distance := model.PredictWithDistance(query);
+        if label != 0 || distance != utils.CosineDistance(query, centroids[0]) {
+            t.Errorf("By cosine distance %v should be nearest centroid 0 at %v, Actual %d at %v.", query, utils.CosineDistance(query, centroids[0]), label, distance);
+        }
+    }
+};
+
+func benchmarkClassifier(b *testing.B, index bool) {
+    random := rand.New(rand.NewSource(0));
+    centroids := randomMatrix(random, 5000, 4);
+    queries := randomMatrix(random, 1000, 4);
+    model := classifier.NewClassifier(centroids);
+    if index {
+        model.BuildIndex();
+    }
+    b.ResetTimer();
+    for i := 0; i < b.N; i++ {
+        model.Predict(queries[i % len(queries)]);
+    }
+};
+
+func BenchmarkClassifierLinearScan(b *testing.B) {
+    benchmarkClassifier(b, false);
+};
+
+func BenchmarkClassifierIndex(b *testing.B) {
+    benchmarkClassifier(b, true);
+};
+
+func TestClassifierAnomalyScore(t *testing.T) {
+    model := classifier.NewClassifier([][]float64{{0, 0}, {10, 0}});
+    if !math.IsNaN(model.AnomalyScore([]float64{1, 0})) {
+        t.Error("A cluster with nothing observed should score NaN.");
+    }
+    for _, vec := range [][]float64{{1, 0}, {0, 3}, {-2, 0}, {0, -2}, {9, 0}, {11, 0}} {
+        model.Observe(vec);
+    }
+    stats := model.GetClusterStats();
+    if stats[0].Count != 4 || stats[0].Mean != 2 || math.Abs(stats[0].Std() - math.Sqrt(0.5)) > 1e-12 {
+        t.Errorf("Expected 4 distances with mean 2 and std %v, Actual %+v.", math.Sqrt(0.5), stats[0]);
+    }
+    if score := model.AnomalyScore([]float64{0, 2}); score != 0 {
+        t.Errorf("A point at the mean distance should score 0, Actual %v.", score);
+    }
+    if score := model.AnomalyScore([]float64{0, 5}); math.Abs(score - 3 / math.Sqrt(0.5)) > 1e-12 {
+        t.Errorf("Expected a score of %v, Actual %v.", 3 / math.Sqrt(0.5), score);
+    }
+    // The second cluster has no spread, every member lies at distance 1.
+    if score := model.AnomalyScore([]float64{10, 1}); score != 0 {
+        t.Errorf("A point at the only observed distance should score 0, Actual %v.", score);
+    }
+    if score := model.AnomalyScore([]float64{10, 3}); !math.IsInf(score, 1) {
+        t.Errorf("A farther point in a cluster without spread should score +Inf, Actual %v.", score);
+    }
+};
+
+func TestClassifierPredictWithDistance(t *testing.T) {
+    random := rand.New(rand.NewSource(5));
+    centroids := randomMatrix(random, 50, 3);
+    queries := randomMatrix(random, 40, 3);
+    scan := classifier.NewClassifier(centroids);
+    indexed := classifier.NewClassifier(centroids);
+    indexed.BuildIndex();
+    for _, model := range []*classifier.Classifier{scan, indexed} {
+        labels, distances := model.PredictAllWithDistance(queries);
+        if len(labels) != len(queries) || len(distances) != len(queries) {
+            t.Fatalf("Expected %v labels and distances, Actual %v and %v.", len(queries), len(labels), len(distances));
+        }
+        for i, query := range queries {
+            label, distance := model.PredictWithDistance(query);
+            if label != labels[i] || distance != distances[i] {
+                t.Errorf("Query %v: the batch gave (%v, %v), the single form (%v, %v).", i, labels[i], distances[i], label, distance);
+            }
+            if expected := utils.Distance(query, centroids[label]); distance != expected {
+                t.Errorf("Query %v: expected the distance %v to centroid %v, Actual %v.", i, expected, label, distance);
+            }
+        }
+    }
+    if label, distance := classifier.NewClassifier(nil).PredictWithDistance(queries[0]); label != -1 || !math.IsInf(distance, 1) {
+        t.Errorf("Without centroids expected (-1, +Inf), Actual (%v, %v).", label, distance);
+    }
+};
+
+func TestClassifierTieBreak(t *testing.T) {
+    centroids := [][]float64{{0, 1}, {1, 0}, {0, -1}, {-1, 0}, {0, 1}};
+    queries := [][]float64{{0, 0}, {1, 1}, {-1, -1}, {0, 1}};
+    expected := []int{0, 0, 2, 0};
+    indexed := classifier.NewClassifier(centroids);
+    indexed.BuildIndex();
+    for _, model := range []*classifier.Classifier{classifier.NewClassifier(centroids), indexed} {
+        for i, query := range queries {
+            if label := model.Predict(query); label != expected[i] {
+                t.Errorf("Query %v with index %v: a tie should go to centroid %v, Actual %v.", query, model.HasIndex(), expected[i], label);
+            }
+        }
+    }
+};
@@ -1,8 +1,13 @@
 package tests;
 
 import (
+    "math"
+    "math/rand"
+    "reflect"
     "testing"
     "github.com/wenkesj/rphash/clusterer"
+    "github.com/wenkesj/rphash/defaults"
+    "github.com/wenkesj/rphash/utils"
 );
 
 func TestClustererUniformVectors(t *testing.T) {
@@ -41,3 +46,266 @@ func TestClustererUniformVectors(t *testing.T) {
     }
   }
 };
+
+func TestClustererMaxIterations(t *testing.T) {
+  // Interleaved so the initial sequential split puts both groups in each cluster.
+  data := [][]float64{{0}, {10}, {1}, {11}, {2}, {12}, {3}, {13}};
+
+  unrefined := clusterer.NewKMeansSimple(2, data);
+  unrefined.SetMaxIterations(0);
+  logger := &recordingLogger{};
+  unrefined.SetLogger(logger);
+  result := unrefined.GetCentroids();
+  if result[0][0] != 5.5 || result[1][0] != 7.5 {
+    t.Errorf("Zero iterations should leave the means of the initial split. Expected [5.5 7.5], Actual %v.", result);
+  }
+  if !logger.contains("kmeans: stopped after the maximum of 0 iterations") {
+    t.Errorf("Stopping before the assignments settle should be warned about, Actual %q.", logger.messages);
+  }
+
+  refined := clusterer.NewKMeansSimple(2, data);
+  if refined.GetMaxIterations() != clusterer.DefaultMaxIterations {
+    t.Errorf("Expected the default of %v iterations, Actual %v.", clusterer.DefaultMaxIterations, refined.GetMaxIterations());
+  }
+  result = refined.GetCentroids();
+  if result[0][0] != 1.5 || result[1][0] != 11.5 {
+    t.Errorf("Refinement did not separate the groups. Expected [1.5 11.5], Actual %v.", result);
+  }
+};
+
+func TestClustererTolerance(t *testing.T) {
+  data := [][]float64{{19}, {7}, {17}, {12}, {15}, {1}, {13}, {15}};
+
+  refined := clusterer.NewKMeansSimple(2, data);
+  expected := refined.GetCentroids();
+
+  // Any movement is within tolerance, so the first pass is the last.
+  loose := clusterer.NewKMeansSimple(2, data);
+  loose.SetTolerance(100);
+  result := loose.GetCentroids();
+  if result[0][0] == expected[0][0] && result[1][0] == expected[1][0] {
+    t.Errorf("A tolerance larger than the data should stop before the means settle at %v, got %v.", expected, result);
+  }
+};
+
+func TestDissolveSmallClusters(t *testing.T) {
+  data := [][]float64{{0}, {1}, {-1}, {10}, {11}, {9}, {12}, {99}};
+  centroids := [][]float64{{0}, {10.5}, {99}};
+  vecs := utils.NewIterator(data);
+
+  result := clusterer.DissolveSmallClusters(vecs, centroids, 2);
+  if len(result) != 2 {
+    t.Fatalf("The single vector cluster should be dissolved, leaving 2 centroids. Actual %v.", result);
+  }
+  if result[0][0] != 0 {
+    t.Errorf("A centroid that gained nothing should be unchanged. Expected 0, Actual %v.", result[0][0]);
+  }
+  // (10 + 11 + 9 + 12 + 99) / 5
+  if math.Abs(result[1][0] - 28.2) > 1e-9 {
+    t.Errorf("The nearest centroid should absorb the dissolved vector. Expected 28.2, Actual %v.", result[1][0]);
+  }
+  if vecs.HasNext() && vecs.Next()[0] != 0 {
+    t.Error("The iterator should be reset after dissolving.");
+  }
+
+  if result := clusterer.DissolveSmallClusters(utils.NewIterator(data), centroids, 1); len(result) != 3 {
+    t.Errorf("A minimum of 1 should keep every cluster, Actual %v.", result);
+  }
+  if result := clusterer.DissolveSmallClusters(utils.NewIterator(data), centroids, 100); len(result) != 1 || result[0][0] != 10.5 {
+    t.Errorf("When every cluster is too small the largest should be kept, Actual %v.", result);
+  }
+};
+
+func TestDissolveSmallClustersWithDistance(t *testing.T) {
+  data := [][]float64{{1, 0}, {2, 0}, {3, 0}, {0, 1}, {0, 2}, {40, 50}};
+  centroids := [][]float64{{2, 0}, {0, 1.5}, {40, 50}};
+
+  // [40 50] is slightly nearer [2 0], but points the way of [0 1.5].
+  euclidean := clusterer.DissolveSmallClusters(utils.NewIterator(data), centroids, 2);
+  if len(euclidean) != 2 || euclidean[0][0] != 46.0 / 4 || euclidean[1][1] != 1.5 {
+    t.Errorf("By Euclidean distance [40 50] should join the first cluster, Actual %v.", euclidean);
+  }
+  cosine := clusterer.DissolveSmallClustersWithDistance(utils.NewIterator(data), centroids, 2, defaults.Cosine);
+  if len(cosine) != 2 || cosine[0][0] != 2 || math.Abs(cosine[1][0] - 40.0 / 3) > 1e-9 || math.Abs(cosine[1][1] - 53.0 / 3) > 1e-9 {
+    t.Errorf("By cosine distance [40 50] should join the second cluster, Actual %v.", cosine);
+  }
+};
+
+func TestClustererInitialMeans(t *testing.T) {
+  data := [][]float64{{0}, {1}, {10}, {11}, {20}, {21}};
+  seeds := [][]float64{{19}, {-1}, {12}};
+  kmeans := clusterer.NewKMeansSimple(3, data);
+  kmeans.SetInitialMeans(seeds);
+  result := kmeans.GetCentroids();
+  expected := []float64{20.5, 0.5, 10.5};
+  for i := range expected {
+    if result[i][0] != expected[i] {
+      t.Errorf("Mean %v should grow out of its seed %v. Expected %v, Actual %v.", i, seeds[i], expected[i], result[i]);
+    }
+  }
+};
+
+func TestClustererProjection(t *testing.T) {
+  var dimension = 40;
+  var data [][]float64;
+  for i := 0; i < 20; i++ {
+    vec := make([]float64, dimension);
+    for j := range vec {
+      vec[j] = float64(i % 2) * 10 + float64(j % 3) * 0.1;
+    }
+    data = append(data, vec);
+  }
+  // Keep each group together for the sequential start.
+  data = append(evenRows(data, 0), evenRows(data, 1)...);
+
+  kmeans := clusterer.NewKMeansSimple(2, data);
+  kmeans.SetProjection(8, 1);
+  result := kmeans.GetCentroids();
+  if len(result) != 2 || len(result[0]) != dimension {
+    t.Fatalf("Means should be in the data's %v dimensions, Actual %v.", dimension, result);
+  }
+  for i, expected := range []float64{0, 10} {
+    if math.Abs(result[i][0] - expected) > 1e-9 {
+      t.Errorf("Mean %v should start at %v, Actual %v.", i, expected, result[i][0]);
+    }
+  }
+};
+
+// Every other row of data, starting from offset.
+func evenRows(data [][]float64, offset int) [][]float64 {
+  var rows [][]float64;
+  for i := offset; i < len(data); i += 2 {
+    rows = append(rows, data[i]);
+  }
+  return rows;
+};
+
+func TestClustererTieBreak(t *testing.T) {
+  // {0} lies halfway between the seeds and should join the first one.
+  data := [][]float64{{-1}, {1}, {0}, {3}};
+  for run := 0; run < 5; run++ {
+    kmeans := clusterer.NewKMeansSimple(2, data);
+    kmeans.SetInitialMeans([][]float64{{-1}, {1}});
+    // Keep the means of the first assignment.
+    kmeans.SetMaxIterations(0);
+    result := kmeans.GetCentroids();
+    if result[0][0] != -0.5 || result[1][0] != 2 {
+      t.Fatalf("Run %v: the tied point should join the lowest mean, expected [[-0.5] [2]], Actual %v.", run, result);
+    }
+  }
+  centroids := [][]float64{{0, 1}, {1, 0}, {0, -1}, {-1, 0}};
+  if nearest := utils.FindNearestDistance([]float64{0, 0}, centroids); nearest != 0 {
+    t.Errorf("A point equally near every centroid should go to the first, Actual %v.", nearest);
+  }
+  if nearest := utils.FindNearestDistance([]float64{-1, -1}, centroids); nearest != 2 {
+    t.Errorf("A point equally near the last two centroids should go to the lower index, Actual %v.", nearest);
+  }
+};
+
+func withinClusterSquares(data, centroids [][]float64) float64 {
+  var sum float64;
+  for _, vec := range data {
+    d := utils.Distance(vec, centroids[utils.FindNearestDistance(vec, centroids)]);
+    sum += d * d;
+  }
+  return sum;
+};
+
+func TestKMeansPlusPlus(t *testing.T) {
+  // Eight tight, well separated blobs.
+  random := rand.New(rand.NewSource(4));
+  var data [][]float64;
+  for blob := 0; blob < 8; blob++ {
+    for i := 0; i < 25; i++ {
+      data = append(data, []float64{float64(blob % 4) * 20 + random.NormFloat64(), float64(blob / 4) * 20 + random.NormFloat64()});
+    }
+  }
+  k, runs := 8, 20;
+  var plusPlus, uniform float64;
+  for seed := int64(0); seed < int64(runs); seed++ {
+    plusPlus += withinClusterSquares(data, defaults.NewKMeansPP(k, data, seed, nil).GetCentroids()) / float64(runs);
+
+    picks := rand.New(rand.NewSource(seed)).Perm(len(data))[:k];
+    means := make([][]float64, k);
+    for i, pick := range picks {
+      means[i] = data[pick];
+    }
+    kmeans := clusterer.NewKMeansSimple(k, data);
+    kmeans.SetInitialMeans(means);
+    uniform += withinClusterSquares(data, kmeans.GetCentroids()) / float64(runs);
+  }
+  if plusPlus >= uniform {
+    t.Errorf("k-means++ seeding should find tighter clusters than uniform seeding, WCSS %v against %v.", plusPlus, uniform);
+  }
+  t.Logf("Mean WCSS over %d seeds, k-means++ %v, uniform %v.", runs, plusPlus, uniform);
+
+  first, second := clusterer.PlusPlusMeans(k, data, 9, nil), clusterer.PlusPlusMeans(k, data, 9, nil);
+  if !reflect.DeepEqual(first, second) {
+    t.Error("The same seed should choose the same means.");
+  }
+  if means := clusterer.PlusPlusMeans(3, data[:2], 9, nil); means != nil {
+    t.Errorf("Expected no means from fewer vectors than k, Actual %v.", means);
+  }
+};
+
+func TestKMeansPlusPlusDistance(t *testing.T) {
+  // Two rays along the axes. By cosine distance every vector sits on the
+  // mean of its own ray, so the second mean must come from the other ray.
+  var data [][]float64;
+  for length := 1.0; length <= 20; length++ {
+    data = append(data, []float64{length, 0}, []float64{0, length});
+  }
+  for seed := int64(0); seed < 20; seed++ {
+    means := clusterer.PlusPlusMeans(2, data, seed, defaults.Cosine);
+    if (means[0][0] == 0) == (means[1][0] == 0) {
+      t.Errorf("Seed %d: expected one mean on each ray by cosine distance, Actual %v.", seed, means);
+    }
+  }
+};
+
+func TestKMeansDistance(t *testing.T) {
+  // Two rays from the origin, 60 degrees apart, with lengths from 1 to 20 on
+  // each, so the populations differ in angle but not in magnitude.
+  random := rand.New(rand.NewSource(6));
+  angles := []float64{0.2, 1.25};
+  var data [][]float64;
+  var population []int;
+  for i := 0; i < 200; i++ {
+    ray := i % 2;
+    length := 1 + random.Float64() * 19;
+    angle := angles[ray] + random.NormFloat64() * 0.03;
+    data = append(data, []float64{length * math.Cos(angle), length * math.Sin(angle)});
+    population = append(population, ray);
+  }
+  separates := func(distance func(a, b []float64) float64) bool {
+    centroids := defaults.NewKMeans(2, data, defaults.KMeansIterations, defaults.KMeansTolerance, distance).GetCentroids();
+    first := utils.FindNearest(data[0], centroids, distance);
+    for i, vec := range data {
+      if (utils.FindNearest(vec, centroids, distance) == first) != (population[i] == population[0]) {
+        return false;
+      }
+    }
+    return true;
+  };
+  if !separates(defaults.Cosine) {
+    t.Error("Cosine distance should split the vectors by angle.");
+  }
+  if separates(defaults.Euclidean) {
+    t.Error("Euclidean distance should mix short vectors of both rays.");
+  }
+
+  kmeans := clusterer.NewKMeansSimple(2, data);
+  kmeans.SetDistance(nil);
+  if kmeans.GetDistance()([]float64{0, 0}, []float64{3, 4}) != 5 {
+    t.Error("A nil distance should fall back to Euclidean distance.");
+  }
+  if d := defaults.SquaredEuclidean([]float64{0, 0}, []float64{3, 4}); d != 25 {
+    t.Errorf("Expected a squared distance of 25, Actual %v.", d);
+  }
+  if d := defaults.Cosine([]float64{1, 0}, []float64{5, 0}); d != 0 {
+    t.Errorf("Parallel vectors should be at cosine distance 0, Actual %v.", d);
+  }
+  if d := defaults.Cosine([]float64{1, 0}, []float64{0, 2}); d != 1 {
+    t.Errorf("Orthogonal vectors should be at cosine distance 1, Actual %v.", d);
+  }
+};
@@ -0,0 +1,194 @@
+package tests;
+
+import (
+  "compress/gzip"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "reflect"
+  "strconv"
+  "strings"
+  "testing"
+  "github.com/wenkesj/rphash/defaults"
+  "github.com/wenkesj/rphash/reader"
+  "github.com/wenkesj/rphash/simple"
+  "github.com/wenkesj/rphash/types"
+);
+
+var fileIteratorExpected = [][]float64{{1, 2, 3}, {4, 5, 6}, {7.5, -8, 9}};
+
+func writeTestFile(t *testing.T, dir, name, contents string, compress bool) string {
+  path := filepath.Join(dir, name);
+  file, err := os.Create(path);
+  if err != nil {
+    t.Fatal(err);
+  }
+  defer file.Close();
+  if compress {
+    writer := gzip.NewWriter(file);
+    writer.Write([]byte(contents));
+    writer.Close();
+  } else {
+    file.Write([]byte(contents));
+  }
+  return path;
+}
+
+// Data as CSV rows that read back to exactly the same vectors.
+func csvRows(data [][]float64) string {
+  rows := make([]string, len(data));
+  for i, vec := range data {
+    row := make([]string, len(vec));
+    for j, x := range vec {
+      row[j] = strconv.FormatFloat(x, 'g', -1, 64);
+    }
+    rows[i] = strings.Join(row, ",");
+  }
+  return strings.Join(rows, "\n") + "\n";
+}
+
+func readAll(iterator types.Iterator) [][]float64 {
+  var result [][]float64;
+  for iterator.HasNext() {
+    result = append(result, iterator.Next());
+  }
+  return result;
+}
+
+func TestFileIterators(t *testing.T) {
+  dir, err := ioutil.TempDir("", "rphash");
+  if err != nil {
+    t.Fatal(err);
+  }
+  defer os.RemoveAll(dir);
+  csvContents := "1,2,3\n4,5,6\n7.5,-8,9\n";
+  jsonlContents := "[1,2,3]\n[4,5,6]\n\n[7.5,-8,9]\n";
+
+  csvIterator, err := reader.NewCSVIterator(writeTestFile(t, dir, "data.csv", csvContents, false));
+  if err != nil {
+    t.Fatal(err);
+  }
+  gzipIterator, err := reader.NewCSVIterator(writeTestFile(t, dir, "data.csv.gz", csvContents, true));
+  if err != nil {
+    t.Fatal(err);
+  }
+  jsonlIterator, err := reader.NewJSONLIterator(writeTestFile(t, dir, "data.jsonl", jsonlContents, false));
+  if err != nil {
+    t.Fatal(err);
+  }
+
+  for name, iterator := range map[string]*reader.FileIterator{"csv": csvIterator, "gzip": gzipIterator, "jsonl": jsonlIterator} {
+    var closable types.ClosableIterator = iterator;
+    if result := readAll(closable); !reflect.DeepEqual(result, fileIteratorExpected) {
+      t.Errorf("%v: Expected %v, Actual %v.", name, fileIteratorExpected, result);
+    }
+    closable.Reset();
+    if result := readAll(closable); !reflect.DeepEqual(result, fileIteratorExpected) {
+      t.Errorf("%v: Reset should rewind to the first vector. Expected %v, Actual %v.", name, fileIteratorExpected, result);
+    }
+    if err := closable.Close(); err != nil {
+      t.Errorf("%v: Unexpected error closing: %v", name, err);
+    }
+    if closable.HasNext() {
+      t.Errorf("%v: A closed iterator should have no more vectors.", name);
+    }
+    closable.Reset();
+    if result := readAll(closable); !reflect.DeepEqual(result, fileIteratorExpected) {
+      t.Errorf("%v: Reset after Close should reopen the file. Expected %v, Actual %v.", name, fileIteratorExpected, result);
+    }
+    closable.Close();
+    if iterator.Err() != nil {
+      t.Errorf("%v: Unexpected read error: %v", name, iterator.Err());
+    }
+  }
+}
+
+func TestFileIteratorLongJSONLine(t *testing.T) {
+  dir, err := ioutil.TempDir("", "rphash");
+  if err != nil {
+    t.Fatal(err);
+  }
+  defer os.RemoveAll(dir);
+  // A vector of 10000 values, well past the 64 KiB a bufio.Scanner allows per
+  // line, with no newline after the last line.
+  wide := make([]float64, 10000);
+  cells := make([]string, len(wide));
+  for i := range wide {
+    wide[i] = float64(i) + 0.123456789;
+    cells[i] = strconv.FormatFloat(wide[i], 'f', -1, 64);
+  }
+  line := "[" + strings.Join(cells, ",") + "]";
+  if len(line) <= 64 * 1024 {
+    t.Fatalf("Expected a line longer than 64 KiB, Actual %d bytes.", len(line));
+  }
+  iterator, err := reader.NewJSONLIterator(writeTestFile(t, dir, "wide.jsonl", "[1,2]\n" + line, false));
+  if err != nil {
+    t.Fatal(err);
+  }
+  defer iterator.Close();
+  result := readAll(iterator);
+  if iterator.Err() != nil {
+    t.Fatalf("Unexpected read error: %v", iterator.Err());
+  }
+  if len(result) != 2 || !reflect.DeepEqual(result[1], wide) {
+    t.Errorf("Expected the long line to be read whole as the second vector, Actual %d vectors.", len(result));
+  }
+}
+
+func TestFileIteratorParseError(t *testing.T) {
+  dir, err := ioutil.TempDir("", "rphash");
+  if err != nil {
+    t.Fatal(err);
+  }
+  defer os.RemoveAll(dir);
+  iterator, err := reader.NewCSVIterator(writeTestFile(t, dir, "bad.csv", "1,2\n3,x\n", false));
+  if err != nil {
+    t.Fatal(err);
+  }
+  defer iterator.Close();
+  if result := readAll(iterator); len(result) != 1 {
+    t.Errorf("Reading should stop at the bad row. Expected 1 vector, Actual %v.", result);
+  }
+  if iterator.Err() == nil {
+    t.Error("A non-numeric cell should be reported by Err.");
+  }
+}
+
+func TestSimpleRunFileTwice(t *testing.T) {
+  dir, err := ioutil.TempDir("", "rphash");
+  if err != nil {
+    t.Fatal(err);
+  }
+  defer os.RemoveAll(dir);
+  data := generateBlobs([][]float64{{10, 0, 0, 0}, {0, 10, 0, 0}}, 50, 1, 0);
+  iterator, err := reader.NewCSVIterator(writeTestFile(t, dir, "data.csv", csvRows(data), false));
+  if err != nil {
+    t.Fatal(err);
+  }
+  // The iterator is ours to close, Run leaves it open for the next pass.
+  defer iterator.Close();
+  RPHashObject := reader.NewStreamObject(4, 2);
+  RPHashObject.SetVectorIterator(iterator);
+  RPHashObject.SetRandomSeed(1);
+  RPHashObject.SetDecoderType(defaults.NewDecoder(2, 6, 1));
+  RPHashSimple := simple.NewSimple(RPHashObject);
+  RPHashSimple.SetMinClusterSize(2);
+  if err := RPHashSimple.Run(); err != nil {
+    t.Fatalf("Unexpected error on the first run: %v", err);
+  }
+  first := RPHashSimple.GetCentroids();
+  if len(first) != 2 {
+    t.Fatalf("Expected 2 centroids, Actual %v.", first);
+  }
+
+  RPHashObject.Reset();
+  if err := RPHashSimple.Run(); err != nil {
+    t.Fatalf("Unexpected error on the second run: %v", err);
+  }
+  if second := RPHashSimple.GetCentroids(); !reflect.DeepEqual(first, second) {
+    t.Errorf("A second run over the same file should give the same centroids. Expected %v, Actual %v.", first, second);
+  }
+  if RPHashObject.NumDataPoints() != len(data) {
+    t.Errorf("Expected every row to be read, Actual %d of %d.", RPHashObject.NumDataPoints(), len(data));
+  }
+}
@@ -0,0 +1,86 @@
+package tests;
+
+import (
+  "flag"
+  "io/ioutil"
+  "math"
+  "testing"
+  "github.com/wenkesj/rphash/api"
+  "github.com/wenkesj/rphash/parse"
+  "github.com/wenkesj/rphash/reader"
+  "github.com/wenkesj/rphash/simple"
+);
+
+// Rewrite the golden centroids from the current pipeline with
+// go test ./tests -run TestGolden -update-golden
+var updateGolden = flag.Bool("update-golden", false, "rewrite the golden centroids");
+
+const (
+  goldenInputFileName = "golden_input.json";
+  goldenOutputFileName = "golden_centroids.json";
+  goldenLabel = "points";
+  goldenClusters = 4;
+  goldenSeed = int64(20151015);
+);
+
+// The whole pipeline, parse, project, sketch and cluster, on a fixed data set
+// of four blobs and a fixed seed. This is also the canonical way to cluster a JSON data set.
+func goldenCentroids(t *testing.T) map[string]interface{} {
+  parser := parse.NewParser();
+  contents, err := ioutil.ReadFile(dataPath + goldenInputFileName);
+  if err != nil {
+    t.Fatalf("Cannot read the golden input: %v", err);
+  }
+  data := mustMatrix(t, parser, goldenLabel, mustBytesToJSON(t, parser, contents));
+
+  RPHashObject := reader.NewSimpleArray(data, goldenClusters);
+  RPHashObject.SetRandomSeed(goldenSeed);
+  RPHashSimple := simple.NewSimple(RPHashObject);
+  // One worker sums the clusters in the same order on every machine.
+  RPHashSimple.SetConcurrency(1);
+  if err := RPHashSimple.Run(); err != nil {
+    t.Fatalf("Unexpected error running the pipeline: %v", err);
+  }
+  centroids, err := api.CentroidsToJSON(parser, RPHashSimple.GetCentroids());
+  if err != nil {
+    t.Fatalf("Unexpected error converting the centroids: %v", err);
+  }
+  return centroids;
+};
+
+func TestGolden(t *testing.T) {
+  actual := goldenCentroids(t);
+  parser := parse.NewParser();
+  if *updateGolden {
+    bytes, err := parser.JSONToBytes(actual);
+    if err != nil {
+      t.Fatalf("Cannot encode the golden centroids: %v", err);
+    }
+    if err := ioutil.WriteFile(dataPath + goldenOutputFileName, append(bytes, '\n'), 0644); err != nil {
+      t.Fatalf("Cannot write the golden centroids: %v", err);
+    }
+  }
+  contents, err := ioutil.ReadFile(dataPath + goldenOutputFileName);
+  if err != nil {
+    t.Fatalf("Cannot read the golden centroids: %v", err);
+  }
+  expectedCentroids := mustBytesToJSON(t, parser, contents)[goldenLabel].([]interface{});
+  actualCentroids := actual[goldenLabel].([]interface{});
+  if len(actualCentroids) != len(expectedCentroids) {
+    t.Fatalf("Expected %v centroids, Actual %v.", len(expectedCentroids), len(actualCentroids));
+  }
+  for i := range expectedCentroids {
+    expectedCentroid := expectedCentroids[i].(map[string]interface{});
+    actualCentroid := actualCentroids[i].(map[string]interface{});
+    if len(actualCentroid) != len(expectedCentroid) {
+      t.Errorf("Centroid %v: expected %v fields, Actual %v.", i, len(expectedCentroid), len(actualCentroid));
+    }
+    for field, value := range expectedCentroid {
+      expected, _ := parser.ConvertInterfaceToFloat64(value);
+      got, err := parser.ConvertInterfaceToFloat64(actualCentroid[field]);
+      if err != nil || math.Abs(got - expected) > 1e-9 * math.Max(1, math.Abs(expected)) {
+        t.Errorf("Centroid %v field %v: expected %v, Actual %v.", i, field, expected, actualCentroid[field]);
+      }
+    }
+  }
+};
@@ -1,9 +1,14 @@
 package tests;
 
 import (
+  "bytes"
+  "encoding/gob"
+  "reflect"
   "testing"
+  "time"
   "math/rand"
   "github.com/wenkesj/rphash/itemset"
+  "github.com/wenkesj/rphash/utils"
 );
 
 func TestCountMinSketchCounts(t *testing.T) {
@@ -58,6 +63,25 @@ func TestCountMinSketchGetTop(t *testing.T) {
   }
 };
 
+func TestCountMinSketchGetTopAfterAdds(t *testing.T) {
+  khh := itemset.NewKHHCountMinSketchWithCapacity(3);
+  for i := int64(1); i <= 3; i++ {
+    khh.AddWeighted(i, i);
+  }
+  if top := khh.GetTop(); !reflect.DeepEqual(top, []int64{1, 2, 3}) {
+    t.Fatalf("Expected the top [1 2 3], Actual %v.", top);
+  }
+  // Reading the top must neither drop the heavy hitters nor hide later adds.
+  khh.AddWeighted(1, 10);
+  khh.AddBatch([]int64{4, 4, 4, 4, 4});
+  if top := khh.GetTop(); !reflect.DeepEqual(top, []int64{3, 4, 1}) {
+    t.Errorf("Expected the top [3 4 1] after more adds, Actual %v.", top);
+  }
+  if counts := khh.GetCounts(); !reflect.DeepEqual(counts, []int64{3, 5, 11}) {
+    t.Errorf("Expected the counts [3 5 11] after more adds, Actual %v.", counts);
+  }
+};
+
 func BenchmarkCountMinSketchAdd(b *testing.B) {
   k := 100;
   khh := itemset.NewKHHCountMinSketch(k);
@@ -65,3 +89,392 @@ func BenchmarkCountMinSketchAdd(b *testing.B) {
     khh.Add(rand.Int63());
   }
 };
+
+func TestCountMinSketchComparator(t *testing.T) {
+  // Item i is added 11 - i times, so low items are the most frequent.
+  fill := func(khh *itemset.KHHCountMinSketch) {
+    for i := 1; i <= 10; i++ {
+      for j := 0; j < 11 - i; j++ {
+        khh.Add(int64(i));
+      }
+    }
+  };
+
+  byCount := itemset.NewKHHCountMinSketch(3);
+  fill(byCount);
+  expected := []int64{3, 2, 1};
+  for i, value := range byCount.GetTop() {
+    if value != expected[i] {
+      t.Errorf("By default the most frequent items should be kept in ascending count. Expected %v, Actual %v.", expected, byCount.GetTop());
+      break;
+    }
+  }
+
+  byItem := itemset.NewKHHCountMinSketch(3);
+  byItem.SetComparator(func(item1, count1, item2, count2 int64) int {
+    return int(item1 - item2);
+  });
+  fill(byItem);
+  expected = []int64{8, 9, 10};
+  for i, value := range byItem.GetTop() {
+    if value != expected[i] {
+      t.Errorf("A comparator on the item should keep the largest items. Expected %v, Actual %v.", expected, byItem.GetTop());
+      break;
+    }
+  }
+};
+
+func TestCountMinSketchAddWeighted(t *testing.T) {
+  repeated := itemset.NewKHHCountMinSketch(10);
+  weighted := itemset.NewKHHCountMinSketch(10);
+  for i := int64(1); i <= 20; i++ {
+    for j := int64(0); j < i; j++ {
+      repeated.Add(i);
+    }
+    weighted.AddWeighted(i, i);
+  }
+  weighted.AddWeighted(99, 0);
+  repeatedTop, weightedTop := repeated.GetTop(), weighted.GetTop();
+  if len(repeatedTop) != len(weightedTop) {
+    t.Fatalf("Weighted adds kept %v items, repeated adds kept %v.", weightedTop, repeatedTop);
+  }
+  for i := range repeatedTop {
+    if repeatedTop[i] != weightedTop[i] || repeated.GetCounts()[i] != weighted.GetCounts()[i] {
+      t.Errorf("Adding an item with weight w should match adding it w times. Expected %v %v, Actual %v %v.",
+        repeatedTop, repeated.GetCounts(), weightedTop, weighted.GetCounts());
+      break;
+    }
+  }
+};
+
+func TestCentroidWeightedUpdate(t *testing.T) {
+  centroid := itemset.NewCentroidSimple(1, 0);
+  centroid.UpdateWeightedVector([]float64{0}, 1);
+  centroid.UpdateWeightedVector([]float64{4}, 3);
+  if centroid.Centroid()[0] != 3 {
+    t.Errorf("The weighted mean of 0 (weight 1) and 4 (weight 3) is 3, Actual %v.", centroid.Centroid()[0]);
+  }
+  if centroid.GetCount() != 2 || centroid.GetWeight() != 4 {
+    t.Errorf("Expected 2 vectors of total weight 4, Actual %v of %v.", centroid.GetCount(), centroid.GetWeight());
+  }
+};
+
+func TestCountMinSketchAddBatch(t *testing.T) {
+  random := rand.New(rand.NewSource(0));
+  items := make([]int64, 5000);
+  for i := range items {
+    // Skewed so some items dominate and others churn through the top-k.
+    items[i] = int64(random.ExpFloat64() * 10) + 1;
+  }
+  perItem := itemset.NewKHHCountMinSketch(5);
+  for _, item := range items {
+    perItem.Add(item);
+  }
+  batch := itemset.NewKHHCountMinSketch(5);
+  batch.AddBatch(items[:2000]);
+  batch.AddBatch(items[2000:]);
+
+  perItemTop, batchTop := perItem.GetTop(), batch.GetTop();
+  if len(perItemTop) != len(batchTop) {
+    t.Fatalf("Batches kept %v, per item adds kept %v.", batchTop, perItemTop);
+  }
+  for i := range perItemTop {
+    if perItemTop[i] != batchTop[i] || perItem.GetCounts()[i] != batch.GetCounts()[i] {
+      t.Errorf("AddBatch should match adding each item. Expected %v %v, Actual %v %v.",
+        perItemTop, perItem.GetCounts(), batchTop, batch.GetCounts());
+      break;
+    }
+  }
+};
+
+func TestCountMinSketchBounded(t *testing.T) {
+  var spill bytes.Buffer;
+  khh := itemset.NewKHHCountMinSketch(10);
+  khh.SetBounded(3, &spill);
+  estimate := khh.MemoryEstimate();
+
+  // Three heavy items, then a long tail of distinct items seen once each.
+  for i := int64(1); i <= 3; i++ {
+    khh.AddWeighted(i, 100 * i);
+  }
+  for i := int64(1000); i < 6000; i++ {
+    khh.Add(i);
+  }
+  if khh.MemoryEstimate() != estimate {
+    t.Errorf("The memory estimate should not grow with distinct items, %v became %v.", estimate, khh.MemoryEstimate());
+  }
+  if khh.SpillErr() != nil {
+    t.Fatalf("Unexpected spill error: %v", khh.SpillErr());
+  }
+  items, estimates, err := itemset.ReadSpill(&spill);
+  if err != nil {
+    t.Fatalf("Unexpected error reading the spill: %v", err);
+  }
+  if len(items) != 5000 || len(estimates) != 5000 {
+    t.Errorf("Every tail item should be spilled, Actual %v.", len(items));
+  }
+
+  top := khh.GetTop();
+  if !reflect.DeepEqual(top, []int64{1, 2, 3}) {
+    t.Errorf("Expected the heavy items [1 2 3], Actual %v.", top);
+  }
+
+  // Shrinking a full sketch spills the lowest ranked items.
+  spill.Reset();
+  khh = itemset.NewKHHCountMinSketch(10);
+  for i := int64(1); i <= 5; i++ {
+    khh.AddWeighted(i, 10 * i);
+  }
+  khh.SetBounded(2, &spill);
+  items, _, _ = itemset.ReadSpill(&spill);
+  if !reflect.DeepEqual(items, []int64{1, 2, 3}) {
+    t.Errorf("Expected [1 2 3] to be spilled, Actual %v.", items);
+  }
+  if top := khh.GetTop(); !reflect.DeepEqual(top, []int64{4, 5}) {
+    t.Errorf("Expected [4 5] to remain, Actual %v.", top);
+  }
+};
+
+func TestCountMinSketchHashCodeCollisions(t *testing.T) {
+  // Both fold to the same 32 bit hash code.
+  var first, second int64 = 0, 1 << 32 | 1;
+  if utils.HashCode(first) != utils.HashCode(second) {
+    t.Fatalf("Expected %v and %v to share a hash code.", first, second);
+  }
+  khh := itemset.NewKHHCountMinSketch(4);
+  khh.AddWeighted(first, 5);
+  khh.AddWeighted(second, 3);
+  top, counts := khh.GetTop(), khh.GetCounts();
+  if !reflect.DeepEqual(top, []int64{second, first}) {
+    t.Fatalf("Expected both items to be tracked, Actual %v.", top);
+  }
+  if !reflect.DeepEqual(counts, []int64{3, 5}) {
+    t.Errorf("Expected the counts to stay separate as [3 5], Actual %v.", counts);
+  }
+};
+
+func TestCountMinSketchWithParams(t *testing.T) {
+  epsilon, delta := 0.01, 0.000001;
+  khh := itemset.NewKHHCountMinSketchWithParams(epsilon, delta, 200);
+  if khh.GetWidth() != 272 || khh.GetDepth() != 14 {
+    t.Fatalf("Expected a table 272 wide and 14 deep, Actual %d wide and %d deep.", khh.GetWidth(), khh.GetDepth());
+  }
+  defaults := itemset.NewKHHCountMinSketch(200);
+  if defaults.GetWidth() != 200000 || defaults.GetDepth() != 7 {
+    t.Errorf("Expected the default table 200000 wide and 7 deep, Actual %d wide and %d deep.", defaults.GetWidth(), defaults.GetDepth());
+  }
+
+  // A skewed stream over fewer distinct items than are tracked, so every
+  // item's estimate is reported at its last occurrence.
+  numToAdd := 20000;
+  zipf := rand.NewZipf(rand.New(rand.NewSource(7)), 1.2, 1, 999);
+  actual := make(map[int64]int64);
+  for i := 0; i < numToAdd; i++ {
+    item := int64(zipf.Uint64());
+    actual[item]++;
+    khh.Add(item);
+  }
+  bound := int64(epsilon * float64(numToAdd));
+  top, counts := khh.GetTop(), khh.GetCounts();
+  if len(top) != len(actual) {
+    t.Fatalf("Expected all %d distinct items to be tracked, Actual %d.", len(actual), len(top));
+  }
+  for i, item := range top {
+    if counts[i] < actual[item] || counts[i] > actual[item] + bound {
+      t.Errorf("Item %d was seen %d times but estimated %d, outside the bound of %d.", item, actual[item], counts[i], bound);
+    }
+  }
+};
+
+func TestCountMinSketchMerge(t *testing.T) {
+  // Item i appears i times so every count is distinct.
+  var items []int64;
+  for i := 1; i <= 40; i++ {
+    for j := 0; j < i; j++ {
+      items = append(items, int64(i));
+    }
+  }
+  shuffled := make([]int64, len(items));
+  for i, j := range rand.New(rand.NewSource(3)).Perm(len(items)) {
+    shuffled[i] = items[j];
+  }
+
+  full := itemset.NewKHHCountMinSketchSeeded(10, 5);
+  full.AddBatch(shuffled);
+  first := itemset.NewKHHCountMinSketchSeeded(10, 5);
+  first.AddBatch(shuffled[:len(shuffled) / 2]);
+  second := itemset.NewKHHCountMinSketchSeeded(10, 5);
+  second.AddBatch(shuffled[len(shuffled) / 2:]);
+  if err := first.Merge(second); err != nil {
+    t.Fatalf("Unexpected error merging sketches: %v", err);
+  }
+
+  if !reflect.DeepEqual(first.GetTop(), full.GetTop()) || !reflect.DeepEqual(first.GetCounts(), full.GetCounts()) {
+    t.Errorf("Merged halves should match the full sketch. Expected %v %v, Actual %v %v.",
+      full.GetTop(), full.GetCounts(), first.GetTop(), first.GetCounts());
+  }
+
+  if err := first.Merge(itemset.NewKHHCountMinSketchSeeded(10, 6)); err == nil {
+    t.Error("Merging sketches with different hash seeds should return an error.");
+  }
+  if err := first.Merge(itemset.NewKHHCountMinSketchWithParams(0.01, 0.01, 10)); err == nil {
+    t.Error("Merging sketches with different table sizes should return an error.");
+  }
+};
+
+func TestCountMinSketchMarshalBinary(t *testing.T) {
+  random := rand.New(rand.NewSource(11));
+  items := make([]int64, 4000);
+  for i := range items {
+    items[i] = int64(random.ExpFloat64() * 10) + 1;
+  }
+
+  uninterrupted := itemset.NewKHHCountMinSketchSeeded(8, 9);
+  uninterrupted.AddBatch(items);
+
+  checkpointed := itemset.NewKHHCountMinSketchSeeded(8, 9);
+  checkpointed.AddBatch(items[:1500]);
+  data, err := checkpointed.MarshalBinary();
+  if err != nil {
+    t.Fatalf("Unexpected error serializing the sketch: %v", err);
+  }
+  restored := new(itemset.KHHCountMinSketch);
+  if err := restored.UnmarshalBinary(data); err != nil {
+    t.Fatalf("Unexpected error restoring the sketch: %v", err);
+  }
+  for _, item := range items[1500:] {
+    restored.Add(item);
+  }
+
+  if !reflect.DeepEqual(restored.GetTop(), uninterrupted.GetTop()) || !reflect.DeepEqual(restored.GetCounts(), uninterrupted.GetCounts()) {
+    t.Errorf("A restored sketch should carry on as if never saved. Expected %v %v, Actual %v %v.",
+      uninterrupted.GetTop(), uninterrupted.GetCounts(), restored.GetTop(), restored.GetCounts());
+  }
+
+  if err := new(itemset.KHHCountMinSketch).UnmarshalBinary(data[:len(data) / 2]); err == nil {
+    t.Error("Restoring a truncated sketch should return an error.");
+  }
+
+  // A table without counters, which every add would divide by.
+  var empty bytes.Buffer;
+  state := struct{ Depth, Width int; HashVector []int64; Table [][]int64 }{1, 0, []int64{1}, [][]int64{{}}};
+  if err := gob.NewEncoder(&empty).Encode(state); err != nil {
+    t.Fatal(err);
+  }
+  if err := new(itemset.KHHCountMinSketch).UnmarshalBinary(empty.Bytes()); err == nil {
+    t.Error("Restoring a sketch of width 0 should return an error.");
+  }
+};
+
+func TestCountMinSketchEstimateCount(t *testing.T) {
+  epsilon := 0.01;
+  khh := itemset.NewKHHCountMinSketchWithParams(epsilon, 0.000001, 3);
+  random := rand.New(rand.NewSource(13));
+  actual := make(map[int64]int64);
+  numToAdd := 10000;
+  for i := 0; i < numToAdd; i++ {
+    // Items 1 to 3 are heavy, the other 500 are light.
+    item := int64(random.Intn(3) + 1);
+    if random.Intn(2) == 0 {
+      item = int64(random.Intn(500) + 100);
+    }
+    actual[item]++;
+    khh.Add(item);
+  }
+  bound := int64(epsilon * float64(numToAdd));
+  for item, count := range actual {
+    estimate := khh.EstimateCount(item);
+    if estimate < count || estimate > count + bound {
+      t.Errorf("Item %d was seen %d times but estimated %d, outside the bound of %d.", item, count, estimate, bound);
+    }
+  }
+  if estimate := khh.EstimateCount(99999); estimate > bound {
+    t.Errorf("An item never added should estimate within %d, Actual %d.", bound, estimate);
+  }
+};
+
+func TestDecayingCountMinSketch(t *testing.T) {
+  now := time.Unix(1000, 0);
+  clock := func() time.Time { return now; };
+  decaying := itemset.NewDecayingKHHCountMinSketch(2, time.Minute);
+  decaying.SetClock(clock);
+  allTime := itemset.NewKHHCountMinSketch(2);
+
+  // Item 1 is hammered early, then goes quiet while 2 and 3 trend.
+  for i := 0; i < 1000; i++ {
+    decaying.Add(1);
+    allTime.Add(1);
+  }
+  if estimate := decaying.EstimateCount(1); estimate != 1000 {
+    t.Errorf("Expected no decay before the clock moves, Actual %d.", estimate);
+  }
+  now = now.Add(time.Minute);
+  if estimate := decaying.EstimateCount(1); estimate != 500 {
+    t.Errorf("Expected the count to halve after a half life, Actual %d.", estimate);
+  }
+  now = now.Add(9 * time.Minute);
+  for i := 0; i < 10; i++ {
+    for _, item := range []int64{2, 3} {
+      decaying.Add(item);
+      allTime.Add(item);
+    }
+  }
+
+  if top := allTime.GetTop(); top[len(top) - 1] != 1 {
+    t.Errorf("Without decay the early item should lead, Actual %v.", top);
+  }
+  top := decaying.GetTop();
+  if !reflect.DeepEqual(top, []int64{2, 3}) && !reflect.DeepEqual(top, []int64{3, 2}) {
+    t.Errorf("Expected the trending items 2 and 3 to displace 1, Actual %v with counts %v.", top, decaying.GetCounts());
+  }
+  if counts := decaying.GetCounts(); counts[0] != 10 || counts[1] != 10 {
+    t.Errorf("Expected the trending items to count 10 each, Actual %v.", counts);
+  }
+  if err := decaying.Merge(itemset.NewDecayingKHHCountMinSketch(2, time.Minute)); err == nil {
+    t.Error("Merging decaying sketches should return an error.");
+  }
+};
+
+func TestCountMinSketchKeepsDominantItems(t *testing.T) {
+  dominant := []int64{7, 1001, 42, 99999, 3};
+  var heavy, rare []int64;
+  for _, e := range dominant {
+    for i := 0; i < 500; i++ {
+      heavy = append(heavy, e);
+    }
+  }
+  for e := int64(10000); e < 12000; e++ {
+    for i := int64(0); i < e % 3 + 1; i++ {
+      rare = append(rare, e);
+    }
+  }
+  shuffled := append(append([]int64{}, heavy...), rare...);
+  rand.New(rand.NewSource(0)).Shuffle(len(shuffled), func(i, j int) {
+    shuffled[i], shuffled[j] = shuffled[j], shuffled[i];
+  });
+  orders := map[string][]int64{
+    "dominant first": append(append([]int64{}, heavy...), rare...),
+    "dominant last": append(append([]int64{}, rare...), heavy...),
+    "shuffled": shuffled,
+  };
+
+  for name, stream := range orders {
+    khh := itemset.NewKHHCountMinSketchSeeded(len(dominant), 1);
+    for _, e := range stream {
+      khh.Add(e);
+    }
+    top := khh.GetTop();
+    if len(top) != len(dominant) {
+      t.Fatalf("%s: expected %d heavy hitters, Actual %v.", name, len(dominant), top);
+    }
+    kept := make(map[int64]bool);
+    for _, e := range top {
+      kept[e] = true;
+    }
+    for _, e := range dominant {
+      if !kept[e] {
+        t.Errorf("%s: the dominant item %d was evicted, the heavy hitters are %v.", name, e, top);
+      }
+    }
+  }
+};
@@ -2,6 +2,7 @@ package tests;
 
 import (
     "testing"
+    "github.com/wenkesj/rphash/defaults"
     "github.com/wenkesj/rphash/hash"
     "github.com/wenkesj/rphash/decoder"
     "github.com/wenkesj/rphash/projector"
@@ -62,7 +63,27 @@ func TestLSHStream(t *testing.T) {
   t.Log("√ LSH Stream test complete");
 };
 
-func BenchmarkSimple(b *testing.B) {
+func TestHashVector(t *testing.T) {
+  var seed int64 = 0;
+  data := []float64{1.0,0.0,2.0,7.0,4.0,0.0,8.0,3.0,2.0,1.0};
+  hash := hash.NewMurmur(1 << 63 - 1);
+  decoder := decoder.NewSpherical(5, 3, 1);
+  projector := projector.NewDBFriendly(10, 5, seed);
+  lsh := lsh.NewLSH(hash, decoder, projector);
+  projected := projector.Project(data);
+  if defaults.HashVector(projected, decoder, hash) != lsh.LSHHashSimple(data) {
+    t.Error("Hashing a projected vector should match hashing through the full LSH.");
+  }
+  nearby := make([]float64, len(projected));
+  for i := range projected {
+    nearby[i] = projected[i] * 1.001;
+  }
+  if defaults.HashVector(projected, decoder, hash) != defaults.HashVector(nearby, decoder, hash) {
+    t.Error("A scaled copy of a vector decodes to the same point on the sphere and should hash the same.");
+  }
+};
+
+func BenchmarkLSHSimple(b *testing.B) {
   var seed int64 = 0;
   var d, k, l int = 64, 6, 4;
   data := []float64{1.0,0.0,2.0,7.0,4.0,0.0,8.0,3.0,2.0,1.0};
@@ -0,0 +1,183 @@
+package tests;
+
+import (
+  "math"
+  "math/rand"
+  "reflect"
+  "testing"
+  "github.com/wenkesj/rphash/api"
+  "github.com/wenkesj/rphash/clusterer"
+  "github.com/wenkesj/rphash/metrics"
+  "github.com/wenkesj/rphash/types"
+  "github.com/wenkesj/rphash/utils"
+);
+
+// Generate numPerBlob points around each center with the given spread.
+func generateBlobs(centers [][]float64, numPerBlob int, spread float64, seed int64) [][]float64 {
+  random := rand.New(rand.NewSource(seed));
+  var data [][]float64;
+  for i := 0; i < numPerBlob; i++ {
+    for _, center := range centers {
+      vec := make([]float64, len(center));
+      for j := range center {
+        vec[j] = center[j] + random.NormFloat64() * spread;
+      }
+      data = append(data, vec);
+    }
+  }
+  return data;
+}
+
+func TestWCSS(t *testing.T) {
+  data := [][]float64{{0, 0}, {2, 0}, {10, 0}, {10, 4}};
+  centroids := [][]float64{{1, 0}, {10, 2}};
+  // 1 + 1 + 4 + 4
+  if wcss := metrics.WCSS(data, centroids); wcss != 10 {
+    t.Errorf("Expected a WCSS of 10, Actual %v.", wcss);
+  }
+}
+
+func TestSilhouette(t *testing.T) {
+  data := [][]float64{{0}, {2}, {10}, {12}};
+  centroids := [][]float64{{1}, {11}};
+  // Every point is 2 from its neighbour. The outer points are on average 11
+  // from the other pair and the inner points 9, scoring 9/11 and 7/9.
+  expected := (9.0 / 11.0 + 7.0 / 9.0) / 2;
+  if s := metrics.Silhouette(data, centroids); math.Abs(s - expected) > 1e-9 {
+    t.Errorf("Expected a silhouette of %v, Actual %v.", expected, s);
+  }
+  if s := metrics.Silhouette(data, [][]float64{{1}, {100}}); s != 0 {
+    t.Errorf("A single non-empty cluster should score 0, Actual %v.", s);
+  }
+}
+
+func TestMetricsSeparation(t *testing.T) {
+  centers := [][]float64{{0, 0}, {4, 4}, {8, 0}};
+  var previousWCSS, previousSilhouette float64;
+  // Tighten the same blobs from overlapping to well separated.
+  for step, spread := range []float64{3, 1, 0.25} {
+    data := generateBlobs(centers, 100, spread, 7);
+    wcss, silhouette := metrics.WCSS(data, centers), metrics.Silhouette(data, centers);
+    if step > 0 && (wcss >= previousWCSS || silhouette <= previousSilhouette) {
+      t.Errorf("Tightening the blobs to a spread of %v should lower the WCSS and raise the silhouette, " +
+        "Actual %v and %v after %v and %v.", spread, wcss, silhouette, previousWCSS, previousSilhouette);
+    }
+    previousWCSS, previousSilhouette = wcss, silhouette;
+
+    sampled := metrics.SilhouetteSample(data, centers, 100, 1);
+    if math.Abs(sampled - silhouette) > 0.1 {
+      t.Errorf("A sample of 100 should estimate the silhouette %v closely, Actual %v.", silhouette, sampled);
+    }
+  }
+  if sampled := metrics.SilhouetteSample(centers, centers, 10, 1); sampled != metrics.Silhouette(centers, centers) {
+    t.Errorf("A sample larger than the data should score all of it, Actual %v.", sampled);
+  }
+}
+
+// The full silhouette compares all 3000^2 pairs, the sample only 300^2.
+func BenchmarkSilhouette(b *testing.B) {
+  centers := [][]float64{{0, 0}, {4, 4}, {8, 0}};
+  data := generateBlobs(centers, 1000, 1, 7);
+  b.ResetTimer();
+  for i := 0; i < b.N; i++ {
+    metrics.Silhouette(data, centers);
+  }
+}
+
+func BenchmarkSilhouetteSample(b *testing.B) {
+  centers := [][]float64{{0, 0}, {4, 4}, {8, 0}};
+  data := generateBlobs(centers, 1000, 1, 7);
+  b.ResetTimer();
+  for i := 0; i < b.N; i++ {
+    metrics.SilhouetteSample(data, centers, 300, int64(i));
+  }
+}
+
+func TestSelectK(t *testing.T) {
+  centers := [][]float64{
+    {10, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+    {0, 10, 0, 0, 0, 0, 0, 0, 0, 0},
+    {0, 0, 10, 0, 0, 0, 0, 0, 0, 0},
+  };
+  data := generateBlobs(centers, 100, 0.5, 0);
+  bestK, scores, err := api.SelectK(data, []int{1, 2, 3, 4, len(data)});
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err);
+  }
+  if len(scores) != 3 {
+    t.Fatalf("Only the candidates 2, 3 and 4 can be scored, got scores %v.", scores);
+  }
+  for k, score := range scores {
+    if score > scores[bestK] {
+      t.Errorf("K %v scored %v, better than the chosen K %v at %v.", k, score, bestK, scores[bestK]);
+    }
+  }
+  if _, _, err := api.SelectK(data, []int{1, len(data)}); err == nil {
+    t.Error("Expected an error when no candidate can be scored.");
+  }
+}
+
+func TestSelectKWith(t *testing.T) {
+  // KMeans starts from contiguous blocks, so keep each blob together.
+  var data [][]float64;
+  for _, center := range [][]float64{{10, 0}, {0, 10}, {-10, 0}} {
+    data = append(data, generateBlobs([][]float64{center}, 50, 0.5, 0)...);
+  }
+  bestK, scores, err := api.SelectKWith(data, []int{2, 3, 4}, func(k int) types.Clusterer {
+    return clusterer.NewKMeansSimple(k, data);
+  });
+  if err != nil || bestK != 3 {
+    t.Errorf("Expected K 3 to score best, Actual %v with scores %v and error %v.", bestK, scores, err);
+  }
+
+  bestK, scores, err = api.SelectKWith(data, []int{2, 3}, func(k int) types.Clusterer {
+    return clusterer.NewKMeansSimple(k, data[:1]);
+  });
+  if len(scores) != 0 {
+    t.Errorf("Candidates whose run fails should not be scored, got %v.", scores);
+  }
+  if err == nil || bestK != 0 {
+    t.Errorf("Expected the error of the failed runs when none is scored, Actual K %v and %v.", bestK, err);
+  }
+};
+
+func TestDaviesBouldin(t *testing.T) {
+  data := [][]float64{{0}, {2}, {10}, {12}};
+  labels := []int{0, 0, 1, 1};
+  centroids := [][]float64{{1}, {11}};
+  // Both spreads are 1 and the centroids are 10 apart.
+  if score := metrics.DaviesBouldin(data, labels, centroids); math.Abs(score - 0.2) > 1e-12 {
+    t.Errorf("Expected 0.2, Actual %v.", score);
+  }
+  if score := metrics.DaviesBouldin(data, labels, append(centroids, []float64{100})); math.Abs(score - 0.2) > 1e-12 {
+    t.Errorf("An empty cluster should be ignored, Actual %v.", score);
+  }
+  if score := metrics.DaviesBouldin(data, []int{0, 0, 0, 0}, centroids); score != 0 {
+    t.Errorf("A single cluster should score 0, Actual %v.", score);
+  }
+  if score := metrics.DaviesBouldin(data, []int{0, 0, 1, 1}, [][]float64{{6}, {6}}); !math.IsInf(score, 1) {
+    t.Errorf("Clusters sharing a centroid should score +Inf, Actual %v.", score);
+  }
+  outlier := [][]float64{{0}, {2}, {11}, {50}};
+  if score := metrics.DaviesBouldin(outlier, []int{0, 0, 1, 7}, centroids); math.Abs(score - 0.1) > 1e-12 {
+    t.Errorf("Labels outside the centroids should be ignored, Actual %v.", score);
+  }
+};
+
+func TestSilhouetteWithDistance(t *testing.T) {
+  // Two rays, apart by angle but spread along their length.
+  var data [][]float64;
+  for length := 1.0; length <= 10; length++ {
+    data = append(data, []float64{length, 0}, []float64{0, length});
+  }
+  centroids := [][]float64{{5.5, 0}, {0, 5.5}};
+  if s := metrics.SilhouetteWithDistance(data, centroids, utils.CosineDistance); math.Abs(s - 1) > 1e-12 {
+    t.Errorf("By cosine distance the rays are perfectly separated, Expected 1, Actual %v.", s);
+  }
+  if s := metrics.Silhouette(data, centroids); s >= 0.75 {
+    t.Errorf("By Euclidean distance the rays overlap near the origin, Expected below 0.75, Actual %v.", s);
+  }
+  if !reflect.DeepEqual(metrics.AssignWithDistance(data, centroids, nil), metrics.Assign(data, centroids)) {
+    t.Error("A nil distance should assign by Euclidean distance.");
+  }
+};
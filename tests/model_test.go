@@ -0,0 +1,66 @@
+package tests;
+
+import (
+  "bytes"
+  "encoding/json"
+  "io/ioutil"
+  "reflect"
+  "testing"
+  "github.com/wenkesj/rphash/api"
+  "github.com/wenkesj/rphash/classifier"
+  "github.com/wenkesj/rphash/parse"
+  "github.com/wenkesj/rphash/projector"
+);
+
+func TestSaveLoadModel(t *testing.T) {
+  parser := parse.NewParser();
+  contents, _ := ioutil.ReadFile(dataPath + goldenInputFileName);
+  jsonData := mustBytesToJSON(t, parser, contents);
+  data := mustMatrix(t, parser, goldenLabel, jsonData);
+  centroids := api.NewRPHash(data, goldenClusters).GetCentroids();
+  projection := projector.NewDBFriendly(len(data[0]), 5, goldenSeed);
+  decoder := api.DecoderConfig{Dimension: 24, Rotations: 6, Searches: 1};
+
+  var buffer bytes.Buffer;
+  if err := api.SaveModel(&buffer, parser, projection, decoder, centroids); err != nil {
+    t.Fatalf("Unexpected error saving the model: %v", err);
+  }
+  saved := buffer.Bytes();
+  model, err := api.LoadModel(bytes.NewReader(saved));
+  if err != nil {
+    t.Fatalf("Unexpected error loading the model: %v", err);
+  }
+  if model.Label != goldenLabel || model.Decoder != decoder || !reflect.DeepEqual(model.Centroids, centroids) {
+    t.Errorf("The model should keep the label, decoder and centroids, Actual %v, %+v, %v.", model.Label, model.Decoder, model.Centroids);
+  }
+  if !reflect.DeepEqual(model.GetParser().GetSchemaKeys(), parser.GetSchemaKeys()) {
+    t.Errorf("Expected the schema keys %v, Actual %v.", parser.GetSchemaKeys(), model.GetParser().GetSchemaKeys());
+  }
+  if !reflect.DeepEqual(model.Projection.Project(data[0]), projection.Project(data[0])) {
+    t.Error("The loaded projection should project like the saved one.");
+  }
+
+  expected := classifier.NewClassifier(centroids);
+  for i, object := range jsonData[goldenLabel].([]interface{})[:20] {
+    raw, _ := json.Marshal(object);
+    label, err := model.Predict(raw);
+    if err != nil {
+      t.Fatalf("Row %v: unexpected error predicting: %v", i, err);
+    }
+    if label != expected.Predict(data[i]) {
+      t.Errorf("Row %v: expected cluster %v, Actual %v.", i, expected.Predict(data[i]), label);
+    }
+  }
+  if _, err := model.Predict([]byte(`{"f0": 1}`)); err == nil {
+    t.Error("Predicting an object missing fields should return an error.");
+  }
+  if _, err := model.Predict([]byte(`{"f0":`)); err == nil {
+    t.Error("Predicting malformed JSON should return an error.");
+  }
+  if _, err := api.LoadModel(bytes.NewReader(saved[:len(saved) / 2])); err == nil {
+    t.Error("Loading a truncated model should return an error.");
+  }
+  if err := api.SaveModel(&buffer, parse.NewParser(), nil, decoder, centroids); err == nil {
+    t.Error("Saving without a schema should return an error.");
+  }
+};
@@ -1,8 +1,16 @@
 package tests;
 
 import (
+  "bytes"
+  "encoding/csv"
+  "encoding/json"
+  "math"
+  "reflect"
+  "strconv"
+  "strings"
   "testing"
   "io/ioutil"
+  "github.com/wenkesj/rphash/api"
   "github.com/wenkesj/rphash/parse"
 );
 
@@ -16,8 +24,8 @@ const (
 func TestParser(t *testing.T) {
   parser := parse.NewParser();
   oldBytes, _ := ioutil.ReadFile(dataPath + dataFileName);
-  oldJSON := parser.BytesToJSON(oldBytes);
-  jsonFloats := parser.JSONToFloat64Matrix(dataLabel, oldJSON);
+  oldJSON := mustBytesToJSON(t, parser, oldBytes);
+  jsonFloats := mustMatrix(t, parser, dataLabel, oldJSON);
   newJSON := parser.Float64MatrixToJSON(dataLabel, jsonFloats);
   oldJSONData := oldJSON[dataLabel].([]interface{});
   newJSONData := newJSON[dataLabel].([]interface{});
@@ -37,3 +45,633 @@ func TestParser(t *testing.T) {
     }
   }
 };
+
+func TestCentroidsToJSON(t *testing.T) {
+  parser := parse.NewParser();
+  bytes, _ := ioutil.ReadFile(dataPath + dataFileName);
+  data := mustMatrix(t, parser, dataLabel, mustBytesToJSON(t, parser, bytes));
+  centroids := [][]float64{data[0], data[1]};
+
+  result, err := api.CentroidsToJSON(parser, centroids);
+  if err != nil {
+    t.Fatalf("Unexpected error converting centroids: %v", err);
+  }
+  objects, ok := result[dataLabel].([]interface{});
+  if !ok || len(objects) != len(centroids) {
+    t.Fatalf("Expected %v centroids under the label %v, got %v.", len(centroids), dataLabel, result);
+  }
+  for _, object := range objects {
+    fields := object.(map[string]interface{});
+    for _, key := range parser.GetSchemaKeys() {
+      if _, ok := fields[key]; !ok {
+        t.Errorf("Centroid is missing schema field %v.", key);
+      }
+    }
+  }
+
+  projected := [][]float64{make([]float64, len(parser.GetSchemaKeys()) / 2)};
+  if _, err := api.CentroidsToJSON(parser, projected); err == nil {
+    t.Error("Centroids that do not match the schema width should be rejected.");
+  }
+  if _, err := api.CentroidsToJSON(parse.NewParser(), centroids); err == nil {
+    t.Error("A parser without a schema should be rejected.");
+  }
+};
+
+func TestEncodeMatrix(t *testing.T) {
+  parser := parse.NewParser();
+  contents, _ := ioutil.ReadFile(dataPath + dataFileName);
+  data := mustMatrix(t, parser, dataLabel, mustBytesToJSON(t, parser, contents));
+
+  var buffer bytes.Buffer;
+  if err := parser.EncodeMatrix(&buffer, dataLabel, data); err != nil {
+    t.Fatalf("Unexpected error encoding the matrix: %v", err);
+  }
+  streamed := mustBytesToJSON(t, parser, buffer.Bytes());
+  objects, ok := streamed[dataLabel].([]interface{});
+  if !ok || len(objects) != len(data) {
+    t.Fatalf("Expected %v objects under the label %v, got %v.", len(data), dataLabel, len(objects));
+  }
+  expected := parser.Float64MatrixToJSON(dataLabel, data)[dataLabel].([]interface{});
+  for i, object := range objects {
+    for key, value := range expected[i].(map[string]interface{}) {
+      actual, _ := parser.ConvertInterfaceToFloat64(object.(map[string]interface{})[key]);
+      if math.Abs(actual - value.(float64)) > 1e-9 * math.Abs(value.(float64)) {
+        t.Errorf("Object %v field %v: expected %v, actual %v.", i, key, value, actual);
+      }
+    }
+  }
+
+  buffer.Reset();
+  if err := parser.EncodeMatrix(&buffer, dataLabel, nil); err != nil {
+    t.Fatalf("Unexpected error encoding an empty matrix: %v", err);
+  }
+  if empty := mustBytesToJSON(t, parser, buffer.Bytes()); len(empty[dataLabel].([]interface{})) != 0 {
+    t.Errorf("An empty matrix should encode an empty array, got %v.", empty);
+  }
+};
+
+func TestJSONToBytes(t *testing.T) {
+  parser := parse.NewParser();
+  contents, err := parser.JSONToBytes(map[string]interface{}{"value": 1.5});
+  if err != nil {
+    t.Fatalf("Unexpected error marshaling a finite value: %v", err);
+  }
+  if decoded := mustBytesToJSON(t, parser, contents); decoded["value"] != 1.5 {
+    t.Errorf("Expected the value to round trip, got %v.", decoded);
+  }
+
+  contents, err = parser.JSONToBytes(map[string]interface{}{"value": math.NaN()});
+  if err == nil {
+    t.Error("Marshaling NaN should return an error.");
+  }
+  if contents != nil {
+    t.Errorf("No bytes should be returned on error, got %s.", contents);
+  }
+};
+
+func TestNonFiniteSanitization(t *testing.T) {
+  parser := parse.NewParser();
+  contents, _ := ioutil.ReadFile(dataPath + dataFileName);
+  data := mustMatrix(t, parser, dataLabel, mustBytesToJSON(t, parser, contents));
+  keys := parser.GetSchemaKeys();
+
+  row := append([]float64{}, data[0]...);
+  row[0] = math.NaN();
+  row[1] = math.Inf(1);
+  result := parser.Float64MatrixToJSON(dataLabel, [][]float64{row, data[1]});
+  if parser.GetNonFiniteCount() != 2 {
+    t.Errorf("Expected 2 replaced values, Actual %v.", parser.GetNonFiniteCount());
+  }
+  object := result[dataLabel].([]interface{})[0].(map[string]interface{});
+  if object[keys[0]] != nil || object[keys[1]] != nil {
+    t.Errorf("Non-finite values should be written as null by default, got %v and %v.", object[keys[0]], object[keys[1]]);
+  }
+  if _, err := parser.JSONToBytes(result); err != nil {
+    t.Errorf("Sanitized output should marshal, got %v.", err);
+  }
+
+  parser.ResetNonFiniteCount();
+  parser.SetNonFiniteSentinel(-1);
+  var buffer bytes.Buffer;
+  if err := parser.EncodeMatrix(&buffer, dataLabel, [][]float64{row}); err != nil {
+    t.Fatalf("Unexpected error encoding sanitized values: %v", err);
+  }
+  object = mustBytesToJSON(t, parser, buffer.Bytes())[dataLabel].([]interface{})[0].(map[string]interface{});
+  if object[keys[0]] != -1.0 || object[keys[1]] != -1.0 {
+    t.Errorf("Non-finite values should be written as the sentinel, got %v and %v.", object[keys[0]], object[keys[1]]);
+  }
+  if parser.GetNonFiniteCount() != 2 {
+    t.Errorf("Expected 2 replaced values after reset, Actual %v.", parser.GetNonFiniteCount());
+  }
+};
+
+func TestFitTransform(t *testing.T) {
+  contents, _ := ioutil.ReadFile(dataPath + dataFileName);
+  reference := parse.NewParser();
+  expected := mustMatrix(t, reference, dataLabel, mustBytesToJSON(t, reference, contents));
+
+  parser := parse.NewParser();
+  data := mustBytesToJSON(t, parser, contents)[dataLabel].([]interface{});
+  if _, err := parser.Transform(data[0].(map[string]interface{})); err == nil {
+    t.Error("Transform before Fit should return an error.");
+  }
+  matrix, err := parser.FitTransform(data);
+  if err != nil {
+    t.Fatalf("Unexpected error fitting the data: %v", err);
+  }
+  if len(matrix) != len(expected) {
+    t.Fatalf("Expected %v rows, Actual %v.", len(expected), len(matrix));
+  }
+  // Schema keys come from map iteration, so compare columns by key.
+  column := make(map[string]int);
+  for j, key := range reference.GetSchemaKeys() {
+    column[key] = j;
+  }
+  for i := range expected {
+    for j, key := range parser.GetSchemaKeys() {
+      if matrix[i][j] != expected[i][column[key]] {
+        t.Errorf("Row %v field %v: expected %v, actual %v.", i, key, expected[i][column[key]], matrix[i][j]);
+      }
+    }
+  }
+
+  // Refitting replaces the schema rather than appending to it.
+  if err := parser.Fit(data); err != nil || len(parser.GetSchemaKeys()) != len(reference.GetSchemaKeys()) {
+    t.Errorf("Refitting should keep %v schema keys, got %v (%v).", len(reference.GetSchemaKeys()), parser.GetSchemaKeys(), err);
+  }
+
+  key := parser.GetSchemaKeys()[0];
+  if _, err := parser.Transform(map[string]interface{}{}); err == nil {
+    t.Error("Transform should reject an object missing schema fields.");
+  }
+  bad := map[string]interface{}{};
+  for _, k := range parser.GetSchemaKeys() {
+    bad[k] = 1.0;
+  }
+  bad[key] = "tall";
+  if _, err := parser.Transform(bad); err == nil {
+    t.Error("Transform should reject non-numeric values.");
+  }
+  // Strings are categories, but other values cannot be encoded.
+  bad[key] = true;
+  if err := parse.NewParser().Fit([]interface{}{bad}); err == nil {
+    t.Error("Fit should reject values that are neither numbers nor strings.");
+  }
+  if err := parse.NewParser().Fit([]interface{}{"not an object"}); err == nil {
+    t.Error("Fit should reject entries that are not objects.");
+  }
+  if err := parse.NewParser().Fit(nil); err == nil {
+    t.Error("Fit should reject an empty data set.");
+  }
+};
+
+func TestExportStats(t *testing.T) {
+  parser := parse.NewParser();
+  if _, err := parser.ExportStats(); err == nil {
+    t.Error("Exporting without a schema should return an error.");
+  }
+  contents, _ := ioutil.ReadFile(dataPath + dataFileName);
+  jsonData := mustBytesToJSON(t, parser, contents);
+  data := mustMatrix(t, parser, dataLabel, jsonData);
+
+  exported, err := parser.ExportStats();
+  if err != nil {
+    t.Fatalf("Unexpected error exporting stats: %v", err);
+  }
+  var stats []parse.FieldStats;
+  if err := json.Unmarshal(exported, &stats); err != nil {
+    t.Fatalf("Exported stats should be a JSON array: %v", err);
+  }
+  keys := parser.GetSchemaKeys();
+  if len(stats) != len(keys) {
+    t.Fatalf("Expected stats for %v fields, Actual %v.", len(keys), len(stats));
+  }
+  first := jsonData[dataLabel].([]interface{})[0].(map[string]interface{});
+  for i, field := range stats {
+    if field.Field != keys[i] || field.Scaler != "minmax" {
+      t.Errorf("Field %v: expected a minmax scaler for %v, Actual %+v.", i, keys[i], field);
+    }
+    if field.ObservedMin > field.ObservedMax {
+      t.Errorf("Field %v: observed min %v is above the max %v.", i, field.ObservedMin, field.ObservedMax);
+    }
+    // The exported scaler reproduces the parser's normalization.
+    value, _ := parser.ConvertInterfaceToFloat64(first[field.Field]);
+    if scaled := (value - field.Min) / (field.Max - field.Min); scaled != data[0][i] {
+      t.Errorf("Field %v: the exported scaler gives %v, the parser %v.", field.Field, scaled, data[0][i]);
+    }
+  }
+};
+
+func TestLargeNumberPolicy(t *testing.T) {
+  contents := []byte(`{"id": 9007199254740993, "big": 123456789012345678901234, "small": 42, "ratio": 1.5}`);
+
+  parser := parse.NewParser();
+  if value, err := parser.ConvertInterfaceToFloat64(mustBytesToJSON(t, parser, contents)["small"]); err != nil || value != 42 {
+    t.Errorf("By default numbers should decode as floats, Actual %v, %v.", value, err);
+  }
+
+  parser.SetLargeNumberPolicy(parse.LargeNumberError);
+  jsonData := mustBytesToJSON(t, parser, contents);
+  for _, key := range []string{"id", "big"} {
+    if _, err := parser.ConvertInterfaceToFloat64(jsonData[key]); err == nil {
+      t.Errorf("Converting %v should fail under LargeNumberError.", key);
+    }
+  }
+  if _, err := parser.ConvertInterfaceToFloat64(int64(1) << 60); err == nil {
+    t.Error("Converting a large int64 should fail under LargeNumberError.");
+  }
+  if value, err := parser.ConvertInterfaceToFloat64(jsonData["small"]); err != nil || value != 42 {
+    t.Errorf("Small integers should convert exactly, Actual %v, %v.", value, err);
+  }
+  if value, err := parser.ConvertInterfaceToFloat64(jsonData["ratio"]); err != nil || value != 1.5 {
+    t.Errorf("Fractions should convert as floats, Actual %v, %v.", value, err);
+  }
+
+  parser.SetLargeNumberPolicy(parse.LargeNumberLabel);
+  id, err := parser.ConvertInterfaceToFloat64(jsonData["id"]);
+  if err != nil {
+    t.Fatalf("Unexpected error under LargeNumberLabel: %v", err);
+  }
+  big, _ := parser.ConvertInterfaceToFloat64(jsonData["big"]);
+  again, _ := parser.ConvertInterfaceToFloat64(json.Number("9007199254740993"));
+  if id == big || id != again {
+    t.Errorf("Each distinct id should keep its own code, Actual %v, %v, %v.", id, big, again);
+  }
+  if code, ok := parser.GetLabelCode("9007199254740993"); !ok || code != id {
+    t.Errorf("Expected the code %v for the id, Actual %v, %v.", id, code, ok);
+  }
+  // 2^53 is still exact, so it converts as a plain number.
+  if exact, _ := parser.ConvertInterfaceToFloat64(json.Number("9007199254740992")); exact != 9007199254740992 {
+    t.Errorf("2^53 should convert exactly, Actual %v.", exact);
+  }
+};
+
+func TestParserLogger(t *testing.T) {
+  parser := parse.NewParser();
+  logger := &recordingLogger{};
+  parser.SetLogger(logger);
+  if err := parser.Fit([]interface{}{map[string]interface{}{"a": 1.0, "b": 2.0}}); err != nil {
+    t.Fatalf("Unexpected error fitting: %v", err);
+  }
+  if !logger.contains("parse: schema fitted with 2 fields from 1 entries") {
+    t.Errorf("Expected the fitted schema to be logged, Actual %q.", logger.messages);
+  }
+};
+
+func TestWriteCSV(t *testing.T) {
+  parser := parse.NewParser();
+  var buffer bytes.Buffer;
+  if err := parser.WriteCSV(&buffer, [][]float64{{1}}, true); err == nil {
+    t.Error("Writing without a schema should return an error.");
+  }
+  contents, _ := ioutil.ReadFile(dataPath + dataFileName);
+  data := mustMatrix(t, parser, dataLabel, mustBytesToJSON(t, parser, contents));
+  if err := parser.WriteCSV(&buffer, data, true); err != nil {
+    t.Fatalf("Unexpected error writing CSV: %v", err);
+  }
+  records, err := csv.NewReader(&buffer).ReadAll();
+  if err != nil {
+    t.Fatalf("The output should be valid CSV: %v", err);
+  }
+  if len(records) != len(data) + 1 || !reflect.DeepEqual(records[0], parser.GetSchemaKeys()) {
+    t.Fatalf("Expected a header of the schema keys and %v rows, Actual %v records.", len(data), len(records));
+  }
+  for i, row := range data {
+    for j, value := range row {
+      if parsed, _ := strconv.ParseFloat(records[i + 1][j], 64); parsed != value {
+        t.Errorf("Row %v column %v: expected %v, Actual %v.", i, j, value, records[i + 1][j]);
+      }
+    }
+  }
+
+  buffer.Reset();
+  if err := parser.WriteCSV(&buffer, data[:1], false); err != nil {
+    t.Fatalf("Unexpected error writing CSV: %v", err);
+  }
+  if records, _ := csv.NewReader(&buffer).ReadAll(); len(records) != 1 {
+    t.Errorf("Without a header expected 1 record, Actual %v.", len(records));
+  }
+  if err := parser.WriteCSV(&buffer, [][]float64{make([]float64, len(data[0]) + 1)}, false); err == nil {
+    t.Error("A row that does not match the schema should return an error.");
+  }
+};
+
+func TestAppendFeature(t *testing.T) {
+  parser := parse.NewParser();
+  data := []interface{}{
+    map[string]interface{}{"a": 1.0, "b": 2.0},
+    map[string]interface{}{"a": 3.0, "b": 4.0},
+  };
+  old, err := parser.FitTransform(data);
+  if err != nil {
+    t.Fatalf("Unexpected error fitting: %v", err);
+  }
+  keys := append([]string{}, parser.GetSchemaKeys()...);
+
+  if err := parser.AppendFeature("c", 0, 10); err != nil {
+    t.Fatalf("Unexpected error appending a feature: %v", err);
+  }
+  if err := parser.AppendFeature("a", 0, 1); err == nil {
+    t.Error("Appending a field already in the schema should return an error.");
+  }
+  if err := parser.AppendFeature("d", 2, 1); err == nil {
+    t.Error("Appending a field with its minimum above its maximum should return an error.");
+  }
+  if !reflect.DeepEqual(parser.GetSchemaKeys(), append(keys, "c")) {
+    t.Fatalf("The new field should be appended after %v, Actual %v.", keys, parser.GetSchemaKeys());
+  }
+
+  vec, err := parser.Transform(map[string]interface{}{"a": 1.0, "b": 2.0, "c": 5.0});
+  if err != nil {
+    t.Fatalf("Unexpected error transforming with the new field: %v", err);
+  }
+  padded := parser.PadVector(old[0]);
+  if len(padded) != 3 || padded[2] != 0 || !reflect.DeepEqual(padded[:2], vec[:2]) {
+    t.Errorf("An old vector should keep its positions and be zero padded, Actual %v against %v.", padded, vec);
+  }
+
+  stats, _ := parser.ExportStats();
+  var fields []parse.FieldStats;
+  json.Unmarshal(stats, &fields);
+  if last := fields[len(fields) - 1]; last.Field != "c" || last.ObservedMin != 0 || last.ObservedMax != 10 {
+    t.Errorf("Expected the initial bounds of the new field, Actual %+v.", last);
+  }
+};
+
+func TestReportCorrelatedFeatures(t *testing.T) {
+  parser := parse.NewParser();
+  var data []interface{};
+  for i := 0; i < 50; i++ {
+    x := float64(i);
+    data = append(data, map[string]interface{}{"a": x, "b": 3 * x + 1, "c": float64((i * 7) % 11), "d": -x, "e": 1.0});
+  }
+  if _, err := parser.FitTransform(data); err != nil {
+    t.Fatalf("Unexpected error fitting: %v", err);
+  }
+  expected := [][2]string{{"a", "b"}, {"a", "d"}, {"b", "d"}};
+  if pairs := parser.ReportCorrelatedFeatures(0.95); !reflect.DeepEqual(pairs, expected) {
+    t.Errorf("Expected the correlated pairs %v, Actual %v.", expected, pairs);
+  }
+  if pairs := parser.ReportCorrelatedFeatures(1); len(pairs) != 0 {
+    t.Errorf("No pair can be correlated above 1, Actual %v.", pairs);
+  }
+  if pairs := parse.NewParser().ReportCorrelatedFeatures(0); len(pairs) != 0 {
+    t.Errorf("A parser without data should report nothing, Actual %v.", pairs);
+  }
+};
+
+func TestPerFieldNormalization(t *testing.T) {
+  parser := parse.NewParser();
+  contents := []byte(`{"rows": [
+    {"salary": 30000, "age": 20, "flag": 1},
+    {"salary": 90000, "age": 60, "flag": 1},
+    {"salary": 60000, "age": 35, "flag": 1}
+  ]}`);
+  original := mustBytesToJSON(t, parser, contents);
+  data := mustMatrix(t, parser, "rows", original);
+  for i, row := range data {
+    for j, value := range row {
+      if math.IsNaN(value) || value < 0 || value > 1 {
+        t.Errorf("Row %v field %v: expected a value in [0, 1], Actual %v.", i, parser.GetSchemaKeys()[j], value);
+      }
+    }
+  }
+  // Each field spans [0, 1] on its own, and the constant field is 0.
+  keys := parser.GetSchemaKeys();
+  for j, key := range keys {
+    low, high := data[0][j], data[0][j];
+    for _, row := range data {
+      low, high = math.Min(low, row[j]), math.Max(high, row[j]);
+    }
+    if key == "flag" && (low != 0 || high != 0) {
+      t.Errorf("A constant field should normalize to 0, Actual [%v, %v].", low, high);
+    } else if key != "flag" && (low != 0 || high != 1) {
+      t.Errorf("Field %v should span [0, 1], Actual [%v, %v].", key, low, high);
+    }
+  }
+
+  restored := parser.Float64MatrixToJSON("rows", data)["rows"].([]interface{});
+  for i, object := range original["rows"].([]interface{}) {
+    for key, value := range object.(map[string]interface{}) {
+      expected, _ := parser.ConvertInterfaceToFloat64(value);
+      actual, _ := parser.ConvertInterfaceToFloat64(restored[i].(map[string]interface{})[key]);
+      if parse.ToFixed(actual, 9) != parse.ToFixed(expected, 9) {
+        t.Errorf("Row %v field %v: expected the round trip to give %v, Actual %v.", i, key, expected, actual);
+      }
+    }
+  }
+  if parse.NormalizeWith(5, 5, 5) != 0 || parse.DeNormalizeWith(0.3, 5, 5) != 5 {
+    t.Error("A field without range should normalize to 0 and back to its value.");
+  }
+};
+
+var categoricalContents = []byte(`{"cars": [
+  {"color": "red", "doors": 2, "make": "ford"},
+  {"color": "blue", "doors": 4, "make": "ford"},
+  {"color": "red", "doors": 4, "make": "fiat"},
+  {"color": "green", "doors": 2, "make": "ford"}
+]}`);
+
+func TestCategoricalOrdinal(t *testing.T) {
+  parser := parse.NewParser();
+  original := mustBytesToJSON(t, parser, categoricalContents);
+  data := mustMatrix(t, parser, "cars", original);
+  if keys := parser.GetSchemaKeys(); !reflect.DeepEqual(keys, []string{"color", "doors", "make"}) {
+    t.Fatalf("Expected one column per field, Actual %v.", keys);
+  }
+  // Codes follow the order the categories are first seen, scaled by the codes seen.
+  colors := []float64{0, 0.5, 0, 1};
+  for i, row := range data {
+    if row[0] != colors[i] {
+      t.Errorf("Row %v: expected the color code %v, Actual %v.", i, colors[i], row[0]);
+    }
+  }
+  restored := parser.Float64MatrixToJSON("cars", data)["cars"].([]interface{});
+  for i, object := range original["cars"].([]interface{}) {
+    for _, key := range []string{"color", "make"} {
+      if actual := restored[i].(map[string]interface{})[key]; actual != object.(map[string]interface{})[key] {
+        t.Errorf("Row %v field %v: expected the round trip to give %v, Actual %v.", i, key, object.(map[string]interface{})[key], actual);
+      }
+    }
+  }
+
+  // A category the schema was not created with is refused, and the schema
+  // stays as it was.
+  unseen := map[string]interface{}{"color": "black", "doors": 2.0, "make": "ford"};
+  if _, err := parser.Transform(unseen); err == nil || !strings.Contains(err.Error(), "black") {
+    t.Errorf("Transforming an unseen category should name it in an error, Actual %v.", err);
+  }
+  vector := parser.JSONToFloat64(unseen);
+  if !math.IsNaN(vector[0]) {
+    t.Errorf("An unseen category should stand in as NaN, Actual %v.", vector[0]);
+  }
+  decoded := parser.Float64MatrixToJSON("cars", [][]float64{vector})["cars"].([]interface{})[0].(map[string]interface{});
+  for _, known := range []string{"red", "blue", "green"} {
+    if decoded["color"] == known {
+      t.Errorf("An unseen category should never decode to the existing category %v.", known);
+    }
+  }
+  stats, err := parser.FieldStatistics();
+  if err != nil {
+    t.Fatalf("Unexpected error exporting the schema: %v", err);
+  }
+  if !reflect.DeepEqual(stats[0].Categories, []string{"red", "blue", "green"}) {
+    t.Errorf("Converting an unseen category should not add it to the schema, Actual %v.", stats[0].Categories);
+  }
+  if known, err := parser.Transform(map[string]interface{}{"color": "green", "doors": 2.0, "make": "ford"}); err != nil || known[0] != 1 {
+    t.Errorf("A known category should keep its code, Actual %v, %v.", known, err);
+  }
+};
+
+func TestCategoricalOneHot(t *testing.T) {
+  parser := parse.NewParser();
+  parser.SetCategoricalMode(parse.OneHot);
+  original := mustBytesToJSON(t, parser, categoricalContents);
+  data := mustMatrix(t, parser, "cars", original);
+  expectedKeys := []string{"color=red", "color=blue", "color=green", "doors", "make=ford", "make=fiat"};
+  if keys := parser.GetSchemaKeys(); !reflect.DeepEqual(keys, expectedKeys) {
+    t.Fatalf("Expected the columns %v, Actual %v.", expectedKeys, keys);
+  }
+  if !reflect.DeepEqual(data[1], []float64{0, 1, 0, 1, 1, 0}) {
+    t.Errorf("Expected the second row to be [0 1 0 1 1 0], Actual %v.", data[1]);
+  }
+  restored := parser.Float64MatrixToJSON("cars", data)["cars"].([]interface{});
+  for i, object := range original["cars"].([]interface{}) {
+    for key, value := range object.(map[string]interface{}) {
+      actual := restored[i].(map[string]interface{})[key];
+      if number, ok := value.(float64); ok {
+        if actual.(float64) != number {
+          t.Errorf("Row %v field %v: expected %v, Actual %v.", i, key, number, actual);
+        }
+      } else if actual != value {
+        t.Errorf("Row %v field %v: expected the round trip to give %v, Actual %v.", i, key, value, actual);
+      }
+    }
+  }
+  // A centroid between categories takes the heaviest one.
+  if color := parser.Float64ToJSON([]float64{0.25, 0.5, 0.25, 0, 1, 0})["color"]; color != "blue" {
+    t.Errorf("Expected the heaviest category blue, Actual %v.", color);
+  }
+
+  // An unseen category has no column and leaves every column of its field at 0.
+  unseen, err := parser.Transform(map[string]interface{}{"color": "black", "doors": 4.0, "make": "fiat"});
+  if err != nil {
+    t.Fatalf("Unexpected error transforming an unseen category: %v", err);
+  }
+  if !reflect.DeepEqual(unseen[:3], []float64{0, 0, 0}) || unseen[5] != 1 {
+    t.Errorf("Expected no color column set and the fiat column set, Actual %v.", unseen);
+  }
+  if color := parser.Float64ToJSON(unseen)["color"]; color != nil {
+    t.Errorf("A field without a hot column should restore as null, Actual %v.", color);
+  }
+  if _, err := parser.Transform(map[string]interface{}{"doors": 4.0, "make": "fiat"}); err == nil {
+    t.Error("Transforming an object missing a categorical field should return an error.");
+  }
+
+  stats, err := parser.FieldStatistics();
+  if err != nil {
+    t.Fatalf("Unexpected error exporting the schema: %v", err);
+  }
+  imported := parse.NewParser();
+  if err := imported.ImportStats("cars", stats); err != nil {
+    t.Fatalf("Unexpected error importing the schema: %v", err);
+  }
+  for i, object := range original["cars"].([]interface{}) {
+    if vec, _ := imported.Transform(object.(map[string]interface{})); !reflect.DeepEqual(vec, data[i]) {
+      t.Errorf("Row %v: the imported schema gives %v, the original %v.", i, vec, data[i]);
+    }
+  }
+};
+
+// BytesToJSON, failing the test on an error.
+func mustBytesToJSON(t *testing.T, parser *parse.Parser, contents []byte) map[string]interface{} {
+  jsonData, err := parser.BytesToJSON(contents);
+  if err != nil {
+    t.Fatalf("Unexpected error decoding JSON: %v", err);
+  }
+  return jsonData;
+};
+
+// JSONToFloat64Matrix, failing the test on an error.
+func mustMatrix(t *testing.T, parser *parse.Parser, label string, dataSet map[string]interface{}) [][]float64 {
+  matrix, err := parser.JSONToFloat64Matrix(label, dataSet);
+  if err != nil {
+    t.Fatalf("Unexpected error converting JSON: %v", err);
+  }
+  return matrix;
+};
+
+func TestParserMalformedInput(t *testing.T) {
+  parser := parse.NewParser();
+  for _, contents := range []string{`{"people": [{"a": 1}`, `not json`, `[1, 2]`, ``} {
+    if _, err := parser.BytesToJSON([]byte(contents)); err == nil {
+      t.Errorf("Decoding %q should return an error.", contents);
+    }
+  }
+  jsonData := mustBytesToJSON(t, parser, []byte(`{"people": [{"a": 1}], "count": 1, "mixed": [{"a": 1}, 2]}`));
+  for _, label := range []string{"cars", "count", "mixed"} {
+    if _, err := parser.JSONToFloat64Matrix(label, jsonData); err == nil {
+      t.Errorf("Converting the label %v should return an error.", label);
+    }
+  }
+  if len(parser.GetSchemaKeys()) != 0 || parser.GetLabel() != "" {
+    t.Errorf("A rejected data set should leave the parser without a schema, Actual %v.", parser.GetSchemaKeys());
+  }
+  if data := mustMatrix(t, parser, "people", jsonData); len(data) != 1 {
+    t.Errorf("Expected one row, Actual %v.", data);
+  }
+};
+
+func TestCSVToFloat64Matrix(t *testing.T) {
+  contents := "height,weight\n5.5,120\n6,180\n5,150\n";
+  parser := parse.NewParser();
+  data, err := parser.CSVToFloat64Matrix(strings.NewReader(contents));
+  if err != nil {
+    t.Fatalf("Unexpected error reading CSV: %v", err);
+  }
+  if !reflect.DeepEqual(parser.GetSchemaKeys(), []string{"height", "weight"}) {
+    t.Fatalf("Expected the header as the schema keys, Actual %v.", parser.GetSchemaKeys());
+  }
+  expected := [][]float64{{0.5, 0}, {1, 1}, {0, 0.5}};
+  if !reflect.DeepEqual(data, expected) {
+    t.Errorf("Expected the normalized matrix %v, Actual %v.", expected, data);
+  }
+  // The same table as JSON converts identically.
+  reference := parse.NewParser();
+  jsonData := mustMatrix(t, reference, dataLabel, mustBytesToJSON(t, reference,
+    []byte(`{"people": [{"height": 5.5, "weight": 120}, {"height": 6, "weight": 180}, {"height": 5, "weight": 150}]}`)));
+  if !reflect.DeepEqual(jsonData, data) {
+    t.Errorf("CSV and JSON should convert alike, JSON %v, CSV %v.", jsonData, data);
+  }
+  if weight := parser.Float64ToJSON(data[1])["weight"]; weight != 180.0 {
+    t.Errorf("Expected the round trip to restore the weight 180, Actual %v.", weight);
+  }
+
+  labeled := parse.NewParser();
+  labeled.SetCSVRowLabels(true);
+  data, err = labeled.CSVToFloat64Matrix(strings.NewReader("id,height,weight\na,5.5,120\nb,6,180\nc,5,150\n"));
+  if err != nil {
+    t.Fatalf("Unexpected error reading labeled CSV: %v", err);
+  }
+  if !reflect.DeepEqual(data, expected) || !reflect.DeepEqual(labeled.GetSchemaKeys(), []string{"height", "weight"}) {
+    t.Errorf("The label column should be left out of the vectors, Actual %v with keys %v.", data, labeled.GetSchemaKeys());
+  }
+  if !reflect.DeepEqual(labeled.GetRowLabels(), []string{"a", "b", "c"}) {
+    t.Errorf("Expected the row labels [a b c], Actual %v.", labeled.GetRowLabels());
+  }
+
+  for contents, line := range map[string]string{
+    "height,weight\n5.5,120\n6\n": "line 3",
+    "height,weight\n5.5,120\n6,heavy\n": "line 3",
+    "height,weight\n5.5,120,1\n": "line 2",
+  } {
+    _, err := parse.NewParser().CSVToFloat64Matrix(strings.NewReader(contents));
+    if err == nil || !strings.Contains(err.Error(), line) {
+      t.Errorf("Reading %q should fail on %v, Actual %v.", contents, line, err);
+    }
+  }
+  if _, err := parse.NewParser().CSVToFloat64Matrix(strings.NewReader("")); err == nil {
+    t.Error("Reading CSV without a header should return an error.");
+  }
+};
@@ -1,9 +1,11 @@
 package tests;
 
 import (
+    "reflect"
     "testing"
     "time"
     "fmt"
+    "math"
     "math/rand"
     "github.com/wenkesj/rphash/projector"
 );
@@ -11,7 +13,7 @@ import (
 func TestDBFriendly(t *testing.T) {
     //There is probably a better way to test this than hard coding.
     data := []float64{1.0,0.0,2.0,7.0,4.0,0.0,8.0,3.0,2.0,1.0};
-    expectedResult := []float64{1.224744871391589, 13.472193585307478};
+    expectedResult := []float64{7.348469228349534, -15.921683328090657};
     var inDimensions, outDimentions int = 10, 2;
     //Use a uniform seed for testing
     var seed int64 = 0;
@@ -28,6 +30,29 @@ func TestDBFriendly(t *testing.T) {
     t.Log("√ DBFriendly Projector test complete");
 }
 
+func TestDBFriendlyPreservesNorms(t *testing.T) {
+    // The projection is scaled so squared norms are preserved in expectation.
+    var inDimensions, outDimensions, numVectors int = 1000, 100, 200;
+    randomGen := rand.New(rand.NewSource(1));
+    RP := projector.NewDBFriendly(inDimensions, outDimensions, 0);
+    var ratioSum float64;
+    for n := 0; n < numVectors; n++ {
+        data := make([]float64, inDimensions);
+        var original, projected float64;
+        for i := range data {
+            data[i] = randomGen.NormFloat64() + 1;
+            original += data[i] * data[i];
+        }
+        for _, val := range RP.Project(data) {
+            projected += val * val;
+        }
+        ratioSum += projected / original;
+    }
+    if ratio := ratioSum / float64(numVectors); ratio < 0.95 || ratio > 1.05 {
+        t.Errorf("Projected squared norms should average the original, Actual ratio %f.", ratio);
+    }
+}
+
 func BenchmarkDBFriendlyProjection(b *testing.B) {
     var inDimensions, outDimentions int = 10, 2;
     for i := 0; i < b.N; i++ {
@@ -43,3 +68,299 @@ func BenchmarkDBFriendlyProjection(b *testing.B) {
         RP.Project(data);
     }
 }
+
+func TestDBFriendlyStrict(t *testing.T) {
+    var seed int64 = 0;
+    if _, err := projector.NewDBFriendlyStrict(10, 10, seed); err == nil {
+        t.Error("A target dimension equal to the input dimension should be rejected.");
+    }
+    if _, err := projector.NewDBFriendlyStrict(10, 20, seed); err == nil {
+        t.Error("A target dimension larger than the input dimension should be rejected.");
+    }
+    RP, err := projector.NewDBFriendlyStrict(10, 2, seed);
+    if err != nil || RP == nil {
+        t.Errorf("A reducing target dimension should be accepted, got error %v.", err);
+    }
+}
+
+func TestJLDimension(t *testing.T) {
+    // 4 ln(1000) / (0.5^2 / 2 - 0.5^3 / 3) = 331.5...
+    if dim := projector.JLDimension(1000, 0.5); dim != 332 {
+        t.Errorf("Expected a JL dimension of 332 for 1000 points at epsilon 0.5, Actual %d.", dim);
+    }
+    if projector.JLDimension(1000, 0.1) <= projector.JLDimension(1000, 0.5) {
+        t.Error("A tighter epsilon should require a larger target dimension.");
+    }
+}
+
+func TestDBFriendlyTail(t *testing.T) {
+  p := projector.NewDBFriendly(6, 3, 0);
+  vec := []float64{1, 2, 3, 4, 5, 6};
+  labeled := append(append([]float64{}, vec...), 99);
+
+  if err := p.CheckLength(labeled); err == nil {
+    t.Error("A longer vector should be refused by default.");
+  }
+  if err := p.CheckLength(vec[:5]); err == nil {
+    t.Error("A shorter vector should be refused.");
+  }
+  func() {
+    defer func() {
+      if recover() == nil {
+        t.Error("Projecting a longer vector should panic by default.");
+      }
+    }();
+    p.Project(labeled);
+  }();
+
+  p.SetIgnoreTail(true);
+  if err := p.CheckLength(labeled); err != nil {
+    t.Errorf("A longer vector should be accepted when ignoring the tail, got %v.", err);
+  }
+  if !reflect.DeepEqual(p.Project(labeled), p.Project(vec)) {
+    t.Error("The tail should not change the projection.");
+  }
+  if err := p.CheckLength(vec[:5]); err == nil {
+    t.Error("A shorter vector should be refused even when ignoring the tail.");
+  }
+};
+
+func TestDBFriendlyMeasureDistortion(t *testing.T) {
+  random := rand.New(rand.NewSource(0));
+  sample := make([][]float64, 50);
+  for i := range sample {
+    sample[i] = make([]float64, 1000);
+    for j := range sample[i] {
+      sample[i][j] = random.NormFloat64();
+    }
+  }
+  wide, narrow := projector.NewDBFriendly(1000, 500, 1), projector.NewDBFriendly(1000, 5, 1);
+  wideMean, wideMax := wide.MeasureDistortion(sample);
+  narrowMean, narrowMax := narrow.MeasureDistortion(sample);
+  if wideMean < 0.9 || wideMean > 1.1 {
+    t.Errorf("A wide projection should keep distances on average, mean ratio %v.", wideMean);
+  }
+  if wideMax < 1 || wideMax > 1.25 {
+    t.Errorf("A wide projection should distort no pair much, max ratio %v.", wideMax);
+  }
+  if narrowMax <= wideMax {
+    t.Errorf("A narrow projection should distort more, max ratio %v against %v (mean %v).", narrowMax, wideMax, narrowMean);
+  }
+
+  if mean, max := wide.MeasureDistortion(sample[:1]); mean != 0 || max != 0 {
+    t.Errorf("A single vector has no pairs, Actual %v and %v.", mean, max);
+  }
+};
+
+func TestGaussianMeasureDistortion(t *testing.T) {
+  random := rand.New(rand.NewSource(42));
+  sample := make([][]float64, 50);
+  for i := range sample {
+    sample[i] = make([]float64, 200);
+    for j := range sample[i] {
+      sample[i][j] = random.NormFloat64();
+    }
+  }
+  // Average over seeds, one projection says little about a small target.
+  var sparseMax, denseMax float64;
+  for seed := int64(0); seed < 10; seed++ {
+    sparse, dense := projector.NewDBFriendly(200, 8, seed), projector.NewGaussian(200, 8, seed);
+    _, max := sparse.MeasureDistortion(sample);
+    sparseMax += max / 10;
+    mean, max := dense.MeasureDistortion(sample);
+    denseMax += max / 10;
+    if mean < 0.75 || mean > 1.25 {
+      t.Errorf("A Gaussian projection should keep distances on average, mean ratio %v for seed %d.", mean, seed);
+    }
+  }
+  if denseMax > sparseMax * 1.1 {
+    t.Errorf("A Gaussian projection should distort no more than the sparse one, max ratio %v against %v.", denseMax, sparseMax);
+  }
+  t.Logf("Mean worst distortion over seeds, sparse %v, Gaussian %v.", sparseMax, denseMax);
+
+  if err := projector.NewGaussian(200, 8, 0).CheckLength(sample[0][:10]); err == nil {
+    t.Error("A vector of the wrong length should be rejected.");
+  }
+};
+
+func TestDBFriendlyDimensionWeights(t *testing.T) {
+    var seed int64 = 4;
+    RP := projector.NewDBFriendly(30, 6, seed);
+    if err := RP.FitDimensionWeights(nil); err == nil {
+        t.Error("Fitting to an empty sample should return an error.");
+    }
+    if err := RP.FitDimensionWeights([][]float64{make([]float64, 29)}); err == nil {
+        t.Error("Fitting to a vector of the wrong length should return an error.");
+    }
+
+    randomGen := rand.New(rand.NewSource(seed));
+    sample := make([][]float64, 200);
+    for i := range sample {
+        sample[i] = make([]float64, 30);
+        for j := range sample[i] {
+            sample[i][j] = randomGen.NormFloat64() * float64(j + 1);
+        }
+    }
+    unweighted := make([][]float64, len(sample));
+    variances := make([]float64, 6);
+    for i, vec := range sample {
+        unweighted[i] = RP.Project(vec);
+    }
+    for d := range variances {
+        var mean, square float64;
+        for _, vec := range unweighted {
+            mean += vec[d] / float64(len(unweighted));
+            square += vec[d] * vec[d] / float64(len(unweighted));
+        }
+        variances[d] = square - mean * mean;
+    }
+
+    if err := RP.FitDimensionWeights(sample); err != nil {
+        t.Fatalf("Unexpected error fitting the weights: %v", err);
+    }
+    weights := RP.GetDimensionWeights();
+    var total float64;
+    for d, weight := range weights {
+        total += weight;
+        for e := range weights {
+            if variances[d] > variances[e] && weight <= weights[e] {
+                t.Errorf("Dimension %v varies more than %v but weighs %v against %v.", d, e, weight, weights[e]);
+            }
+        }
+    }
+    if len(weights) != 6 || math.Abs(total / 6 - 1) > 1e-9 {
+        t.Errorf("Expected 6 weights averaging 1, Actual %v.", weights);
+    }
+    for d, x := range RP.Project(sample[0]) {
+        if math.Abs(x - unweighted[0][d] * weights[d]) > 1e-9 {
+            t.Errorf("Dimension %v: expected the weighted %v, Actual %v.", d, unweighted[0][d] * weights[d], x);
+        }
+    }
+
+    RP.ClearDimensionWeights();
+    if !reflect.DeepEqual(RP.Project(sample[0]), unweighted[0]) {
+        t.Error("Clearing the weights should restore the plain projection.");
+    }
+    if err := RP.FitDimensionWeights([][]float64{sample[0], sample[0]}); err != nil || !reflect.DeepEqual(RP.GetDimensionWeights(), []float64{1, 1, 1, 1, 1, 1}) {
+        t.Errorf("A sample without variance should weigh every dimension 1, Actual %v, %v.", RP.GetDimensionWeights(), err);
+    }
+};
+
+func TestDBFriendlyBasisVectors(t *testing.T) {
+    // A basis vector picks out one column of the matrix, so every output is
+    // 0 or +/- sqrt(3 / t) and about a third of them are nonzero. A phantom
+    // v[0] term would show up as other magnitudes, or as nonzero outputs
+    // for the first basis vector in every row.
+    var inDimensions, outDimensions int = 120, 50;
+    RP := projector.NewDBFriendly(inDimensions, outDimensions, 3);
+    scale := math.Sqrt(3 / float64(outDimensions));
+    nonzero := 0;
+    for j := 0; j < inDimensions; j++ {
+        basis := make([]float64, inDimensions);
+        basis[j] = 1;
+        columnNonzero := 0;
+        for i, x := range RP.Project(basis) {
+            if x == 0 {
+                continue;
+            }
+            columnNonzero++;
+            if math.Abs(math.Abs(x) - scale) > 1e-12 {
+                t.Fatalf("Basis vector %d projected to %f at %d, expected 0 or +/-%f.", j, x, i, scale);
+            }
+        }
+        if columnNonzero == outDimensions {
+            t.Errorf("Basis vector %d is nonzero in every projected dimension.", j);
+        }
+        nonzero += columnNonzero;
+    }
+    fraction := float64(nonzero) / float64(inDimensions * outDimensions);
+    if math.Abs(fraction - 1.0 / 3) > 0.03 {
+        t.Errorf("Expected about a third of the matrix to be nonzero, Actual %f.", fraction);
+    }
+};
+
+func TestDBFriendlyReproject(t *testing.T) {
+    var inDimensions, outDimensions int = 100, 40;
+    RP := projector.NewDBFriendly(inDimensions, outDimensions, 5);
+    for i := 0; i < inDimensions; i++ {
+        basis := make([]float64, inDimensions);
+        basis[i] = 1;
+        lifted := RP.Reproject(RP.Project(basis));
+        if len(lifted) != inDimensions {
+            t.Fatalf("Expected a reprojected vector of %d dimensions, Actual %d.", inDimensions, len(lifted));
+        }
+        largest := 0;
+        for j := range lifted {
+            if math.Abs(lifted[j]) > math.Abs(lifted[largest]) {
+                largest = j;
+            }
+        }
+        if largest != i {
+            t.Errorf("Basis vector %d reprojected with its largest mass at %d: %f against %f.", i, largest, lifted[largest], lifted[i]);
+        }
+    }
+
+    func() {
+        defer func() {
+            if recover() == nil {
+                t.Error("Reprojecting a vector of the wrong dimension should panic.");
+            }
+        }();
+        RP.Reproject(make([]float64, outDimensions + 1));
+    }();
+};
+
+func randomRows(rows, columns int, seed int64) [][]float64 {
+    random := rand.New(rand.NewSource(seed));
+    result := make([][]float64, rows);
+    for i := range result {
+        result[i] = make([]float64, columns);
+        for j := range result[i] {
+            result[i][j] = random.NormFloat64();
+        }
+    }
+    return result;
+};
+
+func TestProjectMatrixParallel(t *testing.T) {
+    data := randomRows(101, 60, 5);
+    RP := projector.NewDBFriendly(60, 12, 5);
+    serial := RP.ProjectMatrix(data);
+    for i, vec := range data {
+        if !reflect.DeepEqual(serial[i], RP.Project(vec)) {
+            t.Fatalf("Row %d of ProjectMatrix does not match Project.", i);
+        }
+    }
+    for _, workers := range []int{0, 1, 2, 3, 7, 200} {
+        if parallel := RP.ProjectMatrixParallel(data, workers); !reflect.DeepEqual(parallel, serial) {
+            t.Errorf("Projecting with %d workers should match the serial result.", workers);
+        }
+    }
+    gaussian := projector.NewGaussian(60, 12, 5);
+    if !reflect.DeepEqual(gaussian.ProjectMatrixParallel(data, 4), gaussian.ProjectMatrix(data)) {
+        t.Error("The Gaussian projection should match serially and in parallel.");
+    }
+    if result := RP.ProjectMatrixParallel(nil, 4); len(result) != 0 {
+        t.Errorf("Expected nothing from an empty matrix, Actual %v.", result);
+    }
+};
+
+func BenchmarkProjectLoop(b *testing.B) {
+    data := randomRows(2000, 500, 1);
+    RP := projector.NewDBFriendly(500, 50, 1);
+    b.ResetTimer();
+    for i := 0; i < b.N; i++ {
+        for _, vec := range data {
+            RP.Project(vec);
+        }
+    }
+};
+
+func BenchmarkProjectMatrixParallel(b *testing.B) {
+    data := randomRows(2000, 500, 1);
+    RP := projector.NewDBFriendly(500, 50, 1);
+    b.ResetTimer();
+    for i := 0; i < b.N; i++ {
+        RP.ProjectMatrixParallel(data, 0);
+    }
+};
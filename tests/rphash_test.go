@@ -0,0 +1,91 @@
+package tests;
+
+import (
+  "fmt"
+  "reflect"
+  "testing"
+  "github.com/wenkesj/rphash/api"
+  "github.com/wenkesj/rphash/defaults"
+  "github.com/wenkesj/rphash/reader"
+  "github.com/wenkesj/rphash/simple"
+  "github.com/wenkesj/rphash/types"
+  "github.com/wenkesj/rphash/utils"
+);
+
+func Example_rphash() {
+  data := generateBlobs([][]float64{{30, 0, 0, 0, 0, 0}, {0, 30, 0, 0, 0, 0}, {0, 0, 30, 0, 0, 0}}, 200, 1, 0);
+  centroids := api.New(data, 3, api.WithSeed(1)).Centroids();
+  fmt.Println(len(centroids));
+  // Output: 3
+}
+
+type decoderSpy struct {
+  types.Decoder;
+  decoded int;
+};
+
+func (this *decoderSpy) Decode(f []float64) []int64 {
+  this.decoded++;
+  return this.Decoder.Decode(f);
+};
+
+func TestRPHashOptions(t *testing.T) {
+  centers := [][]float64{{30, 0, 0, 0, 0, 0}, {0, 30, 0, 0, 0, 0}, {0, 0, 30, 0, 0, 0}};
+  data := generateBlobs(centers, 200, 1, 0);
+  decoder := &decoderSpy{Decoder: defaults.NewDecoder(3, 6, 1)};
+  rphash := api.New(data, 3, api.WithSeed(7), api.WithDecoder(decoder), api.WithHashModulus(1 << 31 - 1));
+
+  centroids := rphash.Centroids();
+  if decoder.decoded == 0 {
+    t.Errorf("Map should hash with the decoder the option set.");
+  }
+  if len(centroids) != len(centers) {
+    t.Fatalf("Expected %d centroids, got %d.", len(centers), len(centroids));
+  }
+  // The facade runs the same pipeline as wiring the object up by hand.
+  object := reader.NewSimpleArray(data, 3);
+  object.SetRandomSeed(7);
+  object.SetDecoderType(decoder);
+  object.SetHashModulus(1 << 31 - 1);
+  object.SetVariance(data);
+  expected := simple.NewSimple(object).GetCentroids();
+  if !reflect.DeepEqual(centroids, expected) {
+    t.Errorf("Expected the centroids of the hand-wired pipeline %v, Actual %v.", expected, centroids);
+  }
+
+  again := api.New(data, 3, api.WithSeed(7), api.WithDecoder(decoder), api.WithHashModulus(1 << 31 - 1)).Centroids();
+  if !reflect.DeepEqual(centroids, again) {
+    t.Errorf("The same seed should give the same centroids.");
+  }
+
+  // With a modulus of 1 every vector hashes to the same bucket.
+  single := api.New(data, 3, api.WithSeed(7), api.WithHashModulus(1));
+  if err := single.Run(); err != nil {
+    t.Fatalf("Unexpected error: %v", err);
+  }
+  if top := single.GetRPHash().GetPreviousTopID(); len(top) != 1 {
+    t.Errorf("Expected a single candidate bucket with a hash modulus of 1, Actual %v.", top);
+  }
+}
+
+func TestRPHashStream(t *testing.T) {
+  data := generateBlobs([][]float64{{30, 0, 0, 0, 0, 0}, {0, 30, 0, 0, 0, 0}, {0, 0, 30, 0, 0, 0}}, 200, 1, 0);
+  expected := api.New(data, 3, api.WithSeed(7)).Centroids();
+  stream := api.NewStream(utils.NewIterator(data), 6, 3, api.WithSeed(7));
+  if actual := stream.Centroids(); !reflect.DeepEqual(actual, expected) {
+    t.Errorf("A stream should give the centroids of the same data in memory. Expected %v, Actual %v.", expected, actual);
+  }
+  if stream.GetRPHash().NumDataPoints() != len(data) {
+    t.Errorf("Expected the stream to count %d vectors, Actual %d.", len(data), stream.GetRPHash().NumDataPoints());
+  }
+
+  // Projections and blurs are kept on either object for a stream.Stream.
+  for _, rphash := range []*api.RPHash{
+    api.New(data, 3, api.WithProjections(3), api.WithBlurs(4)),
+    api.NewStream(utils.NewIterator(data), 6, 3, api.WithProjections(3), api.WithBlurs(4)),
+  } {
+    if object := rphash.GetRPHash(); object.GetNumberOfProjections() != 3 || object.GetNumberOfBlurs() != 4 {
+      t.Errorf("Expected 3 projections and 4 blurs, Actual %d and %d.", object.GetNumberOfProjections(), object.GetNumberOfBlurs());
+    }
+  }
+}
@@ -2,13 +2,24 @@ package tests;
 
 import (
   "testing"
+  "github.com/wenkesj/rphash/generator"
+  "github.com/wenkesj/rphash/projector"
   "github.com/wenkesj/rphash/reader"
   "github.com/wenkesj/rphash/simple"
+  "github.com/wenkesj/rphash/types"
   "math/rand"
   "github.com/wenkesj/rphash/clusterer"
+  "github.com/wenkesj/rphash/defaults"
   "github.com/wenkesj/rphash/utils"
   "time"
   "fmt"
+  "strings"
+  "math"
+  "reflect"
+  "runtime"
+  "sync/atomic"
+  "io/ioutil"
+  "os"
 );
 
 func TestSimpleLeastDistanceVsKmeans(t *testing.T) {
@@ -104,3 +115,642 @@ func BenchmarkSimple(b *testing.B) {
     RPHashObject.GetCentroids();
   }
 };
+
+func TestSizeDistribution(t *testing.T) {
+  distribution := simple.NewSizeDistribution([]int64{40, 0, 10, 30, 20});
+  if distribution.Min != 0 || distribution.Max != 40 || distribution.Median != 20 {
+    t.Errorf("Expected min 0, median 20 and max 40, Actual %v, %v and %v.", distribution.Min, distribution.Median, distribution.Max);
+  }
+  if distribution.Empty != 1 {
+    t.Errorf("Expected 1 empty cluster, Actual %v.", distribution.Empty);
+  }
+  if p := distribution.Percentile(90); p != 36 {
+    t.Errorf("Expected the 90th percentile to interpolate to 36, Actual %v.", p);
+  }
+  if empty := simple.NewSizeDistribution(nil); empty.Percentile(50) != 0 || empty.Max != 0 {
+    t.Error("No clusters should report an all zero distribution.");
+  }
+};
+
+func TestSimpleSizeDistribution(t *testing.T) {
+  var numClusters = 3;
+  data := generator.NewGenerator(0).GenerateData(300, 10);
+  simpleObject := simple.NewSimple(reader.NewSimpleArray(data, numClusters));
+  sizes := simpleObject.GetClusterSizes();
+  if len(sizes) != numClusters {
+    t.Fatalf("Expected a size for each of %v clusters, Actual %v.", numClusters, sizes);
+  }
+  var total int64;
+  for _, size := range sizes {
+    total += size;
+  }
+  if total > int64(len(data)) {
+    t.Errorf("The clusters hold %v vectors, more than the %v in the data.", total, len(data));
+  }
+  distribution := simpleObject.SizeDistribution();
+  if distribution.Percentile(0) != float64(distribution.Min) || distribution.Percentile(100) != float64(distribution.Max) {
+    t.Errorf("The 0th and 100th percentiles should be the min and max, got %v.", distribution);
+  }
+};
+
+// seedSpy counts how often a run reads the object's own random seed.
+type seedSpy struct {
+  *reader.SimpleArray;
+  seedReads int;
+};
+
+func (this *seedSpy) GetRandomSeed() int64 {
+  this.seedReads++;
+  return this.SimpleArray.GetRandomSeed();
+};
+
+func TestSimpleRunOptions(t *testing.T) {
+  data := generator.NewGenerator(0).GenerateData(300, 10);
+  RPHashObject := &seedSpy{SimpleArray: reader.NewSimpleArray(data, 3)};
+  RPHashObject.SetRandomSeed(42);
+  simpleObject := simple.NewSimple(RPHashObject);
+
+  centroids, err := simpleObject.RunWith(simple.RunOptions{RandomSeed: 7, UseRandomSeed: true});
+  if err != nil {
+    t.Fatalf("Unexpected error running with options: %v", err);
+  }
+  if RPHashObject.seedReads != 0 {
+    t.Errorf("A run with a seed override should not read the object's seed, read it %v times.", RPHashObject.seedReads);
+  }
+  if RPHashObject.GetRandomSeed() != 42 || len(RPHashObject.GetCentroids()) != 0 || len(RPHashObject.GetPreviousTopID()) != 0 {
+    t.Error("Run options should leave the object's settings and results unchanged.");
+  }
+  seeded := reader.NewSimpleArray(data, 3);
+  seeded.SetRandomSeed(7);
+  if expected := simple.NewSimple(seeded).GetCentroids(); !reflect.DeepEqual(centroids, expected) {
+    t.Errorf("A run with seed 7 should match an object seeded with 7. Expected %v, Actual %v.", expected, centroids);
+  }
+
+  RPHashObject.seedReads = 0;
+  simpleObject.Run();
+  if RPHashObject.seedReads == 0 {
+    t.Error("A run without options should use the object's seed.");
+  }
+
+  // The copy a run reads from must come from the iterator.
+  stream := reader.NewStreamObject(10, 3);
+  stream.SetVectorIterator(struct{ types.Iterator }{utils.NewIterator(data)});
+  if _, err := simple.NewSimple(stream).RunWith(simple.RunOptions{}); err == nil {
+    t.Error("RunWith should refuse an iterator it cannot copy.");
+  }
+};
+
+func TestSimpleRunWithConcurrent(t *testing.T) {
+  data := generator.NewGenerator(0).GenerateData(500, 10);
+  RPHashObject := reader.NewSimpleArray(data, 3);
+  RPHashObject.SetRandomSeed(1);
+  RPHashObject.SetProjector(projector.NewDBFriendly(10, 5, 1));
+  simpleObject := simple.NewSimple(RPHashObject);
+  seeds := []int64{1, 2, 3, 4};
+  expected := make([][][]float64, len(seeds));
+  for i, seed := range seeds {
+    expected[i], _ = simpleObject.RunWith(simple.RunOptions{RandomSeed: seed, UseRandomSeed: seed % 2 == 0});
+  }
+
+  results := make([][][]float64, len(seeds));
+  errs := make([]error, len(seeds));
+  done := make(chan bool);
+  for i, seed := range seeds {
+    go func(i int, seed int64) {
+      results[i], errs[i] = simpleObject.RunWith(simple.RunOptions{RandomSeed: seed, UseRandomSeed: seed % 2 == 0});
+      done <- true;
+    }(i, seed);
+  }
+  for range seeds {
+    <-done;
+  }
+  for i := range seeds {
+    if errs[i] != nil {
+      t.Fatalf("Unexpected error in run %d: %v", i, errs[i]);
+    }
+    if !reflect.DeepEqual(results[i], expected[i]) {
+      t.Errorf("Run %d alongside others should match it run alone. Expected %v, Actual %v.", i, expected[i], results[i]);
+    }
+  }
+};
+
+func TestSimpleRunErrors(t *testing.T) {
+  data := generator.NewGenerator(0).GenerateData(10, 2);
+  var simpleObject types.Clusterer = simple.NewSimple(reader.NewSimpleArray(data, 0));
+  if err := simpleObject.Run(); err == nil {
+    t.Error("Running with a K of 0 should return an error.");
+  }
+  if centroids := simpleObject.GetCentroids(); centroids != nil {
+    t.Errorf("A failed run should have no centroids, got %v.", centroids);
+  }
+};
+
+type projectorSpy struct {
+  types.Projector;
+  projected int64;
+};
+
+func (this *projectorSpy) Project(v []float64) []float64 {
+  atomic.AddInt64(&this.projected, 1);
+  return this.Projector.Project(v);
+};
+
+func TestSimpleHeldProjector(t *testing.T) {
+  data := generator.NewGenerator(0).GenerateData(50, 20);
+  RPHashObject := reader.NewSimpleArray(data, 2);
+  // Simple hashes with a decoder of half the data's dimension.
+  spy := &projectorSpy{Projector: projector.NewDBFriendly(20, 10, 1)};
+  RPHashObject.SetProjector(spy);
+  simpleObject := simple.NewSimple(RPHashObject);
+
+  // Map hashes every vector once.
+  if err := simpleObject.Run(); err != nil {
+    t.Fatalf("Unexpected error running: %v", err);
+  }
+  if spy.projected != int64(len(data)) {
+    t.Errorf("Expected the held projector to project %v vectors, Actual %v.", len(data), spy.projected);
+  }
+
+  spy.projected = 0;
+  RPHashObject.SetCentroids(nil);
+  simpleObject.Run();
+  if spy.projected != int64(len(data)) {
+    t.Errorf("A second run should reuse the held projector, projected %v vectors.", spy.projected);
+  }
+
+  spy.projected = 0;
+  if _, err := simpleObject.RunWith(simple.RunOptions{RandomSeed: 3, UseRandomSeed: true}); err != nil {
+    t.Fatalf("Unexpected error running with a seed: %v", err);
+  }
+  if spy.projected != 0 {
+    t.Errorf("A run with its own seed should not use the held projector, projected %v vectors.", spy.projected);
+  }
+};
+
+func TestSimpleInitialCentroids(t *testing.T) {
+  var dimension = 16;
+  point := func(value float64) []float64 {
+    vec := make([]float64, dimension);
+    for i := range vec {
+      vec[i] = value;
+    }
+    return vec;
+  };
+  var data [][]float64;
+  for _, center := range [][]float64{point(5), point(-5)} {
+    data = append(data, generateBlobs([][]float64{center}, 200, 0.1, 0)...);
+  }
+  simpleObject := simple.NewSimple(reader.NewSimpleArray(data, 2));
+  if err := simpleObject.SetInitialCentroids([][]float64{point(0)}); err == nil {
+    t.Error("Initial centroids should be rejected unless there are K of them.");
+  }
+  if err := simpleObject.SetInitialCentroids([][]float64{{0, 0}, {0, 0}}); err == nil {
+    t.Error("Initial centroids should be rejected unless they have the data's dimension.");
+  }
+
+  // Yesterday's centroids, in the opposite order to the data.
+  prior := [][]float64{point(-4), point(4)};
+  if err := simpleObject.SetInitialCentroids(prior); err != nil {
+    t.Fatalf("Unexpected error setting initial centroids: %v", err);
+  }
+  result := simpleObject.GetCentroids();
+  if len(result) != len(prior) {
+    t.Fatalf("Expected %v centroids, Actual %v.", len(prior), result);
+  }
+  for i := range prior {
+    if nearest := utils.FindNearestDistance(result[i], prior); nearest != i {
+      t.Errorf("Centroid %v %v should continue initial centroid %v, it is nearest %v.", i, result[i], prior[i], prior[nearest]);
+    }
+  }
+};
+
+func TestSimpleApproxCentroids(t *testing.T) {
+  var dimension = 16;
+  var centers [][]float64;
+  for _, value := range []float64{-10, 0, 10} {
+    center := make([]float64, dimension);
+    for i := range center {
+      center[i] = value;
+    }
+    centers = append(centers, center);
+  }
+  RPHashObject := reader.NewSimpleArray(generateBlobs(centers, 100, 0.01, 0), 3);
+  simpleObject := simple.NewSimple(RPHashObject);
+
+  approx := simpleObject.ApproxCentroids();
+  if len(approx) == 0 || len(approx) > len(centers) {
+    t.Fatalf("Expected between 1 and %v approximate centroids, Actual %v.", len(centers), len(approx));
+  }
+  for _, centroid := range approx {
+    nearest := centers[utils.FindNearestDistance(centroid, centers)];
+    if distance := utils.Distance(centroid, nearest); distance > 1 {
+      t.Errorf("Approximate centroid %v is %v from the nearest center.", centroid, distance);
+    }
+  }
+  if len(RPHashObject.GetCentroids()) != 0 {
+    t.Error("Approximate centroids should not run Reduce.");
+  }
+};
+
+func TestSimpleRefineInProjectedSpace(t *testing.T) {
+  data := generator.NewGenerator(0).GenerateData(200, 20);
+  simpleObject := simple.NewSimple(reader.NewSimpleArray(data, 2));
+  if simpleObject.GetRefineInProjectedSpace() {
+    t.Error("Refinement should be in the data's space by default.");
+  }
+  simpleObject.SetRefineInProjectedSpace(true);
+  centroids := simpleObject.GetCentroids();
+  if len(centroids) != 2 {
+    t.Fatalf("Expected 2 centroids, Actual %v.", len(centroids));
+  }
+  for _, centroid := range centroids {
+    if len(centroid) != 20 {
+      t.Errorf("Centroids refined in projected space should still have the data's dimension, Actual %v.", len(centroid));
+    }
+  }
+};
+
+func TestSimpleCandidateMultiplier(t *testing.T) {
+  data := generator.NewGenerator(0).GenerateData(1000, 20);
+  RPHashObject := reader.NewSimpleArray(data, 3);
+  simpleObject := simple.NewSimple(RPHashObject);
+  if simpleObject.GetCandidateMultiplier() != 1 {
+    t.Errorf("The multiplier should default to 1, Actual %v.", simpleObject.GetCandidateMultiplier());
+  }
+
+  // 4 * 3 ln 3 = 13.2
+  simpleObject.SetCandidateMultiplier(4);
+  simpleObject.Map();
+  if candidates := len(RPHashObject.GetPreviousTopID()); candidates != 13 {
+    t.Errorf("Expected 13 candidate buckets, Actual %v.", candidates);
+  }
+  simpleObject.Reduce();
+  if centroids := len(RPHashObject.GetCentroids()); centroids != 3 {
+    t.Errorf("Reduce should narrow the candidates to K 3, Actual %v.", centroids);
+  }
+
+  simpleObject.SetCandidateMultiplier(0.1);
+  simpleObject.Map();
+  if candidates := len(RPHashObject.GetPreviousTopID()); candidates != 3 {
+    t.Errorf("At least K candidates should be kept, Actual %v.", candidates);
+  }
+};
+
+// An RPHash object that can be cleared for another run.
+type resettableObject interface {
+  types.RPHashObject;
+  Reset();
+};
+
+func TestSimpleResetBetweenRuns(t *testing.T) {
+  data := generator.NewGenerator(0).GenerateData(200, 10);
+  dir, err := ioutil.TempDir("", "rphash");
+  if err != nil {
+    t.Fatal(err);
+  }
+  defer os.RemoveAll(dir);
+  path := writeTestFile(t, dir, "data.csv", csvRows(data), false);
+  stream := func(vecs types.Iterator) resettableObject {
+    object := reader.NewStreamObject(10, 3);
+    object.SetVectorIterator(vecs);
+    object.SetDecoderType(defaults.NewDecoder(5, 6, 1));
+    return object;
+  };
+  sources := map[string]func() resettableObject{
+    "simple array": func() resettableObject {
+      return reader.NewSimpleArray(data, 3);
+    },
+    "stream": func() resettableObject {
+      return stream(utils.NewIterator(data));
+    },
+    "file": func() resettableObject {
+      iterator, err := reader.NewCSVIterator(path);
+      if err != nil {
+        t.Fatal(err);
+      }
+      return stream(iterator);
+    },
+  };
+
+  for name, source := range sources {
+    RPHashObject := source();
+    RPHashObject.SetRandomSeed(1);
+    simple.NewSimple(RPHashObject).Run();
+    simple.NewSimple(RPHashObject).Run();
+    if len(RPHashObject.GetCentroids()) != 6 {
+      t.Fatalf("%v: without a reset a second run should add to the centroids, Actual %v.", name, len(RPHashObject.GetCentroids()));
+    }
+
+    RPHashObject.Reset();
+    if err := simple.NewSimple(RPHashObject).Run(); err != nil {
+      t.Fatalf("%v: unexpected error after Reset: %v", name, err);
+    }
+    fresh := source();
+    fresh.SetRandomSeed(1);
+    simple.NewSimple(fresh).Run();
+    if !reflect.DeepEqual(RPHashObject.GetCentroids(), fresh.GetCentroids()) {
+      t.Errorf("%v: a run after Reset should match a fresh object. Expected %v, Actual %v.", name, fresh.GetCentroids(), RPHashObject.GetCentroids());
+    }
+    if !reflect.DeepEqual(RPHashObject.GetPreviousTopID(), fresh.GetPreviousTopID()) {
+      t.Errorf("%v: a run after Reset should leave the top ids of a fresh object. Expected %v, Actual %v.", name, fresh.GetPreviousTopID(), RPHashObject.GetPreviousTopID());
+    }
+    // Every run leaves the iterator rewound, so the next pass reads the
+    // same vectors in the same order.
+    if vecs := readAll(RPHashObject.GetVectorIterator()); !reflect.DeepEqual(vecs, data) {
+      t.Errorf("%v: a pass after the runs should read the data again in order, read %d of %d vectors.", name, len(vecs), len(data));
+    }
+    for _, object := range []resettableObject{RPHashObject, fresh} {
+      if closable, ok := object.GetVectorIterator().(types.ClosableIterator); ok {
+        closable.Close();
+      }
+    }
+  }
+};
+
+func TestSimpleClassifier(t *testing.T) {
+  data := generator.NewGenerator(0).GenerateData(300, 10);
+  RPHashObject := reader.NewSimpleArray(data, 3);
+  RPHashSimple := simple.NewSimple(RPHashObject);
+  model := RPHashSimple.GetClassifier();
+  if len(model.GetCentroids()) != 3 {
+    t.Fatalf("Expected a classifier over 3 centroids, Actual %v.", len(model.GetCentroids()));
+  }
+  var observed int64;
+  for _, stats := range model.GetClusterStats() {
+    observed += stats.Count;
+  }
+  if observed != int64(len(data)) {
+    t.Errorf("Every vector should be observed once, Expected %v, Actual %v.", len(data), observed);
+  }
+  far := make([]float64, 10);
+  for i := range far {
+    far[i] = 1e3;
+  }
+  if score := model.AnomalyScore(far); score < 3 {
+    t.Errorf("A vector far from the data should score as an anomaly, Actual %v.", score);
+  }
+};
+
+// Records every message it is given.
+type recordingLogger struct {
+  messages []string;
+};
+
+func (this *recordingLogger) Debugf(format string, args ...interface{}) {
+  this.messages = append(this.messages, fmt.Sprintf(format, args...));
+};
+
+func (this *recordingLogger) Infof(format string, args ...interface{}) {
+  this.messages = append(this.messages, fmt.Sprintf(format, args...));
+};
+
+func (this *recordingLogger) Warnf(format string, args ...interface{}) {
+  this.messages = append(this.messages, fmt.Sprintf(format, args...));
+};
+
+func (this *recordingLogger) contains(prefix string) bool {
+  for _, message := range this.messages {
+    if strings.HasPrefix(message, prefix) {
+      return true;
+    }
+  }
+  return false;
+};
+
+func TestSimpleLogger(t *testing.T) {
+  data := generator.NewGenerator(0).GenerateData(300, 10);
+  logger := &recordingLogger{};
+  RPHashSimple := simple.NewSimple(reader.NewSimpleArray(data, 3));
+  RPHashSimple.SetLogger(logger);
+  RPHashSimple.SetMinClusterSize(len(data));
+  RPHashSimple.GetCentroids();
+  for _, event := range []string{"simple: map phase", "simple: reduce phase", "itemset: count-min sketch saturated",
+    "projector: projecting", "simple: refined", "simple: dropped"} {
+    if !logger.contains(event) {
+      t.Errorf("Expected a %q event, Actual %q.", event, logger.messages);
+    }
+  }
+
+  RPHashSimple.SetLogger(nil);
+  if RPHashSimple.GetLogger() == nil {
+    t.Error("Setting a nil logger should fall back to the silent default.");
+  }
+};
+
+func TestSimpleLoggerWarnings(t *testing.T) {
+  data := generator.NewGenerator(0).GenerateData(100, 6);
+  logger := &recordingLogger{};
+  stream := reader.NewStreamObject(6, 3);
+  stream.SetVectorIterator(utils.NewIterator(data));
+  // The default decoder hashes in more dimensions than the data has, so the
+  // projector Simple builds does not reduce the dimension.
+  RPHashSimple := simple.NewSimple(stream);
+  RPHashSimple.SetLogger(logger);
+  stream.SetNumberOfBlurs(0);
+  stream.AddCentroid([]float64{1, 2});
+  RPHashSimple.Run();
+  for _, warning := range []string{"reader: number of blurs must be at least 1", "reader: centroid has 2 dimensions",
+    "projector: target dimension"} {
+    if !logger.contains(warning) {
+      t.Errorf("Expected the warning %q to reach the Simple's logger, Actual %q.", warning, logger.messages);
+    }
+  }
+};
+
+func TestSimpleReduceConcurrency(t *testing.T) {
+  data := generator.NewGenerator(0).GenerateData(9000, 10);
+  RPHashObject := reader.NewSimpleArray(data, 4);
+  RPHashSimple := simple.NewSimple(RPHashObject);
+  RPHashSimple.Map();
+  // Every run reduces the same buckets, so only the worker count differs.
+  top := RPHashObject.GetPreviousTopID();
+
+  var expectedCentroids [][]float64;
+  var expectedSizes []int64;
+  for _, workers := range []int{1, 2, 3, 8} {
+    RPHashObject.Reset();
+    RPHashObject.SetPreviousTopID(top);
+    RPHashSimple.SetConcurrency(workers);
+    RPHashSimple.Reduce();
+    centroids, sizes := RPHashObject.GetCentroids(), RPHashSimple.GetClusterSizes();
+    if expectedCentroids == nil {
+      expectedCentroids, expectedSizes = centroids, sizes;
+      continue;
+    }
+    if !reflect.DeepEqual(sizes, expectedSizes) {
+      t.Errorf("%v workers: expected the cluster sizes %v, Actual %v.", workers, expectedSizes, sizes);
+    }
+    for i := range centroids {
+      for d := range centroids[i] {
+        if math.Abs(centroids[i][d] - expectedCentroids[i][d]) > 1e-9 {
+          t.Errorf("%v workers: centroid %v differs at %v, expected %v, Actual %v.", workers, i, d, expectedCentroids[i][d], centroids[i][d]);
+        }
+      }
+    }
+  }
+};
+
+func TestSimpleAssign(t *testing.T) {
+  random := rand.New(rand.NewSource(2));
+  centers := [][]float64{{0, 0, 0, 0}, {10, 10, 0, 0}, {0, 0, 10, 10}};
+  var data [][]float64;
+  for i := 0; i < 300; i++ {
+    center := centers[i % len(centers)];
+    vec := make([]float64, len(center));
+    for j := range vec {
+      vec[j] = center[j] + random.NormFloat64() * 0.5;
+    }
+    data = append(data, vec);
+  }
+  RPHashObject := reader.NewSimpleArray(data, 3);
+  RPHashObject.SetRandomSeed(2);
+  RPHashSimple := simple.NewSimple(RPHashObject);
+  centroids := RPHashSimple.GetCentroids();
+  if len(centroids) != len(centers) {
+    t.Fatalf("Expected %d centroids, Actual %v.", len(centers), centroids);
+  }
+  for i, centroid := range centroids {
+    near := make([]float64, len(centroid));
+    for j := range near {
+      near[j] = centroid[j] + random.NormFloat64() * 0.1;
+    }
+    if assigned := RPHashSimple.Assign(near); assigned != i {
+      t.Errorf("A point near centroid %d should be assigned to it, Actual %d.", i, assigned);
+    }
+  }
+  // Points from one blob share a centroid that no other blob's points use.
+  assignments := RPHashSimple.AssignAll(data);
+  for i, assigned := range assignments {
+    if assigned != assignments[i % len(centers)] {
+      t.Fatalf("Vector %d was assigned %d, but its blob went to %d.", i, assigned, assignments[i % len(centers)]);
+    }
+  }
+  if assignments[0] == assignments[1] || assignments[1] == assignments[2] || assignments[0] == assignments[2] {
+    t.Errorf("Each blob should get its own centroid, Actual %v.", assignments[:3]);
+  }
+
+  // A short vector toward the second blob lies nearest the first blob's
+  // centroid, but shares the second's direction.
+  short := []float64{0.3, 0.3, 0, 0};
+  if assigned := RPHashSimple.Assign(short); assigned != assignments[0] {
+    t.Errorf("By Euclidean distance %v should go to centroid %d, Actual %d.", short, assignments[0], assigned);
+  }
+  RPHashSimple.SetDistance(defaults.Cosine);
+  if assigned := RPHashSimple.Assign(short); assigned != assignments[1] {
+    t.Errorf("By cosine distance %v should go to centroid %d, Actual %d.", short, assignments[1], assigned);
+  }
+  model := RPHashSimple.GetClassifier();
+  model.BuildIndex();
+  if predicted := model.Predict(short); predicted != RPHashSimple.Assign(short) {
+    t.Errorf("The classifier should predict by the Simple's distance, Expected %d, Actual %d.", RPHashSimple.Assign(short), predicted);
+  }
+  RPHashSimple.SetDistance(nil);
+
+  if assigned := RPHashSimple.Assign([]float64{1, 2}); assigned != -1 {
+    t.Errorf("A vector of the wrong dimension should be assigned -1, Actual %d.", assigned);
+  }
+
+  empty := reader.NewStreamObject(4, 3);
+  empty.SetVectorIterator(utils.NewIterator(nil));
+  if assigned := simple.NewSimple(empty).AssignAll(data[:2]); !reflect.DeepEqual(assigned, []int{-1, -1}) {
+    t.Errorf("Without centroids every vector should be assigned -1, Actual %v.", assigned);
+  }
+};
+
+func TestSimpleCachesRefinedCentroids(t *testing.T) {
+  data := generateBlobs([][]float64{{30, 0, 0, 0, 0, 0}, {0, 30, 0, 0, 0, 0}, {0, 0, 30, 0, 0, 0}}, 200, 1, 0);
+  RPHashObject := reader.NewSimpleArray(data, 3);
+  RPHashObject.SetRandomSeed(1);
+  simpleObject := simple.NewSimple(RPHashObject);
+  logger := &recordingLogger{};
+  simpleObject.SetLogger(logger);
+  refinements := func() int {
+    count := 0;
+    for _, message := range logger.messages {
+      if strings.HasPrefix(message, "simple: refined") {
+        count++;
+      }
+    }
+    return count;
+  };
+
+  centroids := simpleObject.GetCentroids();
+  simpleObject.GetCentroids();
+  simpleObject.Assign(data[0]);
+  simpleObject.AssignAll(data);
+  if refinements() != 1 {
+    t.Errorf("Expected the centroids to be refined once across GetCentroids and Assign, Actual %d times.", refinements());
+  }
+
+  simpleObject.SetKMeansIterations(5);
+  if again := simpleObject.GetCentroids(); refinements() != 2 || len(again) != len(centroids) {
+    t.Errorf("A changed refinement setting should refine again, refined %d times.", refinements());
+  }
+  simpleObject.Run();
+  simpleObject.GetCentroids();
+  if refinements() != 3 {
+    t.Errorf("A new run should refine again, refined %d times.", refinements());
+  }
+};
+
+func TestSimpleMapParallel(t *testing.T) {
+  // 9000 vectors span several of the blocks Map reads at a time.
+  for _, size := range []int{300, 2000, 9000} {
+    data := generator.NewGenerator(0).GenerateData(size, 10);
+    serialObject := reader.NewSimpleArray(data, 4);
+    serialObject.SetRandomSeed(3);
+    serial := simple.NewSimple(serialObject);
+    serial.Map();
+    expected := serialObject.GetPreviousTopID();
+    // The data must hash into more buckets than the sketches keep, so that
+    // every sketch evicts.
+    hashes := make(map[int64]bool);
+    for vecs := serialObject.GetVectorIterator(); vecs.HasNext(); {
+      vecs.Next();
+      hashes[vecs.PeakLSH()] = true;
+    }
+    serialObject.GetVectorIterator().Reset();
+    if len(hashes) <= len(expected) * 8 {
+      t.Fatalf("Expected the %d vectors to fill more buckets than the sketches keep, Actual %d.", size, len(hashes));
+    }
+    for _, workers := range []int{1, 3, 8} {
+      parallelObject := reader.NewSimpleArray(data, 4);
+      parallelObject.SetRandomSeed(3);
+      parallel := simple.NewSimple(parallelObject);
+      parallel.MapParallel(workers);
+      // The merged table is the one Map counted, so the K heaviest buckets,
+      // the ones Reduce uses, are the same and in the same order.
+      top := parallelObject.GetPreviousTopID();
+      if len(top) != len(expected) || !reflect.DeepEqual(top[len(top) - 4:], expected[len(expected) - 4:]) {
+        t.Errorf("MapParallel with %d workers over %d vectors should find the heaviest buckets of Map, Expected %v, Actual %v.", workers, size, expected, top);
+      }
+      // Bucket means are held per worker, so fewer heavy buckets are lost.
+      if approx := parallel.ApproxCentroids(); len(approx) < len(serial.ApproxCentroids()) {
+        t.Errorf("Expected at least %d approximate centroids with %d workers over %d, Actual %d.", len(serial.ApproxCentroids()), workers, size, len(approx));
+      }
+    }
+  }
+};
+
+func BenchmarkSimpleMap(b *testing.B) {
+  benchmarkSimpleMap(b, 0);
+};
+
+func BenchmarkSimpleMapParallel(b *testing.B) {
+  benchmarkSimpleMap(b, runtime.NumCPU());
+};
+
+func benchmarkSimpleMap(b *testing.B, workers int) {
+  data := generator.NewGenerator(0).GenerateData(300000, 10);
+  RPHashObject := reader.NewSimpleArray(data, 4);
+  RPHashObject.SetRandomSeed(1);
+  RPHashSimple := simple.NewSimple(RPHashObject);
+  b.ResetTimer();
+  for i := 0; i < b.N; i++ {
+    if workers == 0 {
+      RPHashSimple.Map();
+    } else {
+      RPHashSimple.MapParallel(workers);
+    }
+  }
+};
@@ -1,12 +1,14 @@
 package tests;
 
 import (
+  "errors"
   "math"
   "math/rand"
   "reflect"
   "testing"
   "github.com/stretchr/testify/assert"
   "github.com/wenkesj/rphash/reader"
+  "github.com/wenkesj/rphash/simple"
   "github.com/wenkesj/rphash/types"
   "github.com/wenkesj/rphash/utils"
 );
@@ -89,3 +91,47 @@ func TestSimpleArray(t *testing.T) {
   RPHashObject.SetPreviousTopID(newTopId);
   assert.Equal(t, newTopId, RPHashObject.GetPreviousTopID(), "Previous top ID should be equal to the new top centroid.");
 }
+
+func TestWeightedSliceIterator(t *testing.T) {
+  data := [][]float64{{1}, {2}, {3}};
+  iterator := reader.NewWeightedSliceIterator(data, []float64{0.5, 2});
+  var weighted types.WeightedIterator = iterator;
+  expected := []float64{0.5, 2, 1};
+  for i := 0; weighted.HasNext(); i++ {
+    vec := weighted.Next();
+    assert.Equal(t, data[i], vec, "Vectors should come back in order.");
+    assert.Equal(t, expected[i], weighted.Weight(), "Weights should follow their vectors, defaulting to 1.");
+  }
+  weighted.Reset();
+  weighted.Next();
+  assert.Equal(t, 0.5, weighted.Weight(), "Reset should rewind the weights with the vectors.");
+
+  RPHashObject := reader.NewWeightedSimpleArray(data, []float64{0.5, 2}, 1);
+  _, ok := RPHashObject.GetVectorIterator().(types.WeightedIterator);
+  assert.True(t, ok, "A weighted simple array should iterate with weights.");
+}
+
+func TestSimpleArrayAddCentroidChecked(t *testing.T) {
+  RPHashObject := reader.NewSimpleArray([][]float64{{1, 2, 3}, {4, 5, 6}}, 2);
+  assert.Nil(t, RPHashObject.AddCentroidChecked([]float64{1, 2, 3}), "A centroid of the data's dimension should be added.");
+  assert.NotNil(t, RPHashObject.AddCentroidChecked([]float64{1, 2}), "A centroid of another dimension should be refused.");
+  assert.Equal(t, [][]float64{{1, 2, 3}}, RPHashObject.GetCentroids(), "A refused centroid should not be added.");
+}
+
+// A SimpleArray that refuses every centroid, as one wired to the wrong
+// dimension would.
+type refusingArray struct {
+  *reader.SimpleArray;
+}
+
+func (this *refusingArray) AddCentroidChecked(v []float64) error {
+  return errors.New("refused");
+}
+
+func TestSimpleRunAddCentroidError(t *testing.T) {
+  data := [][]float64{{1, 2, 3, 4}, {2, 3, 4, 5}, {9, 8, 7, 6}};
+  RPHashObject := &refusingArray{reader.NewSimpleArray(data, 2)};
+  if err := simple.NewSimple(RPHashObject).Run(); err == nil || err.Error() != "refused" {
+    t.Errorf("Run should return the error of a refused centroid, Actual %v.", err);
+  }
+}
@@ -1,12 +1,18 @@
 package tests;
 
 import (
+  "io/ioutil"
   "math"
   "math/rand"
+  "os"
   "reflect"
+  "strings"
   "testing"
   "github.com/stretchr/testify/assert"
+  "github.com/wenkesj/rphash/defaults"
   "github.com/wenkesj/rphash/reader"
+  "github.com/wenkesj/rphash/simple"
+  "github.com/wenkesj/rphash/stream"
   "github.com/wenkesj/rphash/types"
   "github.com/wenkesj/rphash/utils"
 );
@@ -74,10 +80,14 @@ func TestStreamObject(t *testing.T) {
   assert.Equal(t, newNumProjections, RPHashObject.GetNumberOfProjections(), "Number of projections should be equal to the new number of projections.");
 
   // Hash modulus.
-  assert.Equal(t, int64(math.MaxInt64), RPHashObject.GetHashModulus(), "Hash modulus should be equal to the maximum 32 bit integer value.");
+  assert.Equal(t, int64(math.MaxInt32), RPHashObject.GetHashModulus(), "Hash modulus should be equal to the maximum 32 bit integer value.");
   RPHashObject.SetHashModulus(newHashModulus);
   assert.Equal(t, newHashModulus, RPHashObject.GetHashModulus(), "Hash modulus should be equal to the new hash modulus.");
 
+  // Random seed.
+  RPHashObject.SetRandomSeed(newRandomSeed);
+  assert.Equal(t, newRandomSeed, RPHashObject.GetRandomSeed(), "Random seed should be equal to the new random seed.");
+
   // Centroids.
   assert.Empty(t, RPHashObject.GetCentroids(), "Centroids should initially be empty.");
   RPHashObject.AddCentroid(newCentroid);
@@ -90,3 +100,230 @@ func TestStreamObject(t *testing.T) {
   RPHashObject.SetPreviousTopID(newTopId);
   assert.Equal(t, newTopId, RPHashObject.GetPreviousTopID(), "Previous top ID should be equal to the new top centroid.");
 }
+
+func TestStreamObjectProjectionsAndBlurs(t *testing.T) {
+  // The defaults are kept for data narrower than the decoder.
+  RPHashObject := reader.NewStreamObject(4, 2);
+  assert.Equal(t, 2, RPHashObject.GetNumberOfProjections(), "The default projections should be kept for narrow data.");
+  assert.Equal(t, 2, RPHashObject.GetNumberOfBlurs(), "The default blurs should be kept for narrow data.");
+
+  // Counts beyond the decoder's dimensionality are kept.
+  d := RPHashObject.GetDecoderType().GetDimensionality();
+  assert.Nil(t, RPHashObject.SetNumberOfProjectionsChecked(10), "Any positive number of projections should be accepted.");
+  assert.Equal(t, 10, RPHashObject.GetNumberOfProjections(), "Projections should be kept as set.");
+  assert.Nil(t, RPHashObject.SetNumberOfBlursChecked(4 * d), "Any positive number of blurs should be accepted.");
+  assert.Equal(t, 4 * d, RPHashObject.GetNumberOfBlurs(), "Blurs should be kept as set.");
+
+  // Counts below one are refused and the previous count kept.
+  assert.NotNil(t, RPHashObject.SetNumberOfProjectionsChecked(0), "Projections below one should be refused.");
+  assert.Equal(t, 10, RPHashObject.GetNumberOfProjections(), "A refused count should leave the projections unchanged.");
+  assert.NotNil(t, RPHashObject.SetNumberOfBlursChecked(-1), "Blurs below one should be refused.");
+  RPHashObject.SetNumberOfBlurs(0);
+  assert.Equal(t, 4 * d, RPHashObject.GetNumberOfBlurs(), "A refused count should leave the blurs unchanged.");
+}
+
+func TestStreamObjectVarianceHistory(t *testing.T) {
+  RPHashObject := reader.NewStreamObject(2, 1);
+  RPHashObject.SetVarianceSampleInterval(10);
+  RPHashObject.RecordVariance(1, 0.5);
+  RPHashObject.RecordVariance(5, 0.6);
+  RPHashObject.RecordVariance(11, 0.7);
+  expected := []reader.VarianceSample{
+    {VectorsProcessed: 1, Variance: 0.5},
+    {VectorsProcessed: 11, Variance: 0.7},
+  };
+  if history := RPHashObject.GetVarianceHistory(); !reflect.DeepEqual(history, expected) {
+    t.Errorf("Expected samples %v, Actual %v.", expected, history);
+  }
+
+  var dimensionality = 100;
+  RPHashObject = reader.NewStreamObject(dimensionality, 2);
+  RPHashObject.SetVarianceSampleInterval(100);
+  stream := stream.NewStream(RPHashObject);
+  random := rand.New(rand.NewSource(0));
+  for i := 0; i < 1000; i++ {
+    vec := make([]float64, dimensionality);
+    for j := range vec {
+      vec[j] = random.NormFloat64();
+    }
+    stream.AddVectorOnlineStep(vec);
+  }
+  history := RPHashObject.GetVarianceHistory();
+  if len(history) != 10 {
+    t.Fatalf("Expected 10 samples over 1000 vectors, Actual %v.", len(history));
+  }
+  for i, sample := range history {
+    if sample.VectorsProcessed != int64(i * 100 + 1) {
+      t.Errorf("Sample %v should be taken after %v vectors, Actual %v.", i, i * 100 + 1, sample.VectorsProcessed);
+    }
+  }
+  if last := history[len(history) - 1].Variance; last <= 0 {
+    t.Errorf("The recorded variance should be positive, Actual %v.", last);
+  }
+};
+
+func TestStreamObjectAddCentroidDimension(t *testing.T) {
+  RPHashObject := reader.NewStreamObject(3, 2);
+  RPHashObject.AddCentroid([]float64{1, 2, 3});
+  RPHashObject.AddCentroid([]float64{1, 2});
+  RPHashObject.AddCentroid([]float64{1, 2, 3, 4});
+  if centroids := RPHashObject.GetCentroids(); len(centroids) != 1 {
+    t.Errorf("Centroids of the wrong dimension should be refused, Actual %v.", centroids);
+  }
+  if err := RPHashObject.AddCentroidChecked([]float64{1, 2}); err == nil {
+    t.Error("AddCentroidChecked should return an error for a centroid of the wrong dimension.");
+  }
+
+  // Without a known dimension the first centroid sets it.
+  RPHashObject = reader.NewStreamObject(0, 2);
+  RPHashObject.AddCentroid([]float64{1, 2});
+  RPHashObject.AddCentroid([]float64{1, 2, 3});
+  RPHashObject.AddCentroid([]float64{3, 4});
+  if centroids := RPHashObject.GetCentroids(); !reflect.DeepEqual(centroids, [][]float64{{1, 2}, {3, 4}}) {
+    t.Errorf("Expected [[1 2] [3 4]], Actual %v.", centroids);
+  }
+};
+
+func TestStreamObjectAngularVariance(t *testing.T) {
+  data := [][]float64{{3, 4}, {6, 8}};
+  RPHashObject := reader.NewStreamObject(2, 1);
+  assert.False(t, RPHashObject.GetAngularVariance(), "Angular variance should be off by default.");
+  RPHashObject.SetAngularVariance(true);
+  RPHashObject.SetVariance(data);
+  // One sampled row, (0.6, 0.8) at unit length.
+  assert.InDelta(t, 0.02, RPHashObject.GetVariance(), 1e-12, "Variance should be estimated over unit length rows.");
+};
+
+func TestStreamObjectReset(t *testing.T) {
+  RPHashObject := reader.NewStreamObject(4, 2);
+  RPHashObject.SetRandomSeed(7);
+  decoder := RPHashObject.GetDecoderType();
+  RPHashObject.AddCentroid([]float64{1, 2, 3, 4});
+  RPHashObject.SetPreviousTopID([]int64{5, 6});
+  RPHashObject.RecordVariance(1, 0.5);
+
+  RPHashObject.Reset();
+  RPHashObject.SetK(3);
+  fresh := reader.NewStreamObject(4, 3);
+  assert.Empty(t, RPHashObject.GetCentroids(), "Reset should clear the centroids.");
+  assert.Empty(t, RPHashObject.GetPreviousTopID(), "Reset should clear the top ids.");
+  assert.Empty(t, RPHashObject.GetVarianceHistory(), "Reset should clear the variance history.");
+  assert.Equal(t, fresh.GetCentroids(), RPHashObject.GetCentroids(), "Centroids should match a fresh object.");
+  assert.Equal(t, fresh.GetPreviousTopID(), RPHashObject.GetPreviousTopID(), "Top ids should match a fresh object.");
+  assert.Equal(t, 3, RPHashObject.GetK(), "K should be changed by SetK.");
+  assert.Equal(t, 4, RPHashObject.GetDimensions(), "Reset should keep the dimension.");
+  assert.Equal(t, int64(7), RPHashObject.GetRandomSeed(), "Reset should keep the seed.");
+  assert.Equal(t, decoder, RPHashObject.GetDecoderType(), "Reset should keep the decoder.");
+};
+
+func TestStreamObjectNumDataPoints(t *testing.T) {
+  data := [][]float64{{1, 2}, {3, 4}, {5, 6}, {7, 8}, {9, 10}};
+  RPHashObject := reader.NewStreamObject(2, 1);
+  assert.Nil(t, RPHashObject.GetVectorIterator(), "No iterator should be set by default.");
+  assert.Equal(t, 0, RPHashObject.NumDataPoints(), "Nothing has been streamed yet.");
+
+  RPHashObject.SetVectorIterator(utils.NewIterator(data));
+  vecs := RPHashObject.GetVectorIterator();
+  vecs.Next();
+  vecs.Next();
+  assert.Equal(t, 2, RPHashObject.NumDataPoints(), "The count should follow the vectors read.");
+  for vecs.HasNext() {
+    vecs.Next();
+  }
+  assert.Equal(t, len(data), RPHashObject.NumDataPoints(), "A full pass should count every vector.");
+  vecs.Reset();
+  for vecs.HasNext() {
+    vecs.Next();
+  }
+  assert.Equal(t, len(data), RPHashObject.NumDataPoints(), "A second pass should not count the vectors again.");
+
+  weights := []float64{1, 2, 3, 4, 5};
+  RPHashObject.SetVectorIterator(reader.NewWeightedSliceIterator(data, weights));
+  vecs = RPHashObject.GetVectorIterator();
+  vecs.Next();
+  vecs.Next();
+  weighted, ok := vecs.(types.WeightedIterator);
+  assert.True(t, ok, "The counted iterator should still be weighted.");
+  assert.Equal(t, 2.0, weighted.Weight(), "Weights should pass through the count.");
+  assert.Equal(t, 2, RPHashObject.NumDataPoints(), "A new iterator should start a new count.");
+};
+
+func TestSimpleRunStreamObject(t *testing.T) {
+  centers := [][]float64{{30, 0, 0, 0, 0, 0}, {0, 30, 0, 0, 0, 0}, {0, 0, 30, 0, 0, 0}};
+  data := generateBlobs(centers, 100, 1, 0);
+  streamed := reader.NewStreamObject(6, 3);
+  streamed.SetVectorIterator(utils.NewIterator(data));
+  streamed.SetRandomSeed(1);
+  streamed.SetDecoderType(defaults.NewDecoder(3, 6, 1));
+  streamSimple := simple.NewSimple(streamed);
+  if err := streamSimple.Run(); err != nil {
+    t.Fatalf("Unexpected error running over a stream: %v", err);
+  }
+  assert.Equal(t, len(data), streamed.NumDataPoints(), "A run should stream every vector.");
+
+  // The same data held in memory clusters the same way.
+  held := reader.NewSimpleArray(data, 3);
+  held.SetRandomSeed(1);
+  held.SetDecoderType(defaults.NewDecoder(3, 6, 1));
+  held.SetHashModulus(streamed.GetHashModulus());
+  expected := simple.NewSimple(held).GetCentroids();
+  actual := streamSimple.GetCentroids();
+  assert.Equal(t, 3, len(actual), "A stream should give K centroids.");
+  assert.Equal(t, expected, actual, "A stream should give the centroids of the same data held in memory.");
+};
+
+func TestSimpleRunStreamObjectError(t *testing.T) {
+  dir, err := ioutil.TempDir("", "rphash");
+  if err != nil {
+    t.Fatal(err);
+  }
+  defer os.RemoveAll(dir);
+  iterator, err := reader.NewCSVIterator(writeTestFile(t, dir, "truncated.csv", "1,2\n3,4\n5,6\n7,x\n", false));
+  if err != nil {
+    t.Fatal(err);
+  }
+  defer iterator.Close();
+  RPHashObject := reader.NewStreamObject(2, 1);
+  RPHashObject.SetVectorIterator(iterator);
+  failing, ok := RPHashObject.GetVectorIterator().(interface{ Err() error });
+  assert.True(t, ok, "The counted iterator should report the file's errors.");
+  for RPHashObject.GetVectorIterator().HasNext() {
+    RPHashObject.GetVectorIterator().Next();
+  }
+  assert.NotNil(t, failing.Err(), "A bad row should be reported through the counted iterator.");
+  RPHashObject.GetVectorIterator().Reset();
+
+  if err := simple.NewSimple(RPHashObject).Run(); err == nil || !strings.Contains(err.Error(), "line 4") {
+    t.Errorf("Run should report the bad row, Actual %v.", err);
+  }
+};
+
+func TestSimpleMapStreamObjectError(t *testing.T) {
+  dir, err := ioutil.TempDir("", "rphash");
+  if err != nil {
+    t.Fatal(err);
+  }
+  defer os.RemoveAll(dir);
+  path := writeTestFile(t, dir, "truncated.csv", "1,2\n3,4\n5,6\n7,x\n", false);
+  for _, workers := range []int{0, 2} {
+    iterator, err := reader.NewCSVIterator(path);
+    if err != nil {
+      t.Fatal(err);
+    }
+    RPHashObject := reader.NewStreamObject(2, 1);
+    RPHashObject.SetVectorIterator(iterator);
+    simpleObject := simple.NewSimple(RPHashObject);
+    if workers == 0 {
+      simpleObject.Map();
+    } else {
+      simpleObject.MapParallel(workers);
+    }
+    if err := simpleObject.Err(); err == nil || !strings.Contains(err.Error(), "line 4") {
+      t.Errorf("Map with %d workers should keep the bad row's error, Actual %v.", workers, err);
+    }
+    if err := simpleObject.Reduce().Err(); err == nil || !strings.Contains(err.Error(), "line 4") {
+      t.Errorf("Reduce should keep the bad row's error, Actual %v.", err);
+    }
+    iterator.Close();
+  }
+};
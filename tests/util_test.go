@@ -1,9 +1,13 @@
 package tests;
 
 import (
+  "github.com/wenkesj/rphash/defaults"
   "github.com/wenkesj/rphash/utils"
   "github.com/wenkesj/rphash/itemset"
   "github.com/wenkesj/rphash/types"
+  "math"
+  "math/rand"
+  "reflect"
   "testing"
 );
 
@@ -112,3 +116,141 @@ func TestPriorityQueueRemove(t *testing.T) {
       }
     }
 }
+
+func TestReservoirFillsThenHoldsSize(t *testing.T) {
+  reservoir := utils.NewReservoirWithSeed(5, 0);
+  for i := 0; i < 3; i++ {
+    reservoir.Push([]float64{float64(i)});
+  }
+  if len(reservoir.Sample()) != 3 {
+    t.Errorf("A reservoir should hold everything until it is full. Expected 3, Actual %v.", len(reservoir.Sample()));
+  }
+  for i := 3; i < 100; i++ {
+    reservoir.Push([]float64{float64(i)});
+  }
+  if len(reservoir.Sample()) != 5 {
+    t.Errorf("A full reservoir should hold exactly its size. Expected 5, Actual %v.", len(reservoir.Sample()));
+  }
+  if reservoir.Count() != 100 {
+    t.Errorf("Expected 100 vectors seen, Actual %v.", reservoir.Count());
+  }
+}
+
+func TestReservoirUniform(t *testing.T) {
+  // Every one of 20 values should be sampled about size / 20 of the time.
+  var streamLength, size, trials = 20, 5, 4000;
+  hits := make([]int, streamLength);
+  for trial := 0; trial < trials; trial++ {
+    reservoir := utils.NewReservoirWithSeed(size, int64(trial));
+    for i := 0; i < streamLength; i++ {
+      reservoir.Push([]float64{float64(i)});
+    }
+    for _, v := range reservoir.Sample() {
+      hits[int(v[0])]++;
+    }
+  }
+  expected := float64(trials * size) / float64(streamLength);
+  for i, count := range hits {
+    if float64(count) < expected * 0.85 || float64(count) > expected * 1.15 {
+      t.Errorf("Value %v was sampled %v times, expected about %v.", i, count, expected);
+    }
+  }
+}
+
+func TestMergeTopK(t *testing.T) {
+  lists := [][]int64{{1, 2, 3}, {3, 4}, {2, 5}};
+  counts := [][]int64{{10, 4, 6}, {5, 9}, {3, 1}};
+  // Summed: 1:10, 2:7, 3:11, 4:9, 5:1
+  top, topCounts := utils.MergeTopK(lists, counts, 3);
+  if !reflect.DeepEqual(top, []int64{4, 1, 3}) || !reflect.DeepEqual(topCounts, []int64{9, 10, 11}) {
+    t.Errorf("Expected [4 1 3] with counts [9 10 11], Actual %v with %v.", top, topCounts);
+  }
+
+  // Ties keep the smaller item.
+  top, _ = utils.MergeTopK([][]int64{{7, 8, 9}}, [][]int64{{1, 1, 1}}, 2);
+  if !reflect.DeepEqual(top, []int64{8, 7}) {
+    t.Errorf("Expected the tie to keep [8 7], Actual %v.", top);
+  }
+
+  top, topCounts = utils.MergeTopK(lists, counts, 10);
+  if len(top) != 5 || len(topCounts) != 5 {
+    t.Errorf("A k above the number of items should keep all 5, Actual %v.", top);
+  }
+  if top, _ := utils.MergeTopK(nil, nil, 3); len(top) != 0 {
+    t.Errorf("Merging nothing should be empty, Actual %v.", top);
+  }
+};
+
+func TestAngularVarianceSample(t *testing.T) {
+  // Every row points the same way, (0.6, 0.8) at unit length.
+  data := [][]float64{{3, 4}, {6, 8}, {300, 400}, {0, 0}};
+  // Three sampled rows give six coordinates 0.1 from their mean.
+  expected := 0.06 / 5;
+  if variance := utils.AngularVarianceSample(data[:3], 1); math.Abs(variance - expected) > 1e-12 {
+    t.Errorf("Expected %v, Actual %v.", expected, variance);
+  }
+  if variance := utils.AngularVarianceSample(data[3:], 1); variance != 0 {
+    t.Errorf("Rows of zero length should be skipped, Actual %v.", variance);
+  }
+  if variance := utils.AngularVarianceSample(nil, 1); variance != 0 {
+    t.Errorf("An empty sample should have no variance, Actual %v.", variance);
+  }
+};
+
+func TestCanonicalizeClustering(t *testing.T) {
+  first := [][]float64{{1.0000001, 2}, {-0.0000001, 5}, {1, 1}};
+  second := [][]float64{{0, 5}, {1, 1.0000002}, {0.9999999, 2}};
+  expected := [][]float64{{0, 5}, {1, 1}, {1, 2}};
+  if canonical := defaults.CanonicalizeClustering(first); !reflect.DeepEqual(canonical, expected) {
+    t.Errorf("Expected %v, Actual %v.", expected, canonical);
+  }
+  if !reflect.DeepEqual(defaults.CanonicalizeClustering(first), defaults.CanonicalizeClustering(second)) {
+    t.Error("Clusterings that differ only in order and noise should compare equal.");
+  }
+  if first[0][0] != 1.0000001 {
+    t.Error("The input should not be modified.");
+  }
+  if canonical := utils.CanonicalCentroids([][]float64{{1.4}, {0.6}}, 1); !reflect.DeepEqual(canonical, [][]float64{{1}, {1}}) {
+    t.Errorf("Expected both to round to 1, Actual %v.", canonical);
+  }
+};
+
+func TestCorrelation(t *testing.T) {
+  random := rand.New(rand.NewSource(9));
+  data := make([][]float64, 500);
+  for i := range data {
+    x := random.NormFloat64();
+    data[i] = []float64{x, 2 * x + random.NormFloat64() * 0.1, random.NormFloat64(), -x, 7};
+  }
+  accumulator := utils.NewCorrelation(5);
+  if !math.IsNaN(accumulator.Correlation(0, 1)) {
+    t.Error("Without data the correlation should be NaN.");
+  }
+  for _, vec := range data {
+    accumulator.Add(vec);
+  }
+  // The two pass correlation of dimensions i and j.
+  direct := func(i, j int) float64 {
+    var meanI, meanJ, cov, varI, varJ float64;
+    for _, vec := range data {
+      meanI += vec[i] / float64(len(data));
+      meanJ += vec[j] / float64(len(data));
+    }
+    for _, vec := range data {
+      cov += (vec[i] - meanI) * (vec[j] - meanJ);
+      varI += (vec[i] - meanI) * (vec[i] - meanI);
+      varJ += (vec[j] - meanJ) * (vec[j] - meanJ);
+    }
+    return cov / math.Sqrt(varI * varJ);
+  };
+  for i := 0; i < 4; i++ {
+    for j := 0; j < 4; j++ {
+      if expected, actual := direct(i, j), accumulator.Correlation(i, j); math.Abs(expected - actual) > 1e-9 {
+        t.Errorf("Dimensions %v and %v: expected %v, Actual %v.", i, j, expected, actual);
+      }
+    }
+  }
+  if accumulator.Count() != 500 || !math.IsNaN(accumulator.Correlation(0, 4)) {
+    t.Errorf("A constant dimension should have no correlation, Actual %v.", accumulator.Correlation(0, 4));
+  }
+};
@@ -1,5 +1,9 @@
 package types;
 
+import (
+    "io"
+);
+
 type Iterator interface {
     GetS() [][]float64;
     StoreLSHValues([]int64);
@@ -9,6 +13,30 @@ type Iterator interface {
     Reset();
 };
 
+// WeightedIterator is an Iterator whose vectors carry a weight, such as a
+// point standing in for an aggregated group. Weight reports the weight of the
+// vector most recently returned by Next.
+type WeightedIterator interface {
+    Iterator;
+    Weight() float64;
+};
+
+// ClosableIterator is an Iterator holding a file or network handle that must be
+// released once the data is no longer needed. The caller that opened it
+// closes it; Simple reads it as often as it needs and never closes it.
+type ClosableIterator interface {
+    Iterator;
+    io.Closer;
+};
+
+// CopyableIterator is an Iterator that can open another, independent iterator
+// over the same vectors from the first, so concurrent runs never share a
+// position. A copy holding a handle is closed by whoever made it.
+type CopyableIterator interface {
+    Iterator;
+    Copy() (Iterator, error);
+};
+
 type PQueue interface {
     IsEmpty() bool;
     Poll(i interface{});
@@ -33,6 +61,10 @@ type Projector interface {
     Project(v []float64) []float64;
 };
 
+// Distance measures how far apart two vectors of the same length are, smaller
+// being nearer. Assignment picks the centroid at the smallest distance.
+type Distance func(a, b []float64) float64;
+
 type HashSet interface {
     Add(i int64) bool;
     Get(i int64) bool;
@@ -49,6 +81,7 @@ type Hash interface {
 
 type Centroid interface {
     UpdateCentroidVector(data []float64);
+    UpdateWeightedVector(data []float64, weight float64);
     Centroid() []float64;
     UpdateVector(rp []float64);
     GetCount() int64;
@@ -57,8 +90,15 @@ type Centroid interface {
     AddID(h int64);
 };
 
+// Assigner picks which centroid a vector belongs to during Reduce.
+// It returns the index into centroids, or -1 to leave the vector unassigned.
+type Assigner interface {
+    Assign(vec []float64, hash int64, centroids []Centroid) int;
+};
+
 type CountItemSet interface {
     Add(c int64);
+    AddWeighted(c int64, count int64);
     GetCounts() []int64;
     GetTop() []int64;
     GetCount() int64;
@@ -103,9 +143,46 @@ type RPHashObject interface {
     SetVariance(data [][]float64);
 };
 
+// Implemented by RPHash objects that can hold an already constructed
+// projector. Simple hashes with it instead of building one from the random
+// seed, so every run over the object projects identically. A nil projector
+// means none is held.
+type ProjectorHolder interface {
+    GetProjector() Projector;
+};
+
+// Implemented by RPHash objects that check the centroids added to them.
+// AddCentroidChecked refuses a centroid whose length differs from the
+// dimension of the data with an error, where AddCentroid can only warn.
+type CentroidChecker interface {
+    AddCentroidChecked(v []float64) error;
+};
+
+// Logger receives the pipeline's notable events, such as phase transitions
+// and a saturated sketch, and warnings about settings it had to correct.
+// Nothing is logged unless one is set.
+type Logger interface {
+    Debugf(format string, args ...interface{});
+    Infof(format string, args ...interface{});
+    Warnf(format string, args ...interface{});
+};
+
+// Implemented by the parts of the pipeline that can emit to a Logger.
+type Loggable interface {
+    SetLogger(logger Logger);
+};
+
+// Implemented by RPHash objects that keep a history of the decoder variance
+// used during a streaming run.
+type VarianceRecorder interface {
+    RecordVariance(vectorsProcessed int64, variance float64);
+};
+
+// A clustering algorithm. Run does the clustering and GetCentroids returns
+// its result, running it first if it has not been run.
 type Clusterer interface {
+    Run() error;
     GetCentroids() [][]float64;
-    GetRPHash() RPHashObject;
 };
 
 type StreamClusterer interface {
@@ -0,0 +1,41 @@
+package utils;
+
+import (
+    "math"
+    "sort"
+);
+
+type lexicographic [][]float64;
+
+func (this lexicographic) Len() int { return len(this); };
+func (this lexicographic) Swap(i, j int) { this[i], this[j] = this[j], this[i]; };
+func (this lexicographic) Less(i, j int) bool {
+    for k := 0; k < len(this[i]) && k < len(this[j]); k++ {
+        if this[i][k] != this[j][k] {
+            return this[i][k] < this[j][k];
+        }
+    }
+    return len(this[i]) < len(this[j]);
+};
+
+// A canonical copy of a set of centroids for comparing clusterings: every
+// coordinate is rounded to the nearest multiple of tolerance and the
+// centroids are sorted lexicographically, so two runs that found the same
+// clusters in a different order compare equal. Coordinates within tolerance
+// of each other can still round apart when they straddle a rounding
+// boundary, so pick a tolerance well above the noise between runs.
+func CanonicalCentroids(centroids [][]float64, tolerance float64) [][]float64 {
+    result := make([][]float64, len(centroids));
+    for i, centroid := range centroids {
+        result[i] = make([]float64, len(centroid));
+        for j, x := range centroid {
+            if tolerance > 0 {
+                x = math.Floor(x / tolerance + 0.5) * tolerance;
+            }
+            // Fold -0 into 0 so both print and compare alike.
+            result[i][j] = x + 0;
+        }
+    }
+    sort.Sort(lexicographic(result));
+    return result;
+};
@@ -0,0 +1,62 @@
+package utils;
+
+import (
+    "math"
+);
+
+// Correlation keeps the running means and co-moments of a stream of vectors,
+// so the Pearson correlation of any two dimensions can be read at any time
+// without keeping the vectors. The update is Welford's, extended to pairs.
+type Correlation struct {
+    count int64;
+    means []float64;
+    comoments [][]float64;
+};
+
+func NewCorrelation(dimension int) *Correlation {
+    comoments := make([][]float64, dimension);
+    for i := range comoments {
+        comoments[i] = make([]float64, dimension);
+    }
+    return &Correlation{
+        count: 0,
+        means: make([]float64, dimension),
+        comoments: comoments,
+    };
+};
+
+// Add a vector of the accumulator's dimension.
+func (this *Correlation) Add(vec []float64) {
+    this.count++;
+    deltas := make([]float64, len(this.means));
+    for i := range this.means {
+        deltas[i] = vec[i] - this.means[i];
+        this.means[i] += deltas[i] / float64(this.count);
+    }
+    for i := range this.means {
+        for j := i; j < len(this.means); j++ {
+            this.comoments[i][j] += deltas[i] * (vec[j] - this.means[j]);
+        }
+    }
+};
+
+func (this *Correlation) Count() int64 {
+    return this.count;
+};
+
+func (this *Correlation) Dimension() int {
+    return len(this.means);
+};
+
+// The correlation of dimensions i and j, between -1 and 1.
+// NaN until there are two vectors, or if either dimension is constant.
+func (this *Correlation) Correlation(i, j int) float64 {
+    if i > j {
+        i, j = j, i;
+    }
+    if this.count < 2 || this.comoments[i][i] == 0 || this.comoments[j][j] == 0 {
+        return math.NaN();
+    }
+    r := this.comoments[i][j] / math.Sqrt(this.comoments[i][i] * this.comoments[j][j]);
+    return math.Max(-1, math.Min(1, r));
+};
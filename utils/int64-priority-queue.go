@@ -9,9 +9,26 @@ type int64WithPriority struct {
     actualInt int64
     priority int64
 }
+
+// Int64Comparator ranks two items given their priorities. It returns a negative
+// number when the first ranks below the second, a positive number when it ranks
+// above, and zero when they tie. The lowest ranked item is polled first.
+type Int64Comparator func(item1, priority1, item2, priority2 int64) int
+
+// The default ranking, by priority alone.
+func ComparePriority(item1, priority1, item2, priority2 int64) int {
+    if priority1 > priority2 {
+        return 1;
+    } else if priority1 < priority2 {
+        return -1;
+    }
+    return 0;
+}
+
 type Int64PriorityQueue struct {
     heap     []int64WithPriority
     heapSize int
+    comparator Int64Comparator
 }
 
 func NewInt64PriorityQueue() *Int64PriorityQueue {
@@ -19,6 +36,18 @@ func NewInt64PriorityQueue() *Int64PriorityQueue {
   return &Int64PriorityQueue{
     heapSize: 0,
     heap: heap,
+    comparator: ComparePriority,
+  }
+}
+
+// Change how items are ranked, reordering anything already queued.
+func (this *Int64PriorityQueue) SetComparator(comparator Int64Comparator) {
+  if comparator == nil {
+    comparator = ComparePriority;
+  }
+  this.comparator = comparator;
+  for i := this.heapSize / 2; i > 0; i-- {
+    this.percolateDown(i);
   }
 }
 
@@ -42,6 +71,20 @@ func (this *Int64PriorityQueue) PeakMinPriority() int64 {
   return this.heap[1].priority;
 }
 
+// The lowest ranked item, without removing it.
+func (this *Int64PriorityQueue) PeakMin() int64 {
+  return this.heap[1].actualInt;
+}
+
+// Whether an item with the given priority would rank below everything queued.
+func (this *Int64PriorityQueue) RanksBelowMin(item int64, priority int64) bool {
+  if this.heapSize < 1 {
+    return false;
+  }
+  min := this.heap[1];
+  return this.comparator(item, priority, min.actualInt, min.priority) <= 0;
+}
+
 func (this *Int64PriorityQueue) Poll() int64 {
   var result, error = this.Dequeue();
   if error != nil {
@@ -76,6 +119,30 @@ func (this *Int64PriorityQueue) Enqueue(newInt int64, priority int64) {
   this.percolateUp(this.heapSize);
 }
 
+// The queued items and their priorities in heap order. Enqueuing them in this
+// order into an empty queue with the same comparator rebuilds the same heap.
+func (this *Int64PriorityQueue) Entries() ([]int64, []int64) {
+  items := make([]int64, this.heapSize);
+  priorities := make([]int64, this.heapSize);
+  for i := 1; i <= this.heapSize; i++ {
+    items[i - 1] = this.heap[i].actualInt;
+    priorities[i - 1] = this.heap[i].priority;
+  }
+  return items, priorities;
+}
+
+// A queue holding the same heap and comparator, so polling it leaves this
+// one untouched.
+func (this *Int64PriorityQueue) Copy() *Int64PriorityQueue {
+  heap := make([]int64WithPriority, len(this.heap))
+  copy(heap, this.heap)
+  return &Int64PriorityQueue{
+    heapSize: this.heapSize,
+    heap: heap,
+    comparator: this.comparator,
+  }
+}
+
 func (this *Int64PriorityQueue) Size() int {
   return this.heapSize;
 }
@@ -116,10 +183,6 @@ func (this *Int64PriorityQueue) swap(index1 int, index2 int) {
 }
 
 func (this *Int64PriorityQueue) compare(index1 int,index2 int) int {
-    if this.heap[index1].priority > this.heap[index2].priority {
-        return 1;
-    } else if this.heap[index1].priority < this.heap[index2].priority {
-        return -1;
-    }
-    return 0;
+    first, second := this.heap[index1], this.heap[index2];
+    return this.comparator(first.actualInt, first.priority, second.actualInt, second.priority);
 }
@@ -1,5 +1,9 @@
 package utils;
 
+import (
+    "github.com/wenkesj/rphash/types"
+);
+
 type IterableSlice struct {
     position int;
     data [][]float64;
@@ -39,6 +43,11 @@ func (this *IterableSlice) Reset() {
   this.position = -1;
 }
 
+// A new iterator over the same slice, sharing the vectors but not the position.
+func (this *IterableSlice) Copy() (types.Iterator, error) {
+  return NewIterator(this.data), nil;
+}
+
 func NewIterator(data [][]float64) *IterableSlice {
     return &IterableSlice{-1, data, nil};
 };
@@ -0,0 +1,14 @@
+package utils;
+
+// NopLogger discards everything, it is the logger used until one is set.
+type NopLogger struct {};
+
+func NewNopLogger() *NopLogger {
+    return &NopLogger{};
+};
+
+func (this *NopLogger) Debugf(format string, args ...interface{}) {};
+
+func (this *NopLogger) Infof(format string, args ...interface{}) {};
+
+func (this *NopLogger) Warnf(format string, args ...interface{}) {};
@@ -0,0 +1,52 @@
+package utils;
+
+import (
+    "math/rand"
+    "time"
+);
+
+// Reservoir keeps a uniform random sample of at most size vectors from a
+// stream of unknown length (Vitter's Algorithm R).
+type Reservoir struct {
+    size int;
+    seen int64;
+    sample [][]float64;
+    random *rand.Rand;
+};
+
+func NewReservoir(size int) *Reservoir {
+    return NewReservoirWithSeed(size, time.Now().UnixNano());
+};
+
+func NewReservoirWithSeed(size int, seed int64) *Reservoir {
+    return &Reservoir{
+        size: size,
+        seen: 0,
+        sample: make([][]float64, 0, size),
+        random: rand.New(rand.NewSource(seed)),
+    };
+};
+
+// Offer a vector to the sample. The i-th vector pushed is kept with
+// probability size / i, replacing a uniformly chosen earlier one.
+func (this *Reservoir) Push(v []float64) {
+    this.seen++;
+    if len(this.sample) < this.size {
+        this.sample = append(this.sample, v);
+        return;
+    }
+    j := this.random.Int63n(this.seen);
+    if j < int64(this.size) {
+        this.sample[j] = v;
+    }
+};
+
+// The vectors currently held, fewer than size until that many were pushed.
+func (this *Reservoir) Sample() [][]float64 {
+    return this.sample;
+};
+
+// The number of vectors pushed so far.
+func (this *Reservoir) Count() int64 {
+    return this.seen;
+};
@@ -1,6 +1,7 @@
 package utils;
 
 import (
+    "math"
     "math/rand"
 );
 
@@ -14,8 +15,10 @@ func NewStatTest(sampRatio float64) *StatTest{
     };
 };
 
+// Fold a 64 bit value into 32 bits the way Java's Long.hashCode does.
+// Distinct values can share a hash code, so it must not be used as a key.
 func HashCode(num int64) int64 {
-    return int64(uint64(num) ^ uint64(num) >> 64);
+    return int64(int32(uint64(num) ^ uint64(num) >> 32));
 };
 
 func (this *StatTest) UpdateVarianceSample(row []float64) float64 {
@@ -58,6 +61,40 @@ func VarianceSample(data [][]float64, sampRatio float64) float64 {
 };
 
 
+// The variance of a sample of rows scaled to unit length, the dispersion that
+// matters when vectors are compared by angle rather than by distance.
+// At least one row is sampled, and rows of zero length are skipped as they
+// have no direction.
+func AngularVarianceSample(data [][]float64, sampRatio float64) float64 {
+    var n float64 = 0;
+    var mean float64 = 0;
+    var M2 float64 = 0;
+    len := len(data);
+    if len == 0 {
+        return 0;
+    }
+    samples := int(math.Ceil(sampRatio * float64(len)));
+    if samples < 1 {
+        samples = 1;
+    }
+    for i := 0; i < samples; i++ {
+        row := data[rand.Intn(len)];
+        if Norm(row) == 0 {
+            continue;
+        }
+        for _, x := range Normalize(row) {
+            n++;
+            delta := x - mean;
+            mean = mean + delta / n;
+            M2 = M2 + delta * (x - mean);
+        }
+    }
+    if n < 2 {
+        return 0;
+    }
+    return  M2 / (n - 1.0);
+};
+
 func (this *StatTest) VarianceAll(data [][]float64) float64 {
     var n float64 = 0;
     var mean float64 = 0;
@@ -0,0 +1,50 @@
+package utils;
+
+// Ties on the summed count keep the smaller item, so merges are reproducible.
+func compareMerged(item1, priority1, item2, priority2 int64) int {
+    if order := ComparePriority(item1, priority1, item2, priority2); order != 0 {
+        return order;
+    }
+    if item1 > item2 {
+        return -1;
+    } else if item1 < item2 {
+        return 1;
+    }
+    return 0;
+};
+
+// Merge the top lists of several sketches, such as the workers of a parallel
+// Map, into a global top k. Each item's counts are summed across the lists it
+// appears in and the k items with the largest sums are kept with a bounded
+// heap. lists[i] and counts[i] pair up item by item, and the result is ordered
+// like a sketch's GetTop, from the lowest summed count to the highest.
+func MergeTopK(lists [][]int64, counts [][]int64, k int) ([]int64, []int64) {
+    sums := make(map[int64]int64);
+    var order []int64;
+    for i := 0; i < len(lists) && i < len(counts); i++ {
+        for j := 0; j < len(lists[i]) && j < len(counts[i]); j++ {
+            item := lists[i][j];
+            if _, ok := sums[item]; !ok {
+                order = append(order, item);
+            }
+            sums[item] += counts[i][j];
+        }
+    }
+
+    queue := NewInt64PriorityQueue();
+    queue.SetComparator(compareMerged);
+    for _, item := range order {
+        queue.Enqueue(item, sums[item]);
+        if queue.Size() > k {
+            queue.Poll();
+        }
+    }
+
+    top := make([]int64, 0, queue.Size());
+    topCounts := make([]int64, 0, queue.Size());
+    for !queue.IsEmpty() {
+        topCounts = append(topCounts, queue.PeakMinPriority());
+        top = append(top, queue.Poll());
+    }
+    return top, topCounts;
+};
@@ -3,6 +3,7 @@ package utils;
 import (
   "math"
   "math/rand"
+  "github.com/wenkesj/rphash/types"
 );
 
 func Normalize(input []float64) []float64 {
@@ -135,13 +136,49 @@ func Distance(x, y []float64) float64 {
     return math.Sqrt(dist);
 }
 
+// The squared Euclidean distance. It ranks vectors the same as Distance
+// without the square root.
+func SquaredDistance(x, y []float64) float64 {
+    var dist float64;
+    for i := range x {
+        dist += (x[i] - y[i]) * (x[i] - y[i]);
+    }
+    return dist;
+};
+
+// One minus the cosine of the angle between x and y, from 0 for vectors
+// pointing the same way to 2 for opposite ones. It ignores magnitude, so any
+// positive multiple of a vector is at distance 0 from it. A zero vector has
+// no direction and is at distance 1 from everything.
+func CosineDistance(x, y []float64) float64 {
+    var dot, xx, yy float64;
+    for i := range x {
+        dot += x[i] * y[i];
+        xx += x[i] * x[i];
+        yy += y[i] * y[i];
+    }
+    if xx == 0 || yy == 0 {
+        return 1;
+    }
+    return 1 - dot / math.Sqrt(xx * yy);
+};
+
+// The index of the vector in DB nearest to x by Euclidean distance.
+// See FindNearest.
 func FindNearestDistance(x []float64, DB [][]float64) int {
-    mindist := Distance(x, DB[0]);
+    return FindNearest(x, DB, Distance);
+};
+
+// The index of the vector in DB nearest to x by distance. A point equally
+// near several vectors goes to the lowest index, so assignments never depend
+// on anything but the order of DB.
+func FindNearest(x []float64, DB [][]float64, distance types.Distance) int {
+    mindist := distance(x, DB[0]);
     minindex := 0;
     var tmp float64;
     for i := 1; i < len(DB); i++ {
-        tmp = Distance(x, DB[i]);
-        if tmp <= mindist {
+        tmp = distance(x, DB[i]);
+        if tmp < mindist {
             mindist = tmp;
             minindex = i;
         }